@@ -2,6 +2,7 @@ package gocabalparser
 
 import (
 	"io"
+	"sort"
 )
 
 type SourceRepository struct {
@@ -10,6 +11,9 @@ type SourceRepository struct {
 	Tag      string
 }
 
+// Dependency is a build-depends entry. Range holds the exact version
+// range; the float bounds approximate plain conjunctions of bounds with
+// at most two version components and are kept for compatibility.
 type Dependency struct {
 	Name               string
 	IsLatest           bool
@@ -17,35 +21,201 @@ type Dependency struct {
 	GreaterOrEqualThan float64
 	LessThan           float64
 	LessOrEqualThan    float64
+	Range              *VersionRange
+}
+
+type ModuleRenamingKind int
+
+const (
+	// ModuleRenamingDefault brings all modules into scope under their own names.
+	ModuleRenamingDefault ModuleRenamingKind = iota
+	// ModuleRenamingExplicit brings only the listed modules into scope.
+	ModuleRenamingExplicit
+	// ModuleRenamingHiding brings all modules except the listed ones into scope.
+	ModuleRenamingHiding
+)
+
+type ModuleRename struct {
+	From ModuleName
+	To   ModuleName
+}
+
+type ModuleRenaming struct {
+	Kind    ModuleRenamingKind
+	Renames []ModuleRename
+	Hiding  []ModuleName
+}
+
+// Mixin is a Backpack mixins entry, e.g.
+// str-bytestring (Str as Str.ByteString) requires (Str as Str.Sig).
+type Mixin struct {
+	PackageName string
+	LibraryName string
+	Includes    ModuleRenaming
+	Requires    ModuleRenaming
+}
+
+// Flag is a flag section. Default is true unless the section says
+// otherwise; manual flags are not toggled by the solver.
+type Flag struct {
+	Description string
+	Default     bool
+	Manual      bool
+}
+
+type BuildInfo struct {
+	BuildDepends      []*Dependency
+	Mixins            []*Mixin
+	DefaultLanguage   Language
+	Extensions        []string
+	DefaultExtensions []string
+	OtherExtensions   []string
+	OtherModules      []ModuleName
+	AutogenModules    []ModuleName
+	HSSourceDirs      []string
+	GHCOptions        []string
+	CSources          []string
+	IncludeDirs       []string
+	Includes          []string
+	InstallIncludes   []string
+	Conditionals      []*Conditional
+}
+
+// Conditional is an if block of a component, e.g. if os(windows). Else is
+// nil when the block has no else branch.
+type Conditional struct {
+	Condition string
+	Then      ConditionalBranch
+	Else      *ConditionalBranch
+}
+
+// ConditionalBranch is the body of an if or else block. Besides build info
+// it holds the fields of the enclosing component: exposed-modules,
+// reexported-modules and signatures in libraries, main-is in executables.
+type ConditionalBranch struct {
+	BuildInfo
+	ExposedModules    []ModuleName
+	ReexportedModules []*ModuleReexport
+	Signatures        []ModuleName
+	MainIs            string
+}
+
+// branchValues returns a field of the component followed by the same
+// field of all its conditional branches, regardless of their conditions.
+func branchValues[T any](bi *BuildInfo, field func(b *BuildInfo) []T) []T {
+	res := append([]T{}, field(bi)...)
+
+	for _, b := range conditionalBranches(bi) {
+		res = append(res, field(&b.BuildInfo)...)
+	}
+
+	return res
+}
+
+// conditionalBranches returns the then and else branches of the
+// conditionals of bi, each followed by its nested branches.
+func conditionalBranches(bi *BuildInfo) []*ConditionalBranch {
+	res := make([]*ConditionalBranch, 0)
+
+	for _, c := range bi.Conditionals {
+		res = append(res, &c.Then)
+		res = append(res, conditionalBranches(&c.Then.BuildInfo)...)
+
+		if c.Else != nil {
+			res = append(res, c.Else)
+			res = append(res, conditionalBranches(&c.Else.BuildInfo)...)
+		}
+	}
+
+	return res
+}
+
+// ModuleReexport is a reexported-modules entry, e.g.
+// containers:Data.Map as Map.
+type ModuleReexport struct {
+	OriginalPackage string
+	OriginalName    ModuleName
+	NewName         ModuleName
+}
+
+type Library struct {
+	BuildInfo
+	ExposedModules    []ModuleName
+	ReexportedModules []*ModuleReexport
+	Signatures        []ModuleName
 }
 
 type Executable struct {
-	BuildDepends []*Dependency
-	Extensions   []string
-	MainIs       string
-	OtherModules []string
-	HSSourceDirs []string
+	BuildInfo
+	MainIs string
 }
 
+// CabalPackage is a parsed .cabal file. Revision is the x-revision field
+// added by Hackage metadata revisions, 0 for the original upload.
 type CabalPackage struct {
-	Name         string
-	Version      string
-	CabalVersion string
-	BuildType    string
-	License      string
-	LicenseFile  string
-	Copyright    []string
-	Author       string
-	Maintainer   string
-	Stability    string
-	Homepage     string
-	PackageURL   string
-	Synopsis     []string
-	Description  []string
-	Category     string
-	TestedWith   string
-	Repositories map[string]*SourceRepository
-	Executables  map[string]*Executable
+	Name             string
+	Version          string
+	Revision         int
+	CabalVersion     string
+	BuildType        string
+	License          string
+	LicenseFile      string
+	Copyright        []string
+	Author           string
+	Maintainer       string
+	Stability        string
+	Homepage         string
+	PackageURL       string
+	Synopsis         []string
+	Description      []string
+	Category         string
+	TestedWith       string
+	DataDir          string
+	DataFiles        []string
+	ExtraSourceFiles []string
+	ExtraDocFiles    []string
+	Repositories     map[string]*SourceRepository
+	Flags            map[string]*Flag
+	Library          *Library
+	SubLibraries     map[string]*Library
+	Executables      map[string]*Executable
+}
+
+type namedBuildInfo struct {
+	name string
+	info *BuildInfo
+}
+
+// buildInfos returns build info of all components in a stable order:
+// the main library, sub-libraries and executables sorted by name.
+func (p *CabalPackage) buildInfos() []namedBuildInfo {
+	res := make([]namedBuildInfo, 0)
+
+	if p.Library != nil {
+		res = append(res, namedBuildInfo{name: "library", info: &p.Library.BuildInfo})
+	}
+
+	for _, name := range sortedKeys(p.SubLibraries) {
+		res = append(res, namedBuildInfo{name: "library " + name, info: &p.SubLibraries[name].BuildInfo})
+	}
+
+	for _, name := range sortedKeys(p.Executables) {
+		res = append(res, namedBuildInfo{name: "executable " + name, info: &p.Executables[name].BuildInfo})
+	}
+
+	return res
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
 }
 
 type Parser interface {
@@ -59,10 +229,10 @@ func NewParser() Parser {
 }
 
 func (p *parser) ParseReader(r io.Reader) (*CabalPackage, error) {
-	tokens, err := newTokenizer().TokenizeReader(r)
+	tree, err := ParseSyntaxTree(r)
 	if err != nil {
 		return nil, err
 	}
 
-	return newTokensParser().Parse(tokens)
+	return tree.Package()
 }
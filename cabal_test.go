@@ -61,73 +61,86 @@ func TestParse(t *testing.T) {
 				},
 				Executables: map[string]*Executable{
 					"mountains": {
-						BuildDepends: []*Dependency{
-							{
-								Name:               "base",
-								GreaterOrEqualThan: 3.0,
-								LessThan:           5,
+						BuildInfo: BuildInfo{
+							BuildDepends: []*Dependency{
+								{
+									Name:               "base",
+									GreaterOrEqualThan: 3.0,
+									LessThan:           5,
+									Range:              mustParseVersionRange(">= 3.0 && < 5"),
+								},
+								{
+									Name:               "GLUT",
+									GreaterOrEqualThan: 2.4,
+									LessThan:           2.8,
+									Range:              mustParseVersionRange(">= 2.4 && < 2.8"),
+								},
+								{
+									Name:               "OpenGL",
+									GreaterOrEqualThan: 2.8,
+									LessThan:           3.1,
+									Range:              mustParseVersionRange(">= 2.8 && < 3.1"),
+								},
+								{
+									Name:               "random",
+									GreaterOrEqualThan: 1.0,
+									LessThan:           1.2,
+									Range:              mustParseVersionRange(">= 1.0 && < 1.2"),
+								},
 							},
-							{
-								Name:               "GLUT",
-								GreaterOrEqualThan: 2.4,
-								LessThan:           2.8,
+							Extensions: []string{
+								"FlexibleContexts",
 							},
-							{
-								Name:               "OpenGL",
-								GreaterOrEqualThan: 2.8,
-								LessThan:           3.1,
+							OtherModules: []ModuleName{
+								"Utilities",
 							},
-							{
-								Name:               "random",
-								GreaterOrEqualThan: 1.0,
-								LessThan:           1.2,
+							HSSourceDirs: []string{
+								"src",
+								"src/mountains",
 							},
 						},
-						Extensions: []string{
-							"FlexibleContexts",
-						},
 						MainIs: "Mountains.hs",
-						OtherModules: []string{
-							"Utilities",
-						},
-						HSSourceDirs: []string{
-							"src src/mountains",
-						},
 					},
 					"l-systems": {
-						BuildDepends: []*Dependency{
-							{
-								Name:               "base",
-								GreaterOrEqualThan: 3.0,
-								LessThan:           5,
+						BuildInfo: BuildInfo{
+							BuildDepends: []*Dependency{
+								{
+									Name:               "base",
+									GreaterOrEqualThan: 3.0,
+									LessThan:           5,
+									Range:              mustParseVersionRange(">= 3.0 && < 5"),
+								},
+								{
+									Name:               "GLUT",
+									GreaterOrEqualThan: 2.4,
+									LessThan:           2.8,
+									Range:              mustParseVersionRange(">= 2.4 && < 2.8"),
+								},
+								{
+									Name:               "OpenGL",
+									GreaterOrEqualThan: 2.8,
+									LessThan:           3.1,
+									Range:              mustParseVersionRange(">= 2.8 && < 3.1"),
+								},
 							},
-							{
-								Name:               "GLUT",
-								GreaterOrEqualThan: 2.4,
-								LessThan:           2.8,
+							Extensions: []string{
+								"FlexibleContexts",
 							},
-							{
-								Name:               "OpenGL",
-								GreaterOrEqualThan: 2.8,
-								LessThan:           3.1,
+							OtherModules: []ModuleName{
+								"Utilities",
+								"ConiferLSystem",
+								"IslandLSystem",
+								"KochLSystem",
+								"LSystem",
+								"TreeLSystem",
+								"Turtle",
+							},
+							HSSourceDirs: []string{
+								"src",
+								"src/l-systems",
 							},
-						},
-						Extensions: []string{
-							"FlexibleContexts",
 						},
 						MainIs: "LSystems.hs",
-						OtherModules: []string{
-							"Utilities",
-							"ConiferLSystem",
-							"IslandLSystem",
-							"KochLSystem",
-							"LSystem",
-							"TreeLSystem",
-							"Turtle",
-						},
-						HSSourceDirs: []string{
-							"src src/l-systems",
-						},
 					},
 				},
 			},
@@ -186,74 +199,245 @@ func TestParse(t *testing.T) {
 				},
 				Executables: map[string]*Executable{
 					"mountains": {
+						BuildInfo: BuildInfo{
+							BuildDepends: []*Dependency{
+								{
+									Name:               "base",
+									GreaterOrEqualThan: 3.0,
+									LessThan:           5,
+									Range:              mustParseVersionRange(">= 3.0 && < 5"),
+								},
+								{
+									Name:               "GLUT",
+									GreaterOrEqualThan: 2.4,
+									LessThan:           2.8,
+									Range:              mustParseVersionRange(">= 2.4 && < 2.8"),
+								},
+								{
+									Name:               "OpenGL",
+									GreaterOrEqualThan: 2.8,
+									LessThan:           3.1,
+									Range:              mustParseVersionRange(">= 2.8 && < 3.1"),
+								},
+								{
+									Name:               "random",
+									GreaterOrEqualThan: 1.0,
+									LessThan:           1.2,
+									Range:              mustParseVersionRange(">= 1.0 && < 1.2"),
+								},
+							},
+							Extensions: []string{
+								"FlexibleContexts",
+							},
+							OtherModules: []ModuleName{
+								"Utilities",
+							},
+							HSSourceDirs: []string{
+								"src",
+								"src/mountains",
+							},
+						},
+						MainIs: "Mountains.hs",
+					},
+					"l-systems": {
+						BuildInfo: BuildInfo{
+							BuildDepends: []*Dependency{
+								{
+									Name:               "base",
+									GreaterOrEqualThan: 3.0,
+									LessThan:           5,
+									Range:              mustParseVersionRange(">= 3.0 && < 5"),
+								},
+								{
+									Name:               "GLUT",
+									GreaterOrEqualThan: 2.4,
+									LessThan:           2.8,
+									Range:              mustParseVersionRange(">= 2.4 && < 2.8"),
+								},
+								{
+									Name:               "OpenGL",
+									GreaterOrEqualThan: 2.8,
+									LessThan:           3.1,
+									Range:              mustParseVersionRange(">= 2.8 && < 3.1"),
+								},
+							},
+							Extensions: []string{
+								"FlexibleContexts",
+							},
+							OtherModules: []ModuleName{
+								"Utilities",
+								"ConiferLSystem",
+								"IslandLSystem",
+								"KochLSystem",
+								"LSystem",
+								"TreeLSystem",
+								"Turtle",
+							},
+							HSSourceDirs: []string{
+								"src",
+								"src/l-systems",
+							},
+						},
+						MainIs: "LSystems.hs",
+					},
+				},
+			},
+		},
+		{
+			name:     "libraries",
+			filename: "5.cabal",
+			expected: &CabalPackage{
+				Name:         "containers-extra",
+				Version:      "0.1.0.0",
+				CabalVersion: "3.0",
+				Library: &Library{
+					BuildInfo: BuildInfo{
 						BuildDepends: []*Dependency{
 							{
 								Name:               "base",
-								GreaterOrEqualThan: 3.0,
+								GreaterOrEqualThan: 4.0,
 								LessThan:           5,
+								Range:              mustParseVersionRange(">= 4.0 && < 5"),
 							},
 							{
-								Name:               "GLUT",
-								GreaterOrEqualThan: 2.4,
-								LessThan:           2.8,
-							},
-							{
-								Name:               "OpenGL",
-								GreaterOrEqualThan: 2.8,
-								LessThan:           3.1,
-							},
-							{
-								Name:               "random",
-								GreaterOrEqualThan: 1.0,
-								LessThan:           1.2,
+								Name:               "containers",
+								GreaterOrEqualThan: 0.6,
+								Range:              mustParseVersionRange(">= 0.6"),
 							},
 						},
-						Extensions: []string{
-							"FlexibleContexts",
+						DefaultLanguage: Haskell2010,
+						DefaultExtensions: []string{
+							"OverloadedStrings",
 						},
-						MainIs: "Mountains.hs",
-						OtherModules: []string{
-							"Utilities",
+						OtherModules: []ModuleName{
+							"Data.Internal",
+							"Paths_containers_extra",
+						},
+						AutogenModules: []ModuleName{
+							"Paths_containers_extra",
 						},
 						HSSourceDirs: []string{
-							"src src/mountains",
+							"src",
+						},
+						GHCOptions: []string{
+							"-Wall",
+							"-with-rtsopts=-N -A64m",
 						},
 					},
-					"l-systems": {
-						BuildDepends: []*Dependency{
-							{
-								Name:               "base",
-								GreaterOrEqualThan: 3.0,
-								LessThan:           5,
+					ExposedModules: []ModuleName{
+						"Data.Map.Extra",
+						"Data.Set.Extra",
+					},
+				},
+				SubLibraries: map[string]*Library{
+					"internal": {
+						BuildInfo: BuildInfo{
+							BuildDepends: []*Dependency{
+								{
+									Name:     "base",
+									IsLatest: true,
+									Range:    &VersionRange{},
+								},
 							},
+						},
+						ExposedModules: []ModuleName{
+							"Data.Internal.Utils",
+						},
+					},
+				},
+			},
+		},
+		{
+			name:     "conditionals",
+			filename: "8.cabal",
+			expected: &CabalPackage{
+				Name:         "conditional",
+				Version:      "0.2.0.0",
+				CabalVersion: "2.4",
+				Synopsis:     []string{"A package with flags and conditionals"},
+				Flags: map[string]*Flag{
+					"dev": {Description: "Development build\nwithout optimisation", Manual: true},
+				},
+				Library: &Library{
+					BuildInfo: BuildInfo{
+						BuildDepends:    []*Dependency{mustParseDependency("base >= 4.14 && < 5")},
+						DefaultLanguage: Haskell2010,
+						HSSourceDirs:    []string{"src"},
+						GHCOptions:      []string{"-Wall"},
+						Conditionals: []*Conditional{
 							{
-								Name:               "GLUT",
-								GreaterOrEqualThan: 2.4,
-								LessThan:           2.8,
+								Condition: "os(windows)",
+								Then: ConditionalBranch{BuildInfo: BuildInfo{
+									BuildDepends: []*Dependency{mustParseDependency("Win32 >= 2.10")},
+									OtherModules: []ModuleName{"Conditional.Windows"},
+								}},
+								Else: &ConditionalBranch{BuildInfo: BuildInfo{
+									BuildDepends: []*Dependency{mustParseDependency("unix")},
+									OtherModules: []ModuleName{"Conditional.Posix"},
+								}},
 							},
 							{
-								Name:               "OpenGL",
-								GreaterOrEqualThan: 2.8,
-								LessThan:           3.1,
+								Condition: "flag(dev)",
+								Then: ConditionalBranch{BuildInfo: BuildInfo{
+									GHCOptions: []string{"-O0"},
+									Conditionals: []*Conditional{
+										{
+											Condition: "impl(ghc >= 9.4)",
+											Then:      ConditionalBranch{BuildInfo: BuildInfo{GHCOptions: []string{"-Wno-x-partial"}}},
+										},
+									},
+								}},
 							},
 						},
-						Extensions: []string{
-							"FlexibleContexts",
-						},
-						MainIs: "LSystems.hs",
-						OtherModules: []string{
-							"Utilities",
-							"ConiferLSystem",
-							"IslandLSystem",
-							"KochLSystem",
-							"LSystem",
-							"TreeLSystem",
-							"Turtle",
+					},
+					ExposedModules: []ModuleName{"Conditional"},
+				},
+				Executables: map[string]*Executable{
+					"conditional": {
+						BuildInfo: BuildInfo{
+							BuildDepends:    []*Dependency{mustParseDependency("base"), mustParseDependency("conditional")},
+							DefaultLanguage: Haskell2010,
+							HSSourceDirs:    []string{"app"},
+							Conditionals: []*Conditional{
+								{
+									Condition: "!flag(dev) && arch(x86_64)",
+									Then:      ConditionalBranch{BuildInfo: BuildInfo{GHCOptions: []string{"-O2"}}},
+								},
+							},
 						},
-						HSSourceDirs: []string{
-							"src src/l-systems",
+						MainIs: "Main.hs",
+					},
+				},
+			},
+		},
+		{
+			name:     "c sources",
+			filename: "9.cabal",
+			expected: &CabalPackage{
+				Name:         "cbits",
+				Version:      "1.0.0",
+				CabalVersion: "2.4",
+				Synopsis:     []string{"A package with C sources"},
+				Library: &Library{
+					BuildInfo: BuildInfo{
+						BuildDepends:    []*Dependency{mustParseDependency("base >= 4.14 && < 5")},
+						DefaultLanguage: Haskell2010,
+						HSSourceDirs:    []string{"src"},
+						CSources:        []string{"cbits/hash.c", "cbits/util.c"},
+						IncludeDirs:     []string{"include"},
+						Includes:        []string{"stdint.h"},
+						InstallIncludes: []string{"hash.h"},
+						Conditionals: []*Conditional{
+							{
+								Condition: "arch(x86_64)",
+								Then: ConditionalBranch{BuildInfo: BuildInfo{
+									CSources:    []string{"cbits/hash-sse.c"},
+									IncludeDirs: []string{"include/x86"},
+								}},
+							},
 						},
 					},
+					ExposedModules: []ModuleName{"Data.Hash"},
 				},
 			},
 		},
@@ -0,0 +1,340 @@
+package gocabalparser
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+type Severity int
+
+const (
+	// SeverityWarning marks suspicious but acceptable packages.
+	SeverityWarning Severity = iota
+	// SeverityError marks problems that make a package unfit for upload.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("unknown severity: %d", s)
+	}
+}
+
+// Diagnostic is a single problem reported by Check. Component is empty for
+// package level problems.
+type Diagnostic struct {
+	RuleID    string
+	Severity  Severity
+	Component string
+	Message   string
+}
+
+func (d *Diagnostic) String() string {
+	if d.Component == "" {
+		return fmt.Sprintf("%s [%s]: %s", d.Severity, d.RuleID, d.Message)
+	}
+
+	return fmt.Sprintf("%s [%s]: %s: %s", d.Severity, d.RuleID, d.Component, d.Message)
+}
+
+const maxSynopsisLength = 80
+
+type checkRule func(p *CabalPackage) []*Diagnostic
+
+var checkRules = []checkRule{
+	checkRequiredFields,
+	checkVersion,
+	checkSynopsis,
+	checkDescription,
+	checkLicense,
+	checkMetadata,
+	checkBaseUpperBound,
+	checkGHCOptions,
+	checkSourceDirs,
+	checkLanguage,
+	checkExtensions,
+	checkReexports,
+}
+
+// Check runs the checks cabal check performs before upload on the parsed
+// package and returns the problems found, package level ones first. Like
+// cabal check, fields of conditional branches count as fields of their
+// component whichever way the conditions go.
+func Check(p *CabalPackage) []*Diagnostic {
+	res := make([]*Diagnostic, 0)
+
+	for _, rule := range checkRules {
+		res = append(res, rule(p)...)
+	}
+
+	return res
+}
+
+func newDiagnostic(ruleID string, severity Severity, component, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{
+		RuleID:    ruleID,
+		Severity:  severity,
+		Component: component,
+		Message:   fmt.Sprintf(format, args...),
+	}
+}
+
+func checkRequiredFields(p *CabalPackage) []*Diagnostic {
+	res := make([]*Diagnostic, 0)
+
+	if p.Name == "" {
+		res = append(res, newDiagnostic("no-name", SeverityError, "", "the 'name' field is missing"))
+	}
+
+	if p.Version == "" {
+		res = append(res, newDiagnostic("no-version", SeverityError, "", "the 'version' field is missing"))
+	}
+
+	return res
+}
+
+func checkVersion(p *CabalPackage) []*Diagnostic {
+	if p.Version == "" {
+		return nil
+	}
+
+	if _, err := ParseVersion(strings.TrimSpace(p.Version)); err != nil {
+		return []*Diagnostic{
+			newDiagnostic("invalid-version", SeverityError, "", "'%s' is not a valid package version", p.Version),
+		}
+	}
+
+	return nil
+}
+
+func checkSynopsis(p *CabalPackage) []*Diagnostic {
+	synopsis := strings.Join(p.Synopsis, " ")
+
+	switch {
+	case strings.TrimSpace(synopsis) == "":
+		return []*Diagnostic{
+			newDiagnostic("no-synopsis", SeverityWarning, "", "no 'synopsis' field"),
+		}
+	case len(synopsis) > maxSynopsisLength:
+		return []*Diagnostic{
+			newDiagnostic("long-synopsis", SeverityWarning, "",
+				"the 'synopsis' field is rather long (max %d chars is recommended)", maxSynopsisLength),
+		}
+	default:
+		return nil
+	}
+}
+
+func checkDescription(p *CabalPackage) []*Diagnostic {
+	description := strings.Join(p.Description, " ")
+
+	switch {
+	case strings.TrimSpace(description) == "":
+		return []*Diagnostic{
+			newDiagnostic("no-description", SeverityWarning, "", "no 'description' field"),
+		}
+	case len(description) < len(strings.Join(p.Synopsis, " ")):
+		return []*Diagnostic{
+			newDiagnostic("short-description", SeverityWarning, "",
+				"the 'description' field should be longer than the 'synopsis' field"),
+		}
+	default:
+		return nil
+	}
+}
+
+func checkLicense(p *CabalPackage) []*Diagnostic {
+	switch strings.ToLower(strings.TrimSpace(p.License)) {
+	case "", "none", "allrightsreserved":
+		return []*Diagnostic{
+			newDiagnostic("no-license", SeverityWarning, "",
+				"the 'license' field is missing or says 'NONE'"),
+		}
+	case "publicdomain", "unspecifiedlicense":
+		return nil
+	}
+
+	if p.LicenseFile == "" {
+		return []*Diagnostic{
+			newDiagnostic("no-license-file", SeverityWarning, "", "a 'license-file' is not specified"),
+		}
+	}
+
+	return nil
+}
+
+func checkMetadata(p *CabalPackage) []*Diagnostic {
+	res := make([]*Diagnostic, 0)
+
+	if p.Category == "" {
+		res = append(res, newDiagnostic("no-category", SeverityWarning, "", "no 'category' field"))
+	}
+
+	if p.Maintainer == "" {
+		res = append(res, newDiagnostic("no-maintainer", SeverityWarning, "", "no 'maintainer' field"))
+	}
+
+	return res
+}
+
+func checkBaseUpperBound(p *CabalPackage) []*Diagnostic {
+	res := make([]*Diagnostic, 0)
+
+	for _, c := range p.buildInfos() {
+		for _, d := range branchValues(c.info, func(b *BuildInfo) []*Dependency { return b.BuildDepends }) {
+			if d.Name == "base" && !d.VersionRange().HasUpperBound() {
+				res = append(res, newDiagnostic("missing-upper-bounds", SeverityWarning, c.name,
+					"the dependency 'build-depends: base' does not specify an upper bound on the version number"))
+			}
+		}
+	}
+
+	return res
+}
+
+func checkGHCOptions(p *CabalPackage) []*Diagnostic {
+	res := make([]*Diagnostic, 0)
+
+	for _, c := range p.buildInfos() {
+		for _, o := range branchValues(c.info, func(b *BuildInfo) []string { return b.GHCOptions }) {
+			switch {
+			case o == "-O2":
+				res = append(res, newDiagnostic("option-o2", SeverityWarning, c.name,
+					"'ghc-options: -O2' is rarely needed, check that it is giving a real benefit and not just imposing longer compile times on your users"))
+			case o == "-Werror" || strings.HasPrefix(o, "-Werror="):
+				res = append(res, newDiagnostic("werror", SeverityError, c.name,
+					"'ghc-options: %s' makes the package easy to break with future GHC versions", o))
+			case o == "-prof":
+				res = append(res, newDiagnostic("option-prof", SeverityError, c.name,
+					"'ghc-options: -prof' is not necessary and will lead to problems when used on a library"))
+			case o == "-fhpc":
+				res = append(res, newDiagnostic("option-fhpc", SeverityError, c.name,
+					"'ghc-options: -fhpc' is not necessary, use the configure flag --enable-coverage instead"))
+			}
+		}
+	}
+
+	return res
+}
+
+func checkSourceDirs(p *CabalPackage) []*Diagnostic {
+	res := make([]*Diagnostic, 0)
+
+	for _, c := range p.buildInfos() {
+		for _, dir := range branchValues(c.info, func(b *BuildInfo) []string { return b.HSSourceDirs }) {
+			switch {
+			case path.IsAbs(dir) || (len(dir) > 1 && dir[1] == ':'):
+				res = append(res, newDiagnostic("absolute-path", SeverityError, c.name,
+					"'hs-source-dirs: %s' is an absolute path", dir))
+			case path.Clean(dir) == ".." || strings.HasPrefix(path.Clean(dir), "../"):
+				res = append(res, newDiagnostic("relative-outside", SeverityError, c.name,
+					"'hs-source-dirs: %s' points outside the package directory", dir))
+			}
+		}
+	}
+
+	return res
+}
+
+func checkLanguage(p *CabalPackage) []*Diagnostic {
+	spec, err := p.SpecVersion()
+	if err != nil {
+		return []*Diagnostic{
+			newDiagnostic("invalid-cabal-version", SeverityError, "", "%v", err),
+		}
+	}
+
+	res := make([]*Diagnostic, 0)
+
+	for _, c := range p.buildInfos() {
+		extensions := branchValues(c.info, func(b *BuildInfo) []string { return b.Extensions })
+		if len(extensions) > 0 && spec.Compare(Version{1, 10}) >= 0 {
+			res = append(res, newDiagnostic("deprecated-extensions-field", SeverityWarning, c.name,
+				"the 'extensions' field is deprecated, use 'default-extensions' instead"))
+		}
+
+		languages := branchValues(c.info, func(b *BuildInfo) []Language {
+			if b.DefaultLanguage == "" {
+				return nil
+			}
+
+			return []Language{b.DefaultLanguage}
+		})
+
+		if len(languages) == 0 && spec.Compare(Version{1, 10}) >= 0 && spec.Compare(Version{3, 4}) < 0 {
+			res = append(res, newDiagnostic("no-default-language", SeverityWarning, c.name,
+				"packages using 'cabal-version: >= 1.10' and before 3.4 must specify the 'default-language' field"))
+		}
+	}
+
+	return res
+}
+
+func checkExtensions(p *CabalPackage) []*Diagnostic {
+	res := make([]*Diagnostic, 0)
+
+	for _, issue := range ValidateExtensions(p) {
+		msg := fmt.Sprintf("'%s: %s'", issue.Field, issue.Extension)
+		if issue.Replacement != "" {
+			msg += fmt.Sprintf(", use '%s' instead", issue.Replacement)
+		}
+
+		switch issue.Kind {
+		case ExtensionIssueDeprecated:
+			res = append(res, newDiagnostic("deprecated-extension", SeverityWarning, issue.Component,
+				"deprecated extension %s", msg))
+		default:
+			res = append(res, newDiagnostic("unknown-extension", SeverityError, issue.Component,
+				"unknown extension %s", msg))
+		}
+	}
+
+	return res
+}
+
+// checkReexports reports re-exports from packages the library doesn't
+// depend on. Re-exports and dependencies of conditional branches count.
+func checkReexports(p *CabalPackage) []*Diagnostic {
+	res := make([]*Diagnostic, 0)
+
+	check := func(component string, lib *Library) {
+		declared := make(map[string]struct{})
+
+		for _, d := range branchValues(&lib.BuildInfo, func(b *BuildInfo) []*Dependency { return b.BuildDepends }) {
+			name, _, _ := strings.Cut(d.Name, ":")
+			declared[name] = struct{}{}
+		}
+
+		reexports := append([]*ModuleReexport{}, lib.ReexportedModules...)
+		for _, b := range conditionalBranches(&lib.BuildInfo) {
+			reexports = append(reexports, b.ReexportedModules...)
+		}
+
+		for _, r := range reexports {
+			if r.OriginalPackage == "" {
+				continue
+			}
+
+			if _, ok := declared[r.OriginalPackage]; !ok {
+				res = append(res, newDiagnostic("undeclared-reexport", SeverityError, component,
+					"reexported module '%s' refers to undeclared package '%s'", r.NewName, r.OriginalPackage))
+			}
+		}
+	}
+
+	if p.Library != nil {
+		check("library", p.Library)
+	}
+
+	for _, name := range sortedKeys(p.SubLibraries) {
+		check("library "+name, p.SubLibraries[name])
+	}
+
+	return res
+}
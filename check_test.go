@@ -0,0 +1,206 @@
+package gocabalparser
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	f, err := os.Open("./testdata/4.cabal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	p, err := NewParser().ParseReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := Check(p); len(d) != 0 {
+		t.Fatalf("expected no diagnostics, got %v", d)
+	}
+}
+
+func TestCheck_rules(t *testing.T) {
+	p := &CabalPackage{
+		Name:         "foo",
+		Version:      "1.0-beta",
+		CabalVersion: "2.0",
+		License:      "BSD3",
+		Synopsis:     []string{"A synopsis that goes on and on for much longer than anybody would ever like to read"},
+		Description:  []string{"Short."},
+		Category:     "Data",
+		Maintainer:   "me@example.com",
+		Library: &Library{
+			BuildInfo: BuildInfo{
+				BuildDepends: []*Dependency{
+					{Name: "base", GreaterOrEqualThan: 4},
+					{Name: "containers", IsLatest: true},
+				},
+				DefaultLanguage: Haskell2010,
+				GHCOptions:      []string{"-Wall", "-O2", "-Werror"},
+				HSSourceDirs:    []string{"src", "/home/me/src", "../shared"},
+			},
+		},
+		Executables: map[string]*Executable{
+			"foo": {
+				BuildInfo: BuildInfo{
+					BuildDepends: []*Dependency{
+						{Name: "base", GreaterOrEqualThan: 4, LessThan: 5},
+					},
+					Extensions: []string{"Rank2Types"},
+				},
+			},
+		},
+	}
+
+	expected := []struct {
+		ruleID    string
+		severity  Severity
+		component string
+	}{
+		{"invalid-version", SeverityError, ""},
+		{"long-synopsis", SeverityWarning, ""},
+		{"short-description", SeverityWarning, ""},
+		{"no-license-file", SeverityWarning, ""},
+		{"missing-upper-bounds", SeverityWarning, "library"},
+		{"option-o2", SeverityWarning, "library"},
+		{"werror", SeverityError, "library"},
+		{"absolute-path", SeverityError, "library"},
+		{"relative-outside", SeverityError, "library"},
+		{"deprecated-extensions-field", SeverityWarning, "executable foo"},
+		{"no-default-language", SeverityWarning, "executable foo"},
+		{"deprecated-extension", SeverityWarning, "executable foo"},
+	}
+
+	actual := Check(p)
+
+	if len(actual) != len(expected) {
+		for _, d := range actual {
+			t.Logf("actual: %s", d)
+		}
+
+		t.Fatalf("expected %d diagnostics, got %d", len(expected), len(actual))
+	}
+
+	for i, e := range expected {
+		a := actual[i]
+
+		if a.RuleID != e.ruleID || a.Severity != e.severity || a.Component != e.component {
+			t.Fatalf("expected %s %s in '%s', got %s", e.severity, e.ruleID, e.component, a)
+		}
+	}
+}
+
+func TestCheck_missingFields(t *testing.T) {
+	expected := map[string]bool{
+		"no-name":        true,
+		"no-version":     true,
+		"no-synopsis":    true,
+		"no-description": true,
+		"no-license":     true,
+		"no-category":    true,
+		"no-maintainer":  true,
+	}
+
+	for _, d := range Check(&CabalPackage{}) {
+		if !expected[d.RuleID] {
+			t.Fatalf("unexpected diagnostic: %s", d)
+		}
+
+		delete(expected, d.RuleID)
+	}
+
+	if len(expected) != 0 {
+		t.Fatalf("missing diagnostics: %v", expected)
+	}
+}
+
+func TestCheck_reexports(t *testing.T) {
+	p, err := NewParser().ParseReader(strings.NewReader(`name: foo
+version: 1.0
+library
+  build-depends: base
+  reexported-modules: containers:Data.Map as Map, text:Data.Text, Data.List
+  if flag(text)
+    build-depends: text
+  else
+    reexported-modules: unix:System.Posix
+library internal
+  reexported-modules: bytestring:Data.ByteString
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"error [undeclared-reexport]: library: reexported module 'Map' refers to undeclared package 'containers'",
+		"error [undeclared-reexport]: library: reexported module 'System.Posix' refers to undeclared package 'unix'",
+		"error [undeclared-reexport]: library internal: reexported module 'Data.ByteString' refers to undeclared package 'bytestring'",
+	}
+
+	actual := make([]string, 0)
+
+	for _, d := range checkReexports(p) {
+		actual = append(actual, d.String())
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestCheck_conditionals(t *testing.T) {
+	p, err := NewParser().ParseReader(strings.NewReader(`cabal-version: 2.0
+name: foo
+version: 1.0
+synopsis: Conditionals
+description: Rules look inside conditional branches.
+license: BSD3
+license-file: LICENSE
+category: Data
+maintainer: me@example.com
+flag dev
+  default: False
+library
+  build-depends: base >= 4 && < 5
+  if flag(dev)
+    ghc-options: -Werror
+  else
+    if impl(ghc < 8)
+      build-depends: base >= 3
+      hs-source-dirs: ../compat
+  if os(windows)
+    extensions: CPP
+    default-language: Haskell2010
+executable foo
+  main-is: Main.hs
+  build-depends: base < 5
+  if !flag(dev)
+    default-language: Haskell2010
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"warning [missing-upper-bounds]: library: the dependency 'build-depends: base' does not specify an upper bound on the version number",
+		"error [werror]: library: 'ghc-options: -Werror' makes the package easy to break with future GHC versions",
+		"error [relative-outside]: library: 'hs-source-dirs: ../compat' points outside the package directory",
+		"warning [deprecated-extensions-field]: library: the 'extensions' field is deprecated, use 'default-extensions' instead",
+	}
+
+	actual := make([]string, 0)
+
+	for _, d := range Check(p) {
+		actual = append(actual, d.String())
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+}
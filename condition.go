@@ -0,0 +1,206 @@
+package gocabalparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Platform is the configuration conditionals are resolved against.
+type Platform struct {
+	OS              string
+	Arch            string
+	Compiler        string
+	CompilerVersion Version
+	Flags           map[string]bool
+}
+
+var osAliases = map[string]string{
+	"mingw32":  "windows",
+	"win32":    "windows",
+	"cygwin32": "windows",
+	"darwin":   "osx",
+}
+
+var archAliases = map[string]string{
+	"amd64": "x86_64",
+	"x64":   "x86_64",
+	"i686":  "i386",
+	"arm64": "aarch64",
+}
+
+// EvalCondition evaluates a cabal condition such as
+// "os(windows) && !flag(dev)". Flags missing from the platform are false;
+// flag, os and arch names are compared case-insensitively with aliases
+// such as mingw32 for windows resolved.
+func (p *Platform) EvalCondition(s string) (bool, error) {
+	c := &conditionParser{platform: p, s: s}
+
+	res, err := c.parseOr()
+	if err != nil {
+		return false, err
+	}
+
+	if c.skipSpaces(); c.i < len(c.s) {
+		return false, fmt.Errorf("unexpected %q in condition: %s", c.s[c.i:], s)
+	}
+
+	return res, nil
+}
+
+type conditionParser struct {
+	platform *Platform
+	s        string
+	i        int
+}
+
+func (c *conditionParser) skipSpaces() {
+	for c.i < len(c.s) && isSpace(c.s[c.i]) {
+		c.i++
+	}
+}
+
+func (c *conditionParser) consume(token string) bool {
+	c.skipSpaces()
+
+	if strings.HasPrefix(c.s[c.i:], token) {
+		c.i += len(token)
+
+		return true
+	}
+
+	return false
+}
+
+func (c *conditionParser) parseOr() (bool, error) {
+	res, err := c.parseAnd()
+	if err != nil {
+		return false, err
+	}
+
+	for c.consume("||") {
+		right, err := c.parseAnd()
+		if err != nil {
+			return false, err
+		}
+
+		res = res || right
+	}
+
+	return res, nil
+}
+
+func (c *conditionParser) parseAnd() (bool, error) {
+	res, err := c.parseNot()
+	if err != nil {
+		return false, err
+	}
+
+	for c.consume("&&") {
+		right, err := c.parseNot()
+		if err != nil {
+			return false, err
+		}
+
+		res = res && right
+	}
+
+	return res, nil
+}
+
+func (c *conditionParser) parseNot() (bool, error) {
+	if c.consume("!") {
+		res, err := c.parseNot()
+
+		return !res, err
+	}
+
+	return c.parseAtom()
+}
+
+func (c *conditionParser) parseAtom() (bool, error) {
+	if c.consume("(") {
+		res, err := c.parseOr()
+		if err != nil {
+			return false, err
+		}
+
+		if !c.consume(")") {
+			return false, fmt.Errorf("closing parenthesis expected in condition: %s", c.s)
+		}
+
+		return res, nil
+	}
+
+	c.skipSpaces()
+
+	start := c.i
+	for c.i < len(c.s) && isPackageNameChar(c.s[c.i]) {
+		c.i++
+	}
+
+	name := c.s[start:c.i]
+
+	switch strings.ToLower(name) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "":
+		return false, fmt.Errorf("condition expected: %s", c.s)
+	}
+
+	if !c.consume("(") {
+		return false, fmt.Errorf("'(' expected after %s in condition: %s", name, c.s)
+	}
+
+	end := strings.IndexByte(c.s[c.i:], ')')
+	if end < 0 {
+		return false, fmt.Errorf("closing parenthesis expected in condition: %s", c.s)
+	}
+
+	arg := strings.TrimSpace(c.s[c.i : c.i+end])
+	c.i += end + 1
+
+	return c.platform.test(name, arg)
+}
+
+func (p *Platform) test(name, arg string) (bool, error) {
+	switch name {
+	case "os":
+		return canonicalPlatformName(p.OS, osAliases) == canonicalPlatformName(arg, osAliases), nil
+	case "arch":
+		return canonicalPlatformName(p.Arch, archAliases) == canonicalPlatformName(arg, archAliases), nil
+	case "flag":
+		for flag, value := range p.Flags {
+			if strings.EqualFold(flag, arg) {
+				return value, nil
+			}
+		}
+
+		return false, nil
+	case "impl":
+		nameEnd := packageNameEnd(arg)
+		r := &VersionRange{Kind: VersionRangeAny}
+
+		if constraint := strings.TrimSpace(arg[nameEnd:]); constraint != "" {
+			var err error
+
+			if r, err = ParseVersionRange(constraint); err != nil {
+				return false, err
+			}
+		}
+
+		return strings.EqualFold(arg[:nameEnd], p.Compiler) && r.Contains(p.CompilerVersion), nil
+	default:
+		return false, fmt.Errorf("unknown condition: %s", name)
+	}
+}
+
+func canonicalPlatformName(s string, aliases map[string]string) string {
+	s = strings.ToLower(s)
+	if alias, ok := aliases[s]; ok {
+		return alias
+	}
+
+	return s
+}
@@ -0,0 +1,58 @@
+package gocabalparser
+
+import "testing"
+
+func TestPlatform_EvalCondition(t *testing.T) {
+	p := &Platform{
+		OS:              "Linux",
+		Arch:            "amd64",
+		Compiler:        "ghc",
+		CompilerVersion: Version{9, 6, 4},
+		Flags:           map[string]bool{"Dev": true},
+	}
+
+	tcs := []struct {
+		condition string
+		expected  bool
+	}{
+		{"true", true},
+		{"False", false},
+		{"os(linux)", true},
+		{"os(windows)", false},
+		{"!os(windows)", true},
+		{"arch(x86_64)", true},
+		{"flag(dev)", true},
+		{"flag(missing)", false},
+		{"impl(ghc)", true},
+		{"impl(ghc >= 9.6)", true},
+		{"impl(ghc < 9.4)", false},
+		{"impl(ghcjs)", false},
+		{"os(windows) || flag(dev) && !arch(i386)", true},
+		{"(os(windows) || flag(dev)) && arch(i386)", false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.condition, func(t *testing.T) {
+			actual, err := p.EvalCondition(tc.condition)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPlatform_EvalCondition_errors(t *testing.T) {
+	p := &Platform{}
+
+	for _, condition := range []string{"", "os(linux) &&", "(true", "os linux", "os(linux", "cpu(x86)", "impl(ghc >=)", "true false"} {
+		t.Run(condition, func(t *testing.T) {
+			if _, err := p.EvalCondition(condition); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package gocabalparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ConstraintScopeKind int
+
+const (
+	// ConstraintScopeTopLevel is an unqualified constraint, e.g. aeson,
+	// applying to the package as a dependency of the project.
+	ConstraintScopeTopLevel ConstraintScopeKind = iota
+	// ConstraintScopeAny applies everywhere, e.g. any.aeson.
+	ConstraintScopeAny
+	// ConstraintScopeAnySetup applies to setup dependencies of all
+	// packages, e.g. setup.Cabal.
+	ConstraintScopeAnySetup
+	// ConstraintScopeSetup applies to setup dependencies of Package,
+	// e.g. foo:setup.Cabal.
+	ConstraintScopeSetup
+	// ConstraintScopeExe applies to dependencies of the build tool
+	// Executable of Package, e.g. foo:happy:exe.happy.
+	ConstraintScopeExe
+)
+
+// ConstraintScope qualifies the package a constraint applies to.
+type ConstraintScope struct {
+	Kind       ConstraintScopeKind
+	Package    string
+	Executable string
+}
+
+type PackageConstraintKind int
+
+const (
+	// PackageConstraintVersion restricts versions to Range.
+	PackageConstraintVersion PackageConstraintKind = iota
+	// PackageConstraintInstalled requires the installed instance.
+	PackageConstraintInstalled
+	// PackageConstraintSource requires building from source.
+	PackageConstraintSource
+	// PackageConstraintFlags sets Flags.
+	PackageConstraintFlags
+	// PackageConstraintStanzas enables the test or bench Stanzas.
+	PackageConstraintStanzas
+)
+
+// FlagAssignment is a flag setting such as +dev or -dev.
+type FlagAssignment struct {
+	Name  string
+	Value bool
+}
+
+// PackageConstraint is an entry of the constraints field of cabal.project
+// and freeze files, e.g. any.aeson ==2.1.2.1 or aeson +ordered-keymap.
+type PackageConstraint struct {
+	Scope   ConstraintScope
+	Package string
+	Kind    PackageConstraintKind
+	Range   *VersionRange
+	Flags   []FlagAssignment
+	Stanzas []string
+}
+
+// ParsePackageConstraint parses a single constraint.
+func ParsePackageConstraint(s string) (*PackageConstraint, error) {
+	s = strings.TrimSpace(s)
+
+	end := strings.IndexAny(s, " \t\r\n<>=^(")
+	if end < 0 {
+		end = len(s)
+	}
+
+	res := &PackageConstraint{}
+
+	if err := res.parseQualifiedName(s[:end]); err != nil {
+		return nil, err
+	}
+
+	property := strings.TrimSpace(s[end:])
+	words := strings.Fields(property)
+
+	switch {
+	case property == "":
+		return nil, fmt.Errorf("constraint expected after %s", s)
+	case property == "installed":
+		res.Kind = PackageConstraintInstalled
+	case property == "source":
+		res.Kind = PackageConstraintSource
+	case (property[0] == '+' || property[0] == '-') && words[0] != "-any" && words[0] != "-none":
+		res.Kind = PackageConstraintFlags
+
+		for _, w := range words {
+			if len(w) < 2 || (w[0] != '+' && w[0] != '-') || !isFlagName(w[1:]) {
+				return nil, fmt.Errorf("flag assignment expected: %s", w)
+			}
+
+			res.Flags = append(res.Flags, FlagAssignment{Name: w[1:], Value: w[0] == '+'})
+		}
+	case words[0] == "test" || words[0] == "bench":
+		res.Kind = PackageConstraintStanzas
+
+		for _, w := range words {
+			if w != "test" && w != "bench" {
+				return nil, fmt.Errorf("test or bench expected: %s", w)
+			}
+
+			res.Stanzas = append(res.Stanzas, w)
+		}
+	default:
+		r, err := ParseVersionRange(property)
+		if err != nil {
+			return nil, fmt.Errorf("constraint %s: %v", s, err)
+		}
+
+		res.Range = r
+	}
+
+	return res, nil
+}
+
+// parseQualifiedName parses forms such as aeson, any.aeson, setup.Cabal,
+// foo:setup.Cabal and foo:happy:exe.happy.
+func (c *PackageConstraint) parseQualifiedName(s string) error {
+	parts := strings.Split(s, ":")
+	last := parts[len(parts)-1]
+
+	qualifier, name := "", last
+	if i := strings.IndexByte(last, '.'); i >= 0 {
+		qualifier, name = last[:i], last[i+1:]
+	}
+
+	switch {
+	case len(parts) == 1 && qualifier == "":
+		c.Scope = ConstraintScope{Kind: ConstraintScopeTopLevel}
+	case len(parts) == 1 && qualifier == "any":
+		c.Scope = ConstraintScope{Kind: ConstraintScopeAny}
+	case len(parts) == 1 && qualifier == "setup":
+		c.Scope = ConstraintScope{Kind: ConstraintScopeAnySetup}
+	case len(parts) == 2 && qualifier == "setup":
+		c.Scope = ConstraintScope{Kind: ConstraintScopeSetup, Package: parts[0]}
+	case len(parts) == 3 && qualifier == "exe":
+		c.Scope = ConstraintScope{Kind: ConstraintScopeExe, Package: parts[0], Executable: parts[1]}
+	default:
+		return fmt.Errorf("invalid constraint qualifier: %s", s)
+	}
+
+	for _, n := range []string{name, c.Scope.Package, c.Scope.Executable} {
+		if n != "" && !isPackageName(n) {
+			return fmt.Errorf("invalid package name in constraint: %s", s)
+		}
+	}
+
+	if name == "" || (c.Scope.Kind >= ConstraintScopeSetup && c.Scope.Package == "") {
+		return fmt.Errorf("package name expected in constraint: %s", s)
+	}
+
+	c.Package = name
+
+	return nil
+}
+
+// PinnedVersion returns the version of a constraint fixing a single
+// version, such as the == constraints of freeze files.
+func (c *PackageConstraint) PinnedVersion() (Version, bool) {
+	if c.Kind != PackageConstraintVersion || c.Range.Kind != VersionRangeThis {
+		return nil, false
+	}
+
+	return c.Range.Version, true
+}
+
+func (s ConstraintScope) String() string {
+	switch s.Kind {
+	case ConstraintScopeAny:
+		return "any."
+	case ConstraintScopeAnySetup:
+		return "setup."
+	case ConstraintScopeSetup:
+		return s.Package + ":setup."
+	case ConstraintScopeExe:
+		return s.Package + ":" + s.Executable + ":exe."
+	default:
+		return ""
+	}
+}
+
+func (c *PackageConstraint) String() string {
+	res := c.Scope.String() + c.Package
+
+	switch c.Kind {
+	case PackageConstraintInstalled:
+		return res + " installed"
+	case PackageConstraintSource:
+		return res + " source"
+	case PackageConstraintFlags:
+		for _, f := range c.Flags {
+			if f.Value {
+				res += " +" + f.Name
+			} else {
+				res += " -" + f.Name
+			}
+		}
+
+		return res
+	case PackageConstraintStanzas:
+		return res + " " + strings.Join(c.Stanzas, " ")
+	default:
+		return res + " " + c.Range.String()
+	}
+}
+
+// PackageConstraints parses the constraints of the project.
+func (p *Project) PackageConstraints() ([]*PackageConstraint, error) {
+	res := make([]*PackageConstraint, 0, len(p.Constraints))
+
+	for _, s := range p.Constraints {
+		c, err := ParsePackageConstraint(s)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, c)
+	}
+
+	return res, nil
+}
+
+func isPackageName(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if !isPackageNameChar(s[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isFlagName(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isPackageNameChar(s[i]) && s[i] != '_' {
+			return false
+		}
+	}
+
+	return s != ""
+}
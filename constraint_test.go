@@ -0,0 +1,176 @@
+package gocabalparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePackageConstraint(t *testing.T) {
+	tcs := []struct {
+		input    string
+		expected *PackageConstraint
+		str      string
+	}{
+		{
+			input: "any.aeson ==2.1.2.1",
+			expected: &PackageConstraint{
+				Scope:   ConstraintScope{Kind: ConstraintScopeAny},
+				Package: "aeson",
+				Range:   mustParseVersionRange("== 2.1.2.1"),
+			},
+			str: "any.aeson == 2.1.2.1",
+		},
+		{
+			input: "any.base installed",
+			expected: &PackageConstraint{
+				Scope:   ConstraintScope{Kind: ConstraintScopeAny},
+				Package: "base",
+				Kind:    PackageConstraintInstalled,
+			},
+		},
+		{
+			input: "aeson +ordered-keymap -cffi",
+			expected: &PackageConstraint{
+				Package: "aeson",
+				Kind:    PackageConstraintFlags,
+				Flags:   []FlagAssignment{{Name: "ordered-keymap", Value: true}, {Name: "cffi"}},
+			},
+		},
+		{
+			input: "text>=2 && <2.1",
+			expected: &PackageConstraint{
+				Package: "text",
+				Range:   mustParseVersionRange(">= 2 && < 2.1"),
+			},
+			str: "text >= 2 && < 2.1",
+		},
+		{
+			input: "bytestring -any",
+			expected: &PackageConstraint{
+				Package: "bytestring",
+				Range:   &VersionRange{Kind: VersionRangeAny},
+			},
+		},
+		{
+			input: "setup.Cabal source",
+			expected: &PackageConstraint{
+				Scope:   ConstraintScope{Kind: ConstraintScopeAnySetup},
+				Package: "Cabal",
+				Kind:    PackageConstraintSource,
+			},
+		},
+		{
+			input: "foo:setup.Cabal ^>=3.10",
+			expected: &PackageConstraint{
+				Scope:   ConstraintScope{Kind: ConstraintScopeSetup, Package: "foo"},
+				Package: "Cabal",
+				Range:   mustParseVersionRange("^>= 3.10"),
+			},
+			str: "foo:setup.Cabal ^>= 3.10",
+		},
+		{
+			input: "foo:happy:exe.happy <2",
+			expected: &PackageConstraint{
+				Scope:   ConstraintScope{Kind: ConstraintScopeExe, Package: "foo", Executable: "happy"},
+				Package: "happy",
+				Range:   mustParseVersionRange("< 2"),
+			},
+			str: "foo:happy:exe.happy < 2",
+		},
+		{
+			input: "lens test bench",
+			expected: &PackageConstraint{
+				Package: "lens",
+				Kind:    PackageConstraintStanzas,
+				Stanzas: []string{"test", "bench"},
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.input, func(t *testing.T) {
+			actual, err := ParsePackageConstraint(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Fatalf("expected %+v, got %+v", tc.expected, actual)
+			}
+
+			str := tc.str
+			if str == "" {
+				str = tc.input
+			}
+
+			if actual.String() != str {
+				t.Fatalf("expected %q, got %q", str, actual.String())
+			}
+		})
+	}
+}
+
+func TestParsePackageConstraint_errors(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"aeson",
+		"any.aeson",
+		"all.aeson == 1",
+		"foo:any.aeson == 1",
+		"any.aeson_x == 1",
+		"aeson +",
+		"aeson +a b",
+		"aeson test unit",
+		"aeson >=",
+		":setup.Cabal == 3",
+	} {
+		t.Run(input, func(t *testing.T) {
+			if _, err := ParsePackageConstraint(input); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestProject_PackageConstraints_freeze(t *testing.T) {
+	input := `active-repositories: hackage.haskell.org:merge
+constraints: any.aeson ==2.1.2.1,
+             aeson +ordered-keymap,
+             any.base ==4.18.0.0,
+             any.ghc-prim installed,
+             any.text ==2.0.2
+index-state: hackage.haskell.org 2024-01-15T10:00:00Z
+`
+
+	project, err := NewProjectParser().ParseReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	constraints, err := project.PackageConstraints()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pinned := make(map[string]string)
+
+	for _, c := range constraints {
+		if v, ok := c.PinnedVersion(); ok {
+			pinned[c.Package] = v.String()
+		}
+	}
+
+	expected := map[string]string{"aeson": "2.1.2.1", "base": "4.18.0.0", "text": "2.0.2"}
+	if !reflect.DeepEqual(pinned, expected) {
+		t.Fatalf("expected %v, got %v", expected, pinned)
+	}
+
+	if s := project.Settings["index-state"]; s != "hackage.haskell.org 2024-01-15T10:00:00Z" {
+		t.Fatalf("unexpected index-state: %s", s)
+	}
+
+	if _, err := (&Project{Constraints: []string{"any.aeson"}}).PackageConstraints(); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -14,88 +14,133 @@ func newDependenciesParser() *dependenciesParser {
 }
 
 func (p *dependenciesParser) ParseString(s string) (*Dependency, error) {
-	chunks := strings.Split(s, " ")
-
-	if len(chunks) == 0 {
+	s = strings.TrimSpace(s)
+	if s == "" {
 		return nil, errors.New("empty dependency")
 	}
 
-	var (
-		sign string
-		gt   float64
-		gte  float64
-		lt   float64
-		lte  float64
-	)
+	nameEnd := packageNameEnd(s)
+	if nameEnd == 0 {
+		return nil, fmt.Errorf("package name expected: %s", s)
+	}
 
-	for _, v := range chunks[1:] {
-		if v == "&&" || v == " " || v == "\t" || v == "" {
-			continue
+	r := &VersionRange{Kind: VersionRangeAny}
+
+	if constraint := strings.TrimSpace(s[nameEnd:]); constraint != "" {
+		var err error
+
+		if r, err = ParseVersionRange(constraint); err != nil {
+			return nil, err
 		}
+	}
+
+	return newDependency(s[:nameEnd], r), nil
+}
 
-		if sign == "" {
-			switch v {
-			case ">", ">=", "<", "<=":
-				sign = v
-			default:
-				return nil, fmt.Errorf("unexpected token: %s", v)
+// newDependency builds a dependency on the range, filling in the float
+// bounds when the range is a plain conjunction of bounds. Of several lower
+// or upper bounds the tightest one is kept.
+func newDependency(name string, r *VersionRange) *Dependency {
+	var lower, upper *VersionRange
+
+	for _, b := range intersectedBounds(r) {
+		switch b.Kind {
+		case VersionRangeLater, VersionRangeOrLater:
+			if lower == nil || tighterBound(b, lower, 1) {
+				lower = b
+			}
+		case VersionRangeEarlier, VersionRangeOrEarlier:
+			if upper == nil || tighterBound(b, upper, -1) {
+				upper = b
 			}
+		}
+	}
 
+	res := &Dependency{Name: name, IsLatest: r.Kind == VersionRangeAny, Range: r}
+
+	for _, b := range []*VersionRange{lower, upper} {
+		if b == nil || len(b.Version) > 2 {
 			continue
 		}
 
-		switch sign {
-		case ">":
-			v, err := parseFloat64(v)
-			if err != nil {
-				return nil, fmt.Errorf("expected valid float: %v", err)
-			}
+		v, err := parseFloat64(b.Version.String())
+		if err != nil {
+			continue
+		}
 
-			gt = v
-		case ">=":
-			v, err := parseFloat64(v)
-			if err != nil {
-				return nil, fmt.Errorf("expected valid float: %v", err)
-			}
+		switch b.Kind {
+		case VersionRangeLater:
+			res.GreaterThan = v
+		case VersionRangeOrLater:
+			res.GreaterOrEqualThan = v
+		case VersionRangeEarlier:
+			res.LessThan = v
+		case VersionRangeOrEarlier:
+			res.LessOrEqualThan = v
+		}
+	}
 
-			gte = v
-		case "<":
-			v, err := parseFloat64(v)
-			if err != nil {
-				return nil, fmt.Errorf("expected valid float: %v", err)
-			}
+	return res
+}
 
-			lt = v
-		case "<=":
-			v, err := parseFloat64(v)
-			if err != nil {
-				return nil, fmt.Errorf("expected valid float: %v", err)
-			}
+// tighterBound reports whether the bound b excludes more versions than
+// current, where direction is 1 for lower bounds and -1 for upper ones.
+func tighterBound(b, current *VersionRange, direction int) bool {
+	if c := b.Version.Compare(current.Version) * direction; c != 0 {
+		return c > 0
+	}
+
+	return b.Kind == VersionRangeLater || b.Kind == VersionRangeEarlier
+}
 
-			lte = v
-		default:
-			return nil, fmt.Errorf("unexpected sign: %s", sign)
+// intersectedBounds returns the operands of a range made of intersections
+// only, or nothing for other ranges.
+func intersectedBounds(r *VersionRange) []*VersionRange {
+	switch r.Kind {
+	case VersionRangeIntersection:
+		left, right := intersectedBounds(r.Left), intersectedBounds(r.Right)
+		if left == nil || right == nil {
+			return nil
 		}
 
-		sign = ""
+		return append(left, right...)
+	case VersionRangeLater, VersionRangeOrLater, VersionRangeEarlier, VersionRangeOrEarlier:
+		return []*VersionRange{r}
+	default:
+		return nil
+	}
+}
+
+// packageNameEnd returns the length of the package name at the start of a
+// dependency, including a sub-library qualifier such as :sub or :{a, b}.
+func packageNameEnd(s string) int {
+	i := 0
+	for i < len(s) && isPackageNameChar(s[i]) {
+		i++
+	}
+
+	if i == 0 || i == len(s) || s[i] != ':' {
+		return i
 	}
 
-	if gt > 0 && gte > 0 {
-		return nil, fmt.Errorf("gt and gte simultaneous declaration")
+	if j := i + 1; j < len(s) && s[j] == '{' {
+		if k := strings.IndexByte(s[j:], '}'); k >= 0 {
+			return j + k + 1
+		}
+
+		return i
 	}
 
-	if lt > 0 && lte > 0 {
-		return nil, fmt.Errorf("gt and gte simultaneous declaration")
+	j := i + 1
+	for j < len(s) && isPackageNameChar(s[j]) {
+		j++
 	}
 
-	return &Dependency{
-		Name:               chunks[0],
-		IsLatest:           gt == 0 && gte == 0 && lt == 0 && lte == 0,
-		GreaterThan:        gt,
-		GreaterOrEqualThan: gte,
-		LessThan:           lt,
-		LessOrEqualThan:    lte,
-	}, nil
+	return j
+}
+
+func isPackageNameChar(c byte) bool {
+	return c == '-' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
 }
 
 func parseFloat64(f string) (float64, error) {
@@ -17,6 +17,34 @@ func TestDependenciesParser_ParseString(t *testing.T) {
 			expected: &Dependency{
 				Name:     "base",
 				IsLatest: true,
+				Range:    &VersionRange{},
+			},
+		},
+		{
+			name:             "tighter lower bound",
+			dependencyString: "foo > 1 && >= 1.2",
+			expected: &Dependency{
+				Name:               "foo",
+				GreaterOrEqualThan: 1.2,
+				Range:              mustParseVersionRange("> 1 && >= 1.2"),
+			},
+		},
+		{
+			name:             "tighter upper bound",
+			dependencyString: "foo < 2 && <= 1.5",
+			expected: &Dependency{
+				Name:            "foo",
+				LessOrEqualThan: 1.5,
+				Range:           mustParseVersionRange("< 2 && <= 1.5"),
+			},
+		},
+		{
+			name:             "strict bound on the same version",
+			dependencyString: "foo >= 1 && > 1",
+			expected: &Dependency{
+				Name:        "foo",
+				GreaterThan: 1,
+				Range:       mustParseVersionRange(">= 1 && > 1"),
 			},
 		},
 		{
@@ -26,6 +54,7 @@ func TestDependenciesParser_ParseString(t *testing.T) {
 				Name:        "base",
 				IsLatest:    false,
 				GreaterThan: 1.0,
+				Range:       mustParseVersionRange("> 1.0"),
 			},
 		},
 		{
@@ -35,6 +64,7 @@ func TestDependenciesParser_ParseString(t *testing.T) {
 				Name:     "base",
 				IsLatest: false,
 				LessThan: 1.0,
+				Range:    mustParseVersionRange("< 1.0"),
 			},
 		},
 		{
@@ -44,6 +74,7 @@ func TestDependenciesParser_ParseString(t *testing.T) {
 				Name:               "base",
 				IsLatest:           false,
 				GreaterOrEqualThan: 1.0,
+				Range:              mustParseVersionRange(">= 1.0"),
 			},
 		},
 		{
@@ -53,6 +84,7 @@ func TestDependenciesParser_ParseString(t *testing.T) {
 				Name:            "base",
 				IsLatest:        false,
 				LessOrEqualThan: 1.0,
+				Range:           mustParseVersionRange("<= 1.0"),
 			},
 		},
 		{
@@ -63,6 +95,7 @@ func TestDependenciesParser_ParseString(t *testing.T) {
 				IsLatest:    false,
 				GreaterThan: 1.0,
 				LessThan:    2.0,
+				Range:       mustParseVersionRange("> 1.0 && < 2.0"),
 			},
 		},
 		{
@@ -73,6 +106,7 @@ func TestDependenciesParser_ParseString(t *testing.T) {
 				IsLatest:           false,
 				GreaterOrEqualThan: 1.0,
 				LessThan:           2.0,
+				Range:              mustParseVersionRange(">= 1.0 && < 2.0"),
 			},
 		},
 		{
@@ -83,6 +117,7 @@ func TestDependenciesParser_ParseString(t *testing.T) {
 				IsLatest:        false,
 				GreaterThan:     1.0,
 				LessOrEqualThan: 2.0,
+				Range:           mustParseVersionRange("> 1.0 && <= 2.0"),
 			},
 		},
 		{
@@ -93,6 +128,33 @@ func TestDependenciesParser_ParseString(t *testing.T) {
 				IsLatest:           false,
 				GreaterOrEqualThan: 1.0,
 				LessOrEqualThan:    2.0,
+				Range:              mustParseVersionRange(">= 1.0 && <= 2.0"),
+			},
+		},
+		{
+			name:             "precise versions",
+			dependencyString: "base >= 4.10.1 && < 4.20",
+			expected: &Dependency{
+				Name:     "base",
+				LessThan: 4.2,
+				Range:    mustParseVersionRange(">= 4.10.1 && < 4.20"),
+			},
+		},
+		{
+			name:             "major bound",
+			dependencyString: "text ^>= 2.0",
+			expected: &Dependency{
+				Name:  "text",
+				Range: mustParseVersionRange("^>= 2.0"),
+			},
+		},
+		{
+			name:             "sub-libraries without spaces",
+			dependencyString: "foo:{bar, baz}>=1",
+			expected: &Dependency{
+				Name:               "foo:{bar, baz}",
+				GreaterOrEqualThan: 1,
+				Range:              mustParseVersionRange(">= 1"),
 			},
 		},
 	}
@@ -111,3 +173,13 @@ func TestDependenciesParser_ParseString(t *testing.T) {
 		})
 	}
 }
+
+func TestDependenciesParser_ParseString_errors(t *testing.T) {
+	for _, s := range []string{"", ">= 1", "base >= x"} {
+		t.Run(s, func(t *testing.T) {
+			if _, err := newDependenciesParser().ParseString(s); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package gocabalparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// knownExtensions is the catalogue of language extensions accepted by GHC.
+var knownExtensions = map[string]struct{}{
+	"AllowAmbiguousTypes":               {},
+	"AlternativeLayoutRule":             {},
+	"AlternativeLayoutRuleTransitional": {},
+	"ApplicativeDo":                     {},
+	"Arrows":                            {},
+	"AutoDeriveTypeable":                {},
+	"BangPatterns":                      {},
+	"BinaryLiterals":                    {},
+	"BlockArguments":                    {},
+	"CApiFFI":                           {},
+	"CPP":                               {},
+	"CUSKs":                             {},
+	"ConstrainedClassMethods":           {},
+	"ConstraintKinds":                   {},
+	"DataKinds":                         {},
+	"DatatypeContexts":                  {},
+	"DeepSubsumption":                   {},
+	"DefaultSignatures":                 {},
+	"DeriveAnyClass":                    {},
+	"DeriveDataTypeable":                {},
+	"DeriveFoldable":                    {},
+	"DeriveFunctor":                     {},
+	"DeriveGeneric":                     {},
+	"DeriveLift":                        {},
+	"DeriveTraversable":                 {},
+	"DerivingStrategies":                {},
+	"DerivingVia":                       {},
+	"DisambiguateRecordFields":          {},
+	"DoAndIfThenElse":                   {},
+	"DoRec":                             {},
+	"DuplicateRecordFields":             {},
+	"EmptyCase":                         {},
+	"EmptyDataDecls":                    {},
+	"EmptyDataDeriving":                 {},
+	"ExistentialQuantification":         {},
+	"ExplicitForAll":                    {},
+	"ExplicitNamespaces":                {},
+	"ExtendedDefaultRules":              {},
+	"ExtendedLiterals":                  {},
+	"FieldSelectors":                    {},
+	"FlexibleContexts":                  {},
+	"FlexibleInstances":                 {},
+	"ForeignFunctionInterface":          {},
+	"FunctionalDependencies":            {},
+	"GADTSyntax":                        {},
+	"GADTs":                             {},
+	"GHCForeignImportPrim":              {},
+	"GeneralisedNewtypeDeriving":        {},
+	"GeneralizedNewtypeDeriving":        {},
+	"HexFloatLiterals":                  {},
+	"ImplicitParams":                    {},
+	"ImplicitPrelude":                   {},
+	"ImportQualifiedPost":               {},
+	"ImpredicativeTypes":                {},
+	"IncoherentInstances":               {},
+	"InstanceSigs":                      {},
+	"InterruptibleFFI":                  {},
+	"JavaScriptFFI":                     {},
+	"KindSignatures":                    {},
+	"LambdaCase":                        {},
+	"LexicalNegation":                   {},
+	"LiberalTypeSynonyms":               {},
+	"LinearTypes":                       {},
+	"ListTuplePuns":                     {},
+	"MagicHash":                         {},
+	"MonadComprehensions":               {},
+	"MonoLocalBinds":                    {},
+	"MonomorphismRestriction":           {},
+	"MultiParamTypeClasses":             {},
+	"MultiWayIf":                        {},
+	"MultilineStrings":                  {},
+	"NPlusKPatterns":                    {},
+	"NamedDefaults":                     {},
+	"NamedFieldPuns":                    {},
+	"NamedWildCards":                    {},
+	"NegativeLiterals":                  {},
+	"NondecreasingIndentation":          {},
+	"NullaryTypeClasses":                {},
+	"NumDecimals":                       {},
+	"NumericUnderscores":                {},
+	"OrPatterns":                        {},
+	"OverlappingInstances":              {},
+	"OverloadedLabels":                  {},
+	"OverloadedLists":                   {},
+	"OverloadedRecordDot":               {},
+	"OverloadedRecordUpdate":            {},
+	"OverloadedStrings":                 {},
+	"PackageImports":                    {},
+	"ParallelArrays":                    {},
+	"ParallelListComp":                  {},
+	"PartialTypeSignatures":             {},
+	"PatternGuards":                     {},
+	"PatternSignatures":                 {},
+	"PatternSynonyms":                   {},
+	"PolyKinds":                         {},
+	"PolymorphicComponents":             {},
+	"PostfixOperators":                  {},
+	"QualifiedDo":                       {},
+	"QuantifiedConstraints":             {},
+	"QuasiQuotes":                       {},
+	"Rank2Types":                        {},
+	"RankNTypes":                        {},
+	"RebindableSyntax":                  {},
+	"RecordPuns":                        {},
+	"RecordWildCards":                   {},
+	"RecursiveDo":                       {},
+	"RelaxedLayout":                     {},
+	"RelaxedPolyRec":                    {},
+	"RequiredTypeArguments":             {},
+	"RoleAnnotations":                   {},
+	"Safe":                              {},
+	"ScopedTypeVariables":               {},
+	"StandaloneDeriving":                {},
+	"StandaloneKindSignatures":          {},
+	"StarIsType":                        {},
+	"StaticPointers":                    {},
+	"Strict":                            {},
+	"StrictData":                        {},
+	"TemplateHaskell":                   {},
+	"TemplateHaskellQuotes":             {},
+	"TraditionalRecordSyntax":           {},
+	"TransformListComp":                 {},
+	"Trustworthy":                       {},
+	"TupleSections":                     {},
+	"TypeAbstractions":                  {},
+	"TypeApplications":                  {},
+	"TypeData":                          {},
+	"TypeFamilies":                      {},
+	"TypeFamilyDependencies":            {},
+	"TypeInType":                        {},
+	"TypeOperators":                     {},
+	"TypeSynonymInstances":              {},
+	"UnboxedSums":                       {},
+	"UnboxedTuples":                     {},
+	"UndecidableInstances":              {},
+	"UndecidableSuperClasses":           {},
+	"UnicodeSyntax":                     {},
+	"UnliftedDatatypes":                 {},
+	"UnliftedFFITypes":                  {},
+	"UnliftedNewtypes":                  {},
+	"Unsafe":                            {},
+	"ViewPatterns":                      {},
+}
+
+// deprecatedExtensions maps deprecated extensions to their suggested
+// replacements. An empty replacement means the extension should be dropped.
+var deprecatedExtensions = map[string]string{
+	"AutoDeriveTypeable":    "",
+	"CUSKs":                 "StandaloneKindSignatures",
+	"DatatypeContexts":      "",
+	"DoRec":                 "RecursiveDo",
+	"NullaryTypeClasses":    "MultiParamTypeClasses",
+	"OverlappingInstances":  "",
+	"PatternSignatures":     "ScopedTypeVariables",
+	"PolymorphicComponents": "RankNTypes",
+	"Rank2Types":            "RankNTypes",
+	"RecordPuns":            "NamedFieldPuns",
+	"TypeInType":            "DataKinds, PolyKinds",
+}
+
+type ExtensionIssueKind int
+
+const (
+	ExtensionIssueUnknown ExtensionIssueKind = iota
+	ExtensionIssueDeprecated
+)
+
+func (k ExtensionIssueKind) String() string {
+	switch k {
+	case ExtensionIssueUnknown:
+		return "unknown"
+	case ExtensionIssueDeprecated:
+		return "deprecated"
+	default:
+		return fmt.Sprintf("unknown issue kind: %d", k)
+	}
+}
+
+// ExtensionIssue is an unknown or deprecated entry of an extensions field.
+// Replacement holds the suggested extension, if there is one.
+type ExtensionIssue struct {
+	Component   string
+	Field       string
+	Extension   string
+	Kind        ExtensionIssueKind
+	Replacement string
+}
+
+func (i *ExtensionIssue) String() string {
+	msg := fmt.Sprintf("%s: %s: %s extension '%s'", i.Component, i.Field, i.Kind, i.Extension)
+	if i.Replacement != "" {
+		msg += fmt.Sprintf(", use '%s' instead", i.Replacement)
+	}
+
+	return msg
+}
+
+// IsKnownExtension reports whether name, optionally prefixed with No,
+// is a language extension known to GHC.
+func IsKnownExtension(name string) bool {
+	_, ok := lookupExtension(name)
+
+	return ok
+}
+
+// ValidateExtensions checks extensions, default-extensions and
+// other-extensions of every component and its conditional branches
+// against the known extensions.
+func ValidateExtensions(p *CabalPackage) []*ExtensionIssue {
+	issues := make([]*ExtensionIssue, 0)
+
+	for _, c := range p.buildInfos() {
+		fields := []struct {
+			name       string
+			extensions []string
+		}{
+			{"extensions", branchValues(c.info, func(b *BuildInfo) []string { return b.Extensions })},
+			{"default-extensions", branchValues(c.info, func(b *BuildInfo) []string { return b.DefaultExtensions })},
+			{"other-extensions", branchValues(c.info, func(b *BuildInfo) []string { return b.OtherExtensions })},
+		}
+
+		for _, f := range fields {
+			for _, e := range f.extensions {
+				if issue := validateExtension(e); issue != nil {
+					issue.Component = c.name
+					issue.Field = f.name
+					issues = append(issues, issue)
+				}
+			}
+		}
+	}
+
+	return issues
+}
+
+func validateExtension(name string) *ExtensionIssue {
+	ext, ok := lookupExtension(name)
+	if !ok {
+		return &ExtensionIssue{
+			Extension:   name,
+			Kind:        ExtensionIssueUnknown,
+			Replacement: suggestExtension(name),
+		}
+	}
+
+	replacement, deprecated := deprecatedExtensions[ext]
+	if !deprecated {
+		return nil
+	}
+
+	if replacement != "" && ext != name {
+		replacement = "No" + strings.ReplaceAll(replacement, ", ", ", No")
+	}
+
+	return &ExtensionIssue{
+		Extension:   name,
+		Kind:        ExtensionIssueDeprecated,
+		Replacement: replacement,
+	}
+}
+
+// lookupExtension returns the catalogue name of the extension, without
+// the No prefix of negated extensions.
+func lookupExtension(name string) (string, bool) {
+	if _, ok := knownExtensions[name]; ok {
+		return name, true
+	}
+
+	if positive := strings.TrimPrefix(name, "No"); positive != name {
+		if _, ok := knownExtensions[positive]; ok {
+			return positive, true
+		}
+	}
+
+	return "", false
+}
+
+// suggestExtension returns the known extension closest to name, or an
+// empty string when nothing is similar enough.
+func suggestExtension(name string) string {
+	prefix := ""
+	positive := name
+
+	if strings.HasPrefix(name, "No") {
+		prefix = "No"
+		positive = name[2:]
+	}
+
+	best := ""
+	bestDistance := len(positive)/3 + 1
+
+	for known := range knownExtensions {
+		d := editDistance(strings.ToLower(positive), strings.ToLower(known))
+		if d < bestDistance || (d == bestDistance && best != "" && known < best) {
+			best = known
+			bestDistance = d
+		}
+	}
+
+	if best == "" {
+		return ""
+	}
+
+	return prefix + best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = minInt(prev[j]+1, minInt(curr[j-1]+1, prev[j-1]+cost))
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,121 @@
+package gocabalparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsKnownExtension(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected bool
+	}{
+		{name: "FlexibleContexts", expected: true},
+		{name: "NoImplicitPrelude", expected: true},
+		{name: "NondecreasingIndentation", expected: true},
+		{name: "NoNondecreasingIndentation", expected: true},
+		{name: "Rank2Types", expected: true},
+		{name: "FlexibleContext", expected: false},
+		{name: "No", expected: false},
+		{name: "Haskell2010", expected: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := IsKnownExtension(tc.name); actual != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestValidateExtensions(t *testing.T) {
+	p := &CabalPackage{
+		Library: &Library{
+			BuildInfo: BuildInfo{
+				DefaultExtensions: []string{"OverloadedStrings", "Rank2Types", "NoPatternSignatures"},
+				OtherExtensions:   []string{"TypeInType", "DatatypeContexts"},
+				Conditionals: []*Conditional{{
+					Condition: "impl(ghc < 8)",
+					Then: ConditionalBranch{
+						BuildInfo: BuildInfo{OtherExtensions: []string{"DoRec"}},
+					},
+				}},
+			},
+		},
+		Executables: map[string]*Executable{
+			"app": {
+				BuildInfo: BuildInfo{
+					Extensions: []string{"FlexibleContext", "lambdacase", "Frobnicate"},
+				},
+			},
+		},
+	}
+
+	expected := []*ExtensionIssue{
+		{
+			Component:   "library",
+			Field:       "default-extensions",
+			Extension:   "Rank2Types",
+			Kind:        ExtensionIssueDeprecated,
+			Replacement: "RankNTypes",
+		},
+		{
+			Component:   "library",
+			Field:       "default-extensions",
+			Extension:   "NoPatternSignatures",
+			Kind:        ExtensionIssueDeprecated,
+			Replacement: "NoScopedTypeVariables",
+		},
+		{
+			Component:   "library",
+			Field:       "other-extensions",
+			Extension:   "TypeInType",
+			Kind:        ExtensionIssueDeprecated,
+			Replacement: "DataKinds, PolyKinds",
+		},
+		{
+			Component: "library",
+			Field:     "other-extensions",
+			Extension: "DatatypeContexts",
+			Kind:      ExtensionIssueDeprecated,
+		},
+		{
+			Component:   "library",
+			Field:       "other-extensions",
+			Extension:   "DoRec",
+			Kind:        ExtensionIssueDeprecated,
+			Replacement: "RecursiveDo",
+		},
+		{
+			Component:   "executable app",
+			Field:       "extensions",
+			Extension:   "FlexibleContext",
+			Kind:        ExtensionIssueUnknown,
+			Replacement: "FlexibleContexts",
+		},
+		{
+			Component:   "executable app",
+			Field:       "extensions",
+			Extension:   "lambdacase",
+			Kind:        ExtensionIssueUnknown,
+			Replacement: "LambdaCase",
+		},
+		{
+			Component: "executable app",
+			Field:     "extensions",
+			Extension: "Frobnicate",
+			Kind:      ExtensionIssueUnknown,
+		},
+	}
+
+	actual := ValidateExtensions(p)
+
+	if !reflect.DeepEqual(actual, expected) {
+		for _, i := range actual {
+			t.Logf("actual: %s", i)
+		}
+
+		t.FailNow()
+	}
+}
@@ -0,0 +1,484 @@
+package gocabalparser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+const formatPragmaPrefix = "-- cabal-fmt:"
+
+// moduleExtensions are the source file extensions recognised when module
+// lists are expanded from a source directory.
+var moduleExtensions = []string{".hs", ".lhs", ".hsig", ".lhsig", ".hsc", ".chs", ".x", ".y"}
+
+var sortedModuleFields = map[string]struct{}{
+	"exposed-modules": {},
+	"other-modules":   {},
+	"autogen-modules": {},
+	"signatures":      {},
+}
+
+type Formatter interface {
+	Format(w io.Writer, r io.Reader) error
+}
+
+type formatter struct {
+	fsys fs.FS
+}
+
+// NewFormatter returns a formatter resolving directories of formatter
+// pragmas against fsys, the package root. fsys may be nil when no pragmas
+// need the file system.
+func NewFormatter(fsys fs.FS) Formatter {
+	return &formatter{fsys: fsys}
+}
+
+// formatPragma is a "-- cabal-fmt: expand <dir> [-Module ...]" comment
+// which regenerates the module list of the following field.
+type formatPragma struct {
+	dir     string
+	exclude map[ModuleName]struct{}
+}
+
+// formatItem is a list element with the comments written above it.
+type formatItem struct {
+	text     string
+	comments []string
+}
+
+// Format reformats a .cabal file: fields and sections are indented by two
+// spaces per level, field values are aligned within each block,
+// build-depends and module lists are sorted and deduplicated, dependencies
+// on the same package being merged, and blank lines are collapsed with a
+// single one before every section. Comments are kept.
+func (f *formatter) Format(w io.Writer, r io.Reader) error {
+	tree, err := ParseSyntaxTree(r)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+
+	if err := f.formatNodes(bw, tree.Nodes, ""); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+func (f *formatter) formatNodes(w *bufio.Writer, nodes []*SyntaxNode, indent string) error {
+	width := 0
+
+	for _, n := range nodes {
+		if n.Kind == SyntaxField && len(n.Name) > width {
+			width = len(n.Name)
+		}
+	}
+
+	var pragma *formatPragma
+
+	emitted, blank, afterSection := false, false, false
+
+	for i, n := range nodes {
+		if n.Kind == SyntaxBlank {
+			blank = emitted
+
+			continue
+		}
+
+		if emitted && (blank || afterSection || startsSectionGroup(nodes, i)) {
+			w.WriteString("\n")
+		}
+
+		emitted, blank, afterSection = true, false, n.Kind == SyntaxSection
+
+		switch n.Kind {
+		case SyntaxComment:
+			comment := strings.TrimSpace(n.Lines[0])
+
+			p, err := parseFormatPragma(comment)
+			if err != nil {
+				return err
+			}
+
+			if p != nil {
+				pragma = p
+			}
+
+			fmt.Fprintf(w, "%s%s\n", indent, comment)
+		case SyntaxField:
+			field, err := f.formatField(n, pragma)
+			if err != nil {
+				return err
+			}
+
+			writeField(w, indent, width, field)
+			pragma = nil
+		case SyntaxSection:
+			header := strings.ToLower(n.Name)
+			if args := strings.Join(strings.Fields(n.Args), " "); args != "" {
+				header += " " + args
+			}
+
+			fmt.Fprintf(w, "%s%s\n", indent, header)
+
+			if err := f.formatNodes(w, n.Children, indent+stanzaIndent); err != nil {
+				return err
+			}
+
+			pragma = nil
+		}
+	}
+
+	return nil
+}
+
+// startsSectionGroup reports whether the node is a section or the first of
+// the comments directly above one.
+func startsSectionGroup(nodes []*SyntaxNode, i int) bool {
+	if i > 0 && nodes[i-1].Kind == SyntaxComment {
+		return false
+	}
+
+	for ; i < len(nodes); i++ {
+		if nodes[i].Kind != SyntaxComment {
+			return nodes[i].Kind == SyntaxSection
+		}
+	}
+
+	return false
+}
+
+func (f *formatter) formatField(n *SyntaxNode, pragma *formatPragma) (printField, error) {
+	name := strings.ToLower(n.Name)
+	_, isModuleField := sortedModuleFields[name]
+
+	if pragma != nil && !isModuleField {
+		return printField{}, fmt.Errorf("cabal-fmt: expand is not supported for %s", name)
+	}
+
+	switch {
+	case name == "build-depends":
+		items, err := formatListItems(n, splitCommaList, true)
+		if err != nil {
+			return printField{}, err
+		}
+
+		for _, item := range items {
+			item.text = strings.Join(strings.Fields(item.text), " ")
+		}
+
+		if items, err = mergeDependencyItems(items); err != nil {
+			return printField{}, err
+		}
+
+		items = sortFormatItems(items, func(text string) string {
+			return strings.ToLower(text[:dependencyNameEnd(text)])
+		})
+
+		return formatItemsField(name, items, true), nil
+	case isModuleField:
+		items, err := formatListItems(n, splitOptCommaList, false)
+		if err != nil {
+			return printField{}, err
+		}
+
+		if pragma != nil {
+			if items, err = f.expandModuleItems(pragma, items); err != nil {
+				return printField{}, err
+			}
+		}
+
+		items = sortFormatItems(items, func(text string) string { return text })
+
+		return formatItemsField(name, items, false), nil
+	default:
+		return formatTextField(name, n), nil
+	}
+}
+
+// formatListItems splits the field value into items and attaches comment
+// lines to the item following them. When the items can't be located in
+// the source lines, all comments go above the first item.
+func formatListItems(n *SyntaxNode, split listSplitter, commaMode bool) ([]*formatItem, error) {
+	texts, err := split(strings.Join(n.Value(), "\n"))
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*formatItem, len(texts))
+	for i, text := range texts {
+		items[i] = &formatItem{text: text}
+	}
+
+	located := parseFieldList(n, commaMode).items
+	pending := make([]string, 0)
+	next := 0
+
+	for i, l := range n.Lines {
+		if i > 0 && isCommentLine(l) {
+			pending = append(pending, strings.TrimSpace(l))
+
+			continue
+		}
+
+		for next < len(located) && located[next].line == i {
+			if len(pending) > 0 && len(located) == len(items) {
+				items[next].comments = pending
+				pending = make([]string, 0)
+			}
+
+			next++
+		}
+	}
+
+	if len(pending) > 0 && len(items) > 0 {
+		items[0].comments = append(pending, items[0].comments...)
+	}
+
+	return items, nil
+}
+
+// sortFormatItems sorts items by key, keeping the original order of equal
+// keys, and drops repeated items merging their comments.
+func sortFormatItems(items []*formatItem, key func(string) string) []*formatItem {
+	sort.SliceStable(items, func(i, j int) bool {
+		return key(items[i].text) < key(items[j].text)
+	})
+
+	res := make([]*formatItem, 0, len(items))
+	seen := make(map[string]*formatItem)
+
+	for _, item := range items {
+		if prev, ok := seen[item.text]; ok {
+			prev.comments = append(prev.comments, item.comments...)
+
+			continue
+		}
+
+		seen[item.text] = item
+		res = append(res, item)
+	}
+
+	return res
+}
+
+// mergeDependencyItems merges dependencies on the same package into the
+// first of them, intersecting their version ranges as cabal does, e.g.
+// base >= 4, base < 5 becomes base >= 4 && < 5.
+func mergeDependencyItems(items []*formatItem) ([]*formatItem, error) {
+	p := newDependenciesParser()
+	res := make([]*formatItem, 0, len(items))
+	seen := make(map[string]*formatItem)
+	ranges := make(map[string]*VersionRange)
+
+	for _, item := range items {
+		d, err := p.ParseString(item.text)
+		if err != nil {
+			return nil, err
+		}
+
+		prev, ok := seen[d.Name]
+		if !ok {
+			seen[d.Name], ranges[d.Name] = item, d.Range
+			res = append(res, item)
+
+			continue
+		}
+
+		if r := intersectVersionRanges(ranges[d.Name], d.Range); r != ranges[d.Name] {
+			ranges[d.Name] = r
+			prev.text = newDependency(d.Name, r).String()
+		}
+
+		prev.comments = append(prev.comments, item.comments...)
+	}
+
+	return res, nil
+}
+
+func intersectVersionRanges(a, b *VersionRange) *VersionRange {
+	switch {
+	case b.Kind == VersionRangeAny || a.String() == b.String():
+		return a
+	case a.Kind == VersionRangeAny:
+		return b
+	default:
+		return &VersionRange{Kind: VersionRangeIntersection, Left: a, Right: b}
+	}
+}
+
+func formatItemsField(name string, items []*formatItem, commaList bool) printField {
+	field := printField{name: name, commaList: commaList}
+
+	for _, item := range items {
+		field.lines = append(field.lines, item.text)
+		field.comments = append(field.comments, item.comments)
+	}
+
+	if len(field.lines) == 0 {
+		field.lines = []string{""}
+	}
+
+	return field
+}
+
+// formatTextField keeps the lines of a free form value, re-indenting them
+// while preserving indentation relative to the least indented line.
+func formatTextField(name string, n *SyntaxNode) printField {
+	header := strings.TrimSpace(n.Lines[0][strings.IndexByte(n.Lines[0], ':')+1:])
+	field := printField{name: name, lines: []string{header}, comments: [][]string{nil}}
+
+	common := -1
+
+	for _, l := range n.Lines[1:] {
+		if strings.TrimSpace(l) != "" && !isCommentLine(l) && (common < 0 || lineIndent(l) < common) {
+			common = lineIndent(l)
+		}
+	}
+
+	pending := make([]string, 0)
+
+	for _, l := range n.Lines[1:] {
+		switch {
+		case isCommentLine(l):
+			pending = append(pending, strings.TrimSpace(l))
+		case strings.TrimSpace(l) == "":
+			if field.lines[len(field.lines)-1] != "" {
+				field.lines = append(field.lines, "")
+				field.comments = append(field.comments, nil)
+			}
+		default:
+			field.lines = append(field.lines, strings.TrimRight(l[common:], " \t\r\n"))
+			field.comments = append(field.comments, pending)
+			pending = make([]string, 0)
+		}
+	}
+
+	return field
+}
+
+func parseFormatPragma(comment string) (*formatPragma, error) {
+	if !strings.HasPrefix(comment, formatPragmaPrefix) {
+		return nil, nil
+	}
+
+	words := strings.Fields(strings.TrimPrefix(comment, formatPragmaPrefix))
+	if len(words) == 0 {
+		return nil, fmt.Errorf("cabal-fmt pragma expected")
+	}
+
+	if words[0] != "expand" {
+		return nil, fmt.Errorf("unknown cabal-fmt pragma: %s", words[0])
+	}
+
+	if len(words) < 2 {
+		return nil, fmt.Errorf("cabal-fmt: expand directory expected")
+	}
+
+	p := &formatPragma{dir: path.Clean(words[1]), exclude: make(map[ModuleName]struct{})}
+
+	for _, w := range words[2:] {
+		m, err := ParseModuleName(strings.TrimPrefix(w, "-"))
+		if err != nil || !strings.HasPrefix(w, "-") {
+			return nil, fmt.Errorf("cabal-fmt: excluded module expected: %s", w)
+		}
+
+		p.exclude[m] = struct{}{}
+	}
+
+	return p, nil
+}
+
+// expandModuleItems replaces the items by the modules found in the pragma
+// directory, keeping comments of the modules which were already listed.
+func (f *formatter) expandModuleItems(p *formatPragma, items []*formatItem) ([]*formatItem, error) {
+	modules, err := expandModules(f.fsys, p)
+	if err != nil {
+		return nil, err
+	}
+
+	comments := make(map[string][]string)
+	for _, item := range items {
+		comments[item.text] = item.comments
+	}
+
+	res := make([]*formatItem, len(modules))
+	for i, m := range modules {
+		res[i] = &formatItem{text: string(m), comments: comments[string(m)]}
+	}
+
+	return res, nil
+}
+
+func expandModules(fsys fs.FS, p *formatPragma) ([]ModuleName, error) {
+	if fsys == nil {
+		return nil, fmt.Errorf("cabal-fmt: expand %s: no file system", p.dir)
+	}
+
+	modules, err := sourceModules(fsys, p.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]ModuleName, 0, len(modules))
+
+	for _, m := range modules {
+		if _, ok := p.exclude[m]; !ok {
+			res = append(res, m)
+		}
+	}
+
+	return res, nil
+}
+
+// sourceModules returns the sorted modules of the Haskell sources found
+// under dir, skipping files whose path is not a valid module name.
+func sourceModules(fsys fs.FS, dir string) ([]ModuleName, error) {
+	dir = path.Clean(dir)
+	res := make([]ModuleName, 0)
+
+	err := fs.WalkDir(fsys, dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		ext := path.Ext(name)
+		if !isModuleExtension(ext) {
+			return nil
+		}
+
+		rel := strings.TrimSuffix(name, ext)
+		if dir != "." {
+			rel = strings.TrimPrefix(rel, dir+"/")
+		}
+
+		if m, err := ParseModuleName(strings.ReplaceAll(rel, "/", ".")); err == nil {
+			res = append(res, m)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+
+	return res, nil
+}
+
+func isModuleExtension(ext string) bool {
+	for _, e := range moduleExtensions {
+		if ext == e {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,204 @@
+package gocabalparser
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFormatter_Format(t *testing.T) {
+	f, err := os.Open("./testdata/6.cabal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	expected := `cabal-version: 2.4
+-- A package with comments, odd casing and layout.
+name:          commented
+version:       0.1.0.0
+
+synopsis:      A commented package
+description:
+               First paragraph.
+
+               Second paragraph.
+
+-- The main library.
+library
+  exposed-modules: Commented
+                   -- internal modules follow
+                   Commented.Internal
+
+  build-depends:   base >= 4 && < 5
+                   -- containers is optional
+                 , containers
+
+-- trailing comment inside the library
+
+executable commented-app
+  main-is:        Main.hs
+  build-depends:  base
+                , commented
+  hs-source-dirs: app
+
+-- final comment
+`
+
+	var buf bytes.Buffer
+
+	if err := NewFormatter(nil).Format(&buf, f); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+}
+
+func TestFormatter_Format_sortAndDedupe(t *testing.T) {
+	input := "Name: foo\nLibrary\n      Build-Depends: text, base >=4,\n        containers,   text\n      Exposed-Modules: Foo.B, Foo.A Foo.B\n\n\n      GHC-Options: -Wall\n         -O2\n"
+	expected := `name: foo
+
+library
+  build-depends:   base >=4
+                 , containers
+                 , text
+  exposed-modules: Foo.A
+                   Foo.B
+
+  ghc-options:     -Wall
+                   -O2
+`
+
+	var buf bytes.Buffer
+
+	if err := NewFormatter(nil).Format(&buf, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+}
+
+func TestFormatter_Format_mergeDependencies(t *testing.T) {
+	input := `library
+  build-depends: base >= 4, text, base,
+                 -- upper bound
+                 base < 5,
+                 containers ^>= 0.6 || ^>= 0.7, containers < 0.7, text
+`
+	expected := `library
+  -- upper bound
+  build-depends: base >= 4 && < 5
+               , containers (^>= 0.6 || ^>= 0.7) && < 0.7
+               , text
+`
+
+	var buf bytes.Buffer
+
+	if err := NewFormatter(nil).Format(&buf, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+}
+
+func TestFormatter_Format_expandPragma(t *testing.T) {
+	fsys := fstest.MapFS{
+		"src/Foo.hs":          {},
+		"src/Foo/Internal.hs": {},
+		"src/Foo/Parser.y":    {},
+		"src/Foo/Types.hs":    {},
+		"src/Main.hs":         {},
+		"src/Foo.hs-boot":     {},
+		"src/notes.txt":       {},
+		"src/not-a-module.hs": {},
+	}
+
+	input := `library
+  hs-source-dirs: src
+  -- cabal-fmt: expand src -Main
+  exposed-modules:
+    Foo
+    -- to be replaced
+    Foo.Old
+    Foo.Types
+`
+	expected := `library
+  hs-source-dirs:  src
+  -- cabal-fmt: expand src -Main
+  exposed-modules: Foo
+                   Foo.Internal
+                   Foo.Parser
+                   Foo.Types
+`
+
+	var buf bytes.Buffer
+
+	if err := NewFormatter(fsys).Format(&buf, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+}
+
+func TestFormatter_Format_errors(t *testing.T) {
+	tcs := []struct {
+		name  string
+		input string
+	}{
+		{"unknown pragma", "-- cabal-fmt: shrink\nname: foo\n"},
+		{"no directory", "library\n  -- cabal-fmt: expand\n  exposed-modules: Foo\n"},
+		{"bad exclusion", "library\n  -- cabal-fmt: expand src Main\n  exposed-modules: Foo\n"},
+		{"not a module field", "library\n  -- cabal-fmt: expand src\n  build-depends: base\n"},
+		{"no file system", "library\n  -- cabal-fmt: expand src\n  exposed-modules: Foo\n"},
+		{"invalid dependency", "library\n  build-depends: base >=\n"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := NewFormatter(nil).Format(&bytes.Buffer{}, strings.NewReader(tc.input)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestFormatter_Format_idempotent(t *testing.T) {
+	for _, name := range []string{"1.cabal", "2.cabal", "3.cabal", "4.cabal", "5.cabal", "6.cabal", "7.cabal", "8.cabal", "9.cabal", "10.cabal", "11.cabal"} {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(fmt.Sprintf("./testdata/%s", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var once, twice bytes.Buffer
+
+			if err := NewFormatter(nil).Format(&once, bytes.NewReader(data)); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := NewParser().ParseReader(bytes.NewReader(once.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := NewFormatter(nil).Format(&twice, bytes.NewReader(once.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+
+			if once.String() != twice.String() {
+				t.Fatalf("formatting is not idempotent:\n%s\n%s", once.String(), twice.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package gocabalparser
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// FileGlob is a restricted cabal glob as used by data-files,
+// extra-source-files and extra-doc-files, e.g. data/**/*.json.
+type FileGlob struct {
+	Pattern string
+
+	dir       string
+	recursive bool
+	file      string
+	multiExt  bool
+}
+
+// ParseFileGlob parses and validates a glob according to the rules of the
+// given cabal specification version:
+//   - wildcards are only allowed in the file name part, either as "*.ext"
+//     or, from 2.4, as a bare "*";
+//   - from 2.4 a single "**" directory matches any subdirectory and
+//     "*.ext" matches files with several extensions such as foo.en.ext.
+func ParseFileGlob(pattern string, spec Version) (*FileGlob, error) {
+	if pattern == "" {
+		return nil, errors.New("empty glob")
+	}
+
+	if path.IsAbs(pattern) {
+		return nil, fmt.Errorf("glob '%s' must be a relative path", pattern)
+	}
+
+	spec24 := spec.Compare(Version{2, 4}) >= 0
+
+	g := &FileGlob{
+		Pattern:  pattern,
+		multiExt: spec24,
+	}
+
+	dirs := strings.Split(pattern, "/")
+	g.file = dirs[len(dirs)-1]
+	dirs = dirs[:len(dirs)-1]
+
+	for i, d := range dirs {
+		switch {
+		case d == "**":
+			if !spec24 {
+				return nil, fmt.Errorf("glob '%s': '**' requires cabal-version 2.4 or later", pattern)
+			}
+
+			if i != len(dirs)-1 {
+				return nil, fmt.Errorf("glob '%s': '**' must be followed by the file name", pattern)
+			}
+
+			g.recursive = true
+		case strings.ContainsAny(d, "*"):
+			return nil, fmt.Errorf("glob '%s': wildcards are not allowed in directory names", pattern)
+		case d == "" || d == "." || d == "..":
+			return nil, fmt.Errorf("glob '%s': invalid directory '%s'", pattern, d)
+		default:
+			g.dir = path.Join(g.dir, d)
+		}
+	}
+
+	if !strings.ContainsRune(g.file, '*') {
+		if g.recursive {
+			return nil, fmt.Errorf("glob '%s': '**' must be followed by a wildcard file name", pattern)
+		}
+
+		return g, nil
+	}
+
+	switch {
+	case g.file == "*":
+		if !spec24 {
+			return nil, fmt.Errorf("glob '%s': '*' without extension requires cabal-version 2.4 or later", pattern)
+		}
+	case strings.HasPrefix(g.file, "*.") && !strings.ContainsRune(g.file[1:], '*'):
+	default:
+		return nil, fmt.Errorf("glob '%s': wildcard must replace the whole file name, as in '*.ext'", pattern)
+	}
+
+	return g, nil
+}
+
+// IsLiteral reports whether the glob names a single file.
+func (g *FileGlob) IsLiteral() bool {
+	return !strings.ContainsRune(g.file, '*')
+}
+
+// Match reports whether the slash separated path matches the glob.
+func (g *FileGlob) Match(name string) bool {
+	if g.IsLiteral() {
+		return name == path.Join(g.dir, g.file)
+	}
+
+	dir, file := path.Split(name)
+	dir = strings.TrimSuffix(dir, "/")
+
+	if g.recursive {
+		if g.dir != "" && dir != g.dir && !strings.HasPrefix(dir, g.dir+"/") {
+			return false
+		}
+	} else if dir != g.dir {
+		return false
+	}
+
+	return g.matchFile(file)
+}
+
+func (g *FileGlob) matchFile(file string) bool {
+	if g.file == "*" {
+		return true
+	}
+
+	ext := g.file[1:]
+
+	if g.multiExt {
+		return strings.HasSuffix(file, ext) && len(file) > len(ext)
+	}
+
+	i := strings.IndexByte(file, '.')
+
+	return i > 0 && file[i:] == ext
+}
+
+// Expand returns the sorted list of files in fsys matching the glob.
+func (g *FileGlob) Expand(fsys fs.FS) ([]string, error) {
+	if g.IsLiteral() {
+		name := path.Join(g.dir, g.file)
+
+		if _, err := fs.Stat(fsys, name); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return []string{}, nil
+			}
+
+			return nil, err
+		}
+
+		return []string{name}, nil
+	}
+
+	root := g.dir
+	if root == "" {
+		root = "."
+	}
+
+	res := make([]string, 0)
+
+	err := fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if name == root && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipDir
+			}
+
+			return err
+		}
+
+		if d.IsDir() {
+			if name != root && !g.recursive {
+				return fs.SkipDir
+			}
+
+			return nil
+		}
+
+		if g.Match(name) {
+			res = append(res, name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(res)
+
+	return res, nil
+}
+
+// UnmatchedGlob is a glob that matched no files during expansion.
+type UnmatchedGlob struct {
+	Field   string
+	Pattern string
+}
+
+// PackageFiles is the result of expanding the file globs of a package.
+// Paths are relative to the package directory; data files include the
+// data-dir prefix.
+type PackageFiles struct {
+	DataFiles        []string
+	ExtraSourceFiles []string
+	ExtraDocFiles    []string
+	Unmatched        []*UnmatchedGlob
+}
+
+// ExpandPackageFiles expands data-files, extra-source-files and
+// extra-doc-files of the package against fsys, which must be rooted at the
+// package directory. Globs are validated against the package cabal-version.
+func ExpandPackageFiles(fsys fs.FS, p *CabalPackage) (*PackageFiles, error) {
+	spec, err := p.SpecVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &PackageFiles{}
+
+	dataFS := fsys
+	if p.DataDir != "" {
+		if dataFS, err = fs.Sub(fsys, path.Clean(p.DataDir)); err != nil {
+			return nil, err
+		}
+	}
+
+	fields := []struct {
+		name   string
+		fsys   fs.FS
+		prefix string
+		globs  []string
+		to     *[]string
+	}{
+		{"data-files", dataFS, p.DataDir, p.DataFiles, &res.DataFiles},
+		{"extra-source-files", fsys, "", p.ExtraSourceFiles, &res.ExtraSourceFiles},
+		{"extra-doc-files", fsys, "", p.ExtraDocFiles, &res.ExtraDocFiles},
+	}
+
+	for _, f := range fields {
+		for _, pattern := range f.globs {
+			g, err := ParseFileGlob(pattern, spec)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", f.name, err)
+			}
+
+			files, err := g.Expand(f.fsys)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(files) == 0 {
+				res.Unmatched = append(res.Unmatched, &UnmatchedGlob{Field: f.name, Pattern: pattern})
+			}
+
+			for _, file := range files {
+				*f.to = appendUnique(*f.to, path.Join(f.prefix, file))
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func appendUnique(to []string, s string) []string {
+	for _, v := range to {
+		if v == s {
+			return to
+		}
+	}
+
+	return append(to, s)
+}
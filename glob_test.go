@@ -0,0 +1,146 @@
+package gocabalparser
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func testPackageFS() fstest.MapFS {
+	return fstest.MapFS{
+		"README.md":                 {},
+		"CHANGELOG.md":              {},
+		"cbits/foo.h":               {},
+		"data/a.json":               {},
+		"data/b.en.json":            {},
+		"data/nested/c.json":        {},
+		"data/nested/deep/d.json":   {},
+		"data/nested/deep/e.txt":    {},
+		"share/templates/page.html": {},
+	}
+}
+
+func TestParseFileGlob_errors(t *testing.T) {
+	cases := []struct {
+		pattern string
+		spec    Version
+	}{
+		{pattern: "data/**/*.json", spec: Version{2, 2}},
+		{pattern: "data/*", spec: Version{1, 8}},
+		{pattern: "data/*/a.json", spec: Version{3, 0}},
+		{pattern: "data/**/nested/*.json", spec: Version{3, 0}},
+		{pattern: "data/**/a.json", spec: Version{3, 0}},
+		{pattern: "data/foo*.json", spec: Version{3, 0}},
+		{pattern: "/etc/*.conf", spec: Version{3, 0}},
+		{pattern: "../*.md", spec: Version{3, 0}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			if _, err := ParseFileGlob(tc.pattern, tc.spec); err == nil {
+				t.Fatalf("expected error for '%s' with cabal-version %s", tc.pattern, tc.spec)
+			}
+		})
+	}
+}
+
+func TestFileGlob_Expand(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		spec     Version
+		expected []string
+	}{
+		{
+			pattern:  "*.md",
+			spec:     Version{1, 8},
+			expected: []string{"CHANGELOG.md", "README.md"},
+		},
+		{
+			pattern:  "data/*.json",
+			spec:     Version{1, 8},
+			expected: []string{"data/a.json"},
+		},
+		{
+			pattern:  "data/*.json",
+			spec:     Version{2, 4},
+			expected: []string{"data/a.json", "data/b.en.json"},
+		},
+		{
+			pattern:  "data/**/*.json",
+			spec:     Version{2, 4},
+			expected: []string{"data/a.json", "data/b.en.json", "data/nested/c.json", "data/nested/deep/d.json"},
+		},
+		{
+			pattern:  "data/nested/**/*",
+			spec:     Version{3, 0},
+			expected: []string{"data/nested/c.json", "data/nested/deep/d.json", "data/nested/deep/e.txt"},
+		},
+		{
+			pattern:  "cbits/foo.h",
+			spec:     Version{1, 8},
+			expected: []string{"cbits/foo.h"},
+		},
+		{
+			pattern:  "cbits/bar.h",
+			spec:     Version{1, 8},
+			expected: []string{},
+		},
+		{
+			pattern:  "missing/*.txt",
+			spec:     Version{1, 8},
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.pattern+" "+tc.spec.String(), func(t *testing.T) {
+			g, err := ParseFileGlob(tc.pattern, tc.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			actual, err := g.Expand(testPackageFS())
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Fatalf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExpandPackageFiles(t *testing.T) {
+	p := &CabalPackage{
+		CabalVersion:     "2.4",
+		DataDir:          "share",
+		DataFiles:        []string{"templates/*.html"},
+		ExtraSourceFiles: []string{"cbits/*.h", "data/**/*.txt", "include/*.h"},
+		ExtraDocFiles:    []string{"*.md", "README.md"},
+	}
+
+	actual, err := ExpandPackageFiles(testPackageFS(), p)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := &PackageFiles{
+		DataFiles:        []string{"share/templates/page.html"},
+		ExtraSourceFiles: []string{"cbits/foo.h", "data/nested/deep/e.txt"},
+		ExtraDocFiles:    []string{"CHANGELOG.md", "README.md"},
+		Unmatched: []*UnmatchedGlob{
+			{Field: "extra-source-files", Pattern: "include/*.h"},
+		},
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, actual)
+	}
+
+	p.CabalVersion = ">= 1.10"
+
+	if _, err := ExpandPackageFiles(testPackageFS(), p); err == nil {
+		t.Fatal("expected error for '**' with cabal-version 1.10")
+	}
+}
@@ -0,0 +1,1317 @@
+package gocabalparser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	hpackDefaultVersion      = "0.0.0"
+	hpackDefaultDefaultsPath = ".hpack/defaults.yaml"
+	hpackMaxDefaultsDepth    = 8
+)
+
+// hpackCommonFields are the top-level fields inherited by every component.
+var hpackCommonFields = []string{
+	"dependencies",
+	"source-dirs",
+	"default-extensions",
+	"other-extensions",
+	"ghc-options",
+	"c-sources",
+	"include-dirs",
+	"includes",
+	"install-includes",
+	"language",
+	"when",
+}
+
+// hpackUnmodeledFields are common fields hpack supports but CabalPackage
+// has no place for. They are left out and reported by Parse.
+var hpackUnmodeledFields = map[string]struct{}{
+	"buildable":               {},
+	"build-tools":             {},
+	"system-build-tools":      {},
+	"cpp-options":             {},
+	"cc-options":              {},
+	"cxx-options":             {},
+	"cxx-sources":             {},
+	"asm-options":             {},
+	"asm-sources":             {},
+	"js-sources":              {},
+	"ld-options":              {},
+	"extra-lib-dirs":          {},
+	"extra-libraries":         {},
+	"extra-frameworks-dirs":   {},
+	"frameworks":              {},
+	"pkg-config-dependencies": {},
+	"ghc-prof-options":        {},
+	"ghc-shared-options":      {},
+	"ghcjs-options":           {},
+	"verbatim":                {},
+}
+
+// hpackListFields are concatenated rather than overridden when merged.
+var hpackListFields = map[string]struct{}{
+	"dependencies":              {},
+	"source-dirs":               {},
+	"default-extensions":        {},
+	"other-extensions":          {},
+	"ghc-options":               {},
+	"c-sources":                 {},
+	"include-dirs":              {},
+	"includes":                  {},
+	"install-includes":          {},
+	"when":                      {},
+	"exposed-modules":           {},
+	"other-modules":             {},
+	"generated-exposed-modules": {},
+	"generated-other-modules":   {},
+	"reexported-modules":        {},
+	"signatures":                {},
+	"extra-source-files":        {},
+	"extra-doc-files":           {},
+	"data-files":                {},
+}
+
+type HpackOptions struct {
+	// FS is the package directory. It is used to read local defaults, to
+	// infer module lists and the license file, and may be nil.
+	FS fs.FS
+	// DefaultsCache holds defaults hosted on GitHub laid out like hpack's
+	// ~/.hpack/defaults directory: <owner>/<repo>/<ref>/<path>.
+	DefaultsCache fs.FS
+}
+
+// HpackParser reads hpack package.yaml files. Parse also returns a
+// warning for every common field, such as cpp-options or extra-libraries,
+// which the model has no place for and which is therefore left out;
+// ParseReader drops the warnings.
+type HpackParser interface {
+	Parser
+	Parse(r io.Reader) (*CabalPackage, []*Diagnostic, error)
+}
+
+type hpackParser struct {
+	opts HpackOptions
+}
+
+// NewHpackParser returns a parser of hpack package.yaml files producing
+// the same model as the .cabal parser; when blocks become conditionals.
+// Tests and benchmarks are ignored.
+func NewHpackParser(opts HpackOptions) HpackParser {
+	return &hpackParser{opts: opts}
+}
+
+// hpackMap is a YAML mapping keeping the order of its keys.
+type hpackMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newHpackMap() *hpackMap {
+	return &hpackMap{values: make(map[string]interface{})}
+}
+
+func (m *hpackMap) get(key string) (interface{}, bool) {
+	v, ok := m.values[key]
+
+	return v, ok
+}
+
+func (m *hpackMap) set(key string, v interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+
+	m.values[key] = v
+}
+
+func (m *hpackMap) without(keys ...string) *hpackMap {
+	res := newHpackMap()
+
+	for _, k := range m.keys {
+		if !containsString(keys, k) {
+			res.set(k, m.values[k])
+		}
+	}
+
+	return res
+}
+
+func (p *hpackParser) ParseReader(r io.Reader) (*CabalPackage, error) {
+	res, _, err := p.Parse(r)
+
+	return res, err
+}
+
+func (p *hpackParser) Parse(r io.Reader) (*CabalPackage, []*Diagnostic, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	top, err := parseHpackYAML(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if top, err = p.applyDefaults(top, 0); err != nil {
+		return nil, nil, err
+	}
+
+	res, err := p.parsePackage(top)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return res, hpackUnmodeled(top, res.Name), nil
+}
+
+func (p *hpackParser) parsePackage(top *hpackMap) (*CabalPackage, error) {
+	res, err := hpackPackage(top)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Flags, err = hpackFlags(top); err != nil {
+		return nil, err
+	}
+
+	if res.LicenseFile == "" && p.opts.FS != nil {
+		if _, err := fs.Stat(p.opts.FS, "LICENSE"); err == nil {
+			res.LicenseFile = "LICENSE"
+		}
+	}
+
+	common := newHpackMap()
+
+	for _, k := range hpackCommonFields {
+		if v, ok := top.get(k); ok {
+			common.set(k, v)
+		}
+	}
+
+	component := func(v interface{}) (*hpackMap, error) {
+		m, err := hpackMapping(v)
+		if err != nil {
+			return nil, err
+		}
+
+		return mergeHpack(common, m), nil
+	}
+
+	if v, ok := top.get("library"); ok {
+		m, err := component(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.Library, err = p.library(res.Name, m); err != nil {
+			return nil, err
+		}
+	}
+
+	libs, err := hpackSections(top, "internal-libraries")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range libs.keys {
+		m, err := component(libs.values[name])
+		if err != nil {
+			return nil, err
+		}
+
+		lib, err := p.library(res.Name, m)
+		if err != nil {
+			return nil, fmt.Errorf("internal library %s: %v", name, err)
+		}
+
+		if res.SubLibraries == nil {
+			res.SubLibraries = make(map[string]*Library)
+		}
+
+		res.SubLibraries[name] = lib
+	}
+
+	exes, err := hpackSections(top, "executables")
+	if err != nil {
+		return nil, err
+	}
+
+	if v, ok := top.get("executable"); ok {
+		exes.set(res.Name, v)
+	}
+
+	for _, name := range exes.keys {
+		m, err := component(exes.values[name])
+		if err != nil {
+			return nil, err
+		}
+
+		exe, err := p.executable(res.Name, m)
+		if err != nil {
+			return nil, fmt.Errorf("executable %s: %v", name, err)
+		}
+
+		if res.Executables == nil {
+			res.Executables = make(map[string]*Executable)
+		}
+
+		res.Executables[name] = exe
+	}
+
+	return res, nil
+}
+
+func parseHpackYAML(data []byte) (*hpackMap, error) {
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	v, err := hpackValue(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if v == nil {
+		return newHpackMap(), nil
+	}
+
+	return hpackMapping(v)
+}
+
+// hpackValue converts a YAML node into nil, a string, a list or an
+// *hpackMap. Scalars are kept as written, so version 1.10 stays 1.10;
+// merge keys are resolved and fields starting with an underscore, used by
+// hpack for anchors, are dropped.
+func hpackValue(n *yaml.Node) (interface{}, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+
+		return hpackValue(n.Content[0])
+	case yaml.AliasNode:
+		return hpackValue(n.Alias)
+	case yaml.ScalarNode:
+		if n.Tag == "!!null" {
+			return nil, nil
+		}
+
+		return n.Value, nil
+	case yaml.SequenceNode:
+		res := make([]interface{}, 0, len(n.Content))
+
+		for _, c := range n.Content {
+			v, err := hpackValue(c)
+			if err != nil {
+				return nil, err
+			}
+
+			res = append(res, v)
+		}
+
+		return res, nil
+	case yaml.MappingNode:
+		res := newHpackMap()
+		merged := make([]*hpackMap, 0)
+
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i].Value
+
+			v, err := hpackValue(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+
+			switch {
+			case key == "<<":
+				for _, m := range hpackList("<<", v) {
+					mm, err := hpackMapping(m)
+					if err != nil {
+						return nil, err
+					}
+
+					merged = append(merged, mm)
+				}
+			case strings.HasPrefix(key, "_"):
+			default:
+				res.set(key, v)
+			}
+		}
+
+		for _, m := range merged {
+			for _, k := range m.keys {
+				if _, ok := res.get(k); !ok {
+					res.set(k, m.values[k])
+				}
+			}
+		}
+
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unsupported YAML node at line %d", n.Line)
+	}
+}
+
+func hpackMapping(v interface{}) (*hpackMap, error) {
+	switch v := v.(type) {
+	case nil:
+		return newHpackMap(), nil
+	case *hpackMap:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("mapping expected, got: %v", v)
+	}
+}
+
+func hpackSections(top *hpackMap, key string) (*hpackMap, error) {
+	v, _ := top.get(key)
+
+	m, err := hpackMapping(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", key, err)
+	}
+
+	return m, nil
+}
+
+// hpackList returns the value as a list. Single values become one element
+// lists, except for a dependencies mapping which becomes a list of single
+// entry mappings.
+func hpackList(key string, v interface{}) []interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	case *hpackMap:
+		if key != "dependencies" {
+			return []interface{}{v}
+		}
+
+		res := make([]interface{}, 0, len(v.keys))
+
+		for _, k := range v.keys {
+			entry := newHpackMap()
+			entry.set(k, v.values[k])
+			res = append(res, entry)
+		}
+
+		return res
+	default:
+		return []interface{}{v}
+	}
+}
+
+func hpackStrings(key string, v interface{}) ([]string, error) {
+	items := hpackList(key, v)
+	if items == nil {
+		return nil, nil
+	}
+
+	res := make([]string, 0, len(items))
+
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: string expected, got: %v", key, item)
+		}
+
+		res = append(res, s)
+	}
+
+	return res, nil
+}
+
+func hpackString(m *hpackMap, key string) (string, error) {
+	v, _ := m.get(key)
+
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		return "", fmt.Errorf("%s: string expected, got: %v", key, v)
+	}
+}
+
+// hpackLines splits a YAML string into field lines. Blank lines between
+// paragraphs are kept as "." when paragraphs is set, as cabal files
+// encode empty description lines, and dropped otherwise.
+func hpackLines(s string, paragraphs bool) []string {
+	var res []string
+
+	blank := false
+
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l == "" {
+			blank = len(res) > 0
+
+			continue
+		}
+
+		if blank && paragraphs {
+			res = append(res, ".")
+		}
+
+		res = append(res, l)
+		blank = false
+	}
+
+	return res
+}
+
+// hpackJoined returns a field which may be a string or a list of strings
+// joined by ", ".
+func hpackJoined(m *hpackMap, key string) (string, error) {
+	v, _ := m.get(key)
+
+	values, err := hpackStrings(key, v)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(values, ", "), nil
+}
+
+// mergeHpack merges over into base: list fields are concatenated, nested
+// mappings merged and other values of over take precedence.
+func mergeHpack(base, over *hpackMap) *hpackMap {
+	res := base.without()
+
+	for _, k := range over.keys {
+		ov := over.values[k]
+		bv, ok := res.get(k)
+
+		if _, isList := hpackListFields[k]; ok && isList {
+			res.set(k, append(append([]interface{}{}, hpackList(k, bv)...), hpackList(k, ov)...))
+
+			continue
+		}
+
+		bm, bIsMap := bv.(*hpackMap)
+		om, oIsMap := ov.(*hpackMap)
+
+		if ok && bIsMap && oIsMap {
+			res.set(k, mergeHpack(bm, om))
+		} else {
+			res.set(k, ov)
+		}
+	}
+
+	return res
+}
+
+// applyDefaults merges the defaults the package refers to beneath it.
+func (p *hpackParser) applyDefaults(m *hpackMap, depth int) (*hpackMap, error) {
+	v, ok := m.get("defaults")
+	if !ok {
+		return m, nil
+	}
+
+	if depth >= hpackMaxDefaultsDepth {
+		return nil, errors.New("defaults: too deeply nested")
+	}
+
+	res := newHpackMap()
+
+	for _, ref := range hpackList("defaults", v) {
+		data, err := p.readDefaults(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		d, err := parseHpackYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("defaults: %v", err)
+		}
+
+		if d, err = p.applyDefaults(d, depth+1); err != nil {
+			return nil, err
+		}
+
+		res = mergeHpack(res, d)
+	}
+
+	return mergeHpack(res, m.without("defaults")), nil
+}
+
+func (p *hpackParser) readDefaults(ref interface{}) ([]byte, error) {
+	var github, gitRef, file, local string
+
+	switch ref := ref.(type) {
+	case string:
+		at := strings.LastIndexByte(ref, '@')
+		if at < 0 {
+			return nil, fmt.Errorf("defaults: ref expected: %s", ref)
+		}
+
+		github, gitRef, file = ref[:at], ref[at+1:], hpackDefaultDefaultsPath
+	case *hpackMap:
+		var err error
+
+		for key, to := range map[string]*string{"github": &github, "ref": &gitRef, "path": &file, "local": &local} {
+			if *to, err = hpackString(ref, key); err != nil {
+				return nil, fmt.Errorf("defaults: %v", err)
+			}
+		}
+
+		if file == "" {
+			file = hpackDefaultDefaultsPath
+		}
+	default:
+		return nil, fmt.Errorf("defaults: unexpected value: %v", ref)
+	}
+
+	switch {
+	case local != "":
+		if p.opts.FS == nil {
+			return nil, fmt.Errorf("defaults %s: no file system", local)
+		}
+
+		return fs.ReadFile(p.opts.FS, path.Clean(local))
+	case github != "" && gitRef != "":
+		if p.opts.DefaultsCache == nil {
+			return nil, fmt.Errorf("defaults %s@%s: no defaults cache", github, gitRef)
+		}
+
+		return fs.ReadFile(p.opts.DefaultsCache, path.Join(github, gitRef, file))
+	default:
+		return nil, errors.New("defaults: github and ref or local expected")
+	}
+}
+
+func hpackFlags(top *hpackMap) (map[string]*Flag, error) {
+	flags, err := hpackSections(top, "flags")
+	if err != nil {
+		return nil, err
+	}
+
+	var res map[string]*Flag
+
+	for _, name := range flags.keys {
+		m, err := hpackMapping(flags.values[name])
+		if err != nil {
+			return nil, fmt.Errorf("flag %s: %v", name, err)
+		}
+
+		flag := &Flag{}
+
+		fields := []struct {
+			key string
+			to  *bool
+		}{
+			{"default", &flag.Default},
+			{"manual", &flag.Manual},
+		}
+
+		for _, f := range fields {
+			if !hpackHas(m, f.key) {
+				return nil, fmt.Errorf("flag %s: %s expected", name, f.key)
+			}
+
+			s, err := hpackString(m, f.key)
+			if err != nil {
+				return nil, fmt.Errorf("flag %s: %v", name, err)
+			}
+
+			switch strings.ToLower(s) {
+			case "true":
+				*f.to = true
+			case "false":
+				*f.to = false
+			default:
+				return nil, fmt.Errorf("flag %s: boolean expected for %s", name, f.key)
+			}
+		}
+
+		if flag.Description, err = hpackString(m, "description"); err != nil {
+			return nil, fmt.Errorf("flag %s: %v", name, err)
+		}
+
+		if res == nil {
+			res = make(map[string]*Flag)
+		}
+
+		res[name] = flag
+	}
+
+	return res, nil
+}
+
+// hpackUnmodeled reports the unmodeled fields of the top level, the
+// components and their when blocks.
+func hpackUnmodeled(top *hpackMap, pkg string) []*Diagnostic {
+	res := make([]*Diagnostic, 0)
+
+	var walk func(component string, v interface{})
+	walk = func(component string, v interface{}) {
+		m, err := hpackMapping(v)
+		if err != nil {
+			return
+		}
+
+		for _, k := range m.keys {
+			if _, ok := hpackUnmodeledFields[k]; ok {
+				res = append(res, newDiagnostic("hpack-unmodeled-field", SeverityWarning, component,
+					"%s is not modeled and is left out", k))
+			}
+		}
+
+		when, _ := m.get("when")
+		for _, item := range hpackList("when", when) {
+			walk(component, item)
+
+			if c, err := hpackMapping(item); err == nil {
+				then, _ := c.get("then")
+				els, _ := c.get("else")
+				walk(component, then)
+				walk(component, els)
+			}
+		}
+	}
+
+	walk("", top)
+
+	if v, ok := top.get("library"); ok {
+		walk("library", v)
+	}
+
+	for _, kind := range []struct{ key, component string }{
+		{"internal-libraries", "library"},
+		{"executables", "executable"},
+	} {
+		sections, err := hpackSections(top, kind.key)
+		if err != nil {
+			continue
+		}
+
+		for _, name := range sections.keys {
+			walk(kind.component+" "+name, sections.values[name])
+		}
+	}
+
+	if v, ok := top.get("executable"); ok {
+		walk("executable "+pkg, v)
+	}
+
+	return res
+}
+
+func hpackPackage(top *hpackMap) (*CabalPackage, error) {
+	res := &CabalPackage{}
+
+	fields := []struct {
+		key    string
+		to     *string
+		joined bool
+	}{
+		{key: "name", to: &res.Name},
+		{key: "version", to: &res.Version},
+		{key: "category", to: &res.Category},
+		{key: "stability", to: &res.Stability},
+		{key: "homepage", to: &res.Homepage},
+		{key: "license", to: &res.License},
+		{key: "build-type", to: &res.BuildType},
+		{key: "data-dir", to: &res.DataDir},
+		{key: "author", to: &res.Author, joined: true},
+		{key: "maintainer", to: &res.Maintainer, joined: true},
+		{key: "tested-with", to: &res.TestedWith, joined: true},
+	}
+
+	var err error
+
+	for _, f := range fields {
+		if f.joined {
+			*f.to, err = hpackJoined(top, f.key)
+		} else {
+			*f.to, err = hpackString(top, f.key)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if res.Version == "" {
+		res.Version = hpackDefaultVersion
+	}
+
+	lists := map[string]*[]string{
+		"copyright":          &res.Copyright,
+		"license-file":       nil,
+		"data-files":         &res.DataFiles,
+		"extra-source-files": &res.ExtraSourceFiles,
+		"extra-doc-files":    &res.ExtraDocFiles,
+	}
+
+	for key, to := range lists {
+		v, _ := top.get(key)
+
+		values, err := hpackStrings(key, v)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case to != nil:
+			*to = values
+		case len(values) > 0:
+			res.LicenseFile = values[0]
+		}
+	}
+
+	for key, to := range map[string]*[]string{"synopsis": &res.Synopsis, "description": &res.Description} {
+		s, err := hpackString(top, key)
+		if err != nil {
+			return nil, err
+		}
+
+		*to = hpackLines(s, key == "description")
+	}
+
+	if err := hpackRepository(top, res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// hpackRepository derives the head source repository and the homepage
+// from the github or git fields.
+func hpackRepository(top *hpackMap, to *CabalPackage) error {
+	github, err := hpackString(top, "github")
+	if err != nil {
+		return err
+	}
+
+	location, err := hpackString(top, "git")
+	if err != nil {
+		return err
+	}
+
+	if github != "" {
+		parts := strings.Split(github, "/")
+		if len(parts) < 2 {
+			return fmt.Errorf("github: owner/repo expected: %s", github)
+		}
+
+		location = "https://github.com/" + parts[0] + "/" + parts[1]
+
+		if to.Homepage == "" {
+			to.Homepage = location + "#readme"
+		}
+	}
+
+	if location != "" {
+		to.Repositories = map[string]*SourceRepository{
+			"head": {Type: "git", Location: location},
+		}
+	}
+
+	return nil
+}
+
+func hpackBuildInfo(m *hpackMap) (BuildInfo, error) {
+	var (
+		res BuildInfo
+		err error
+	)
+
+	v, _ := m.get("dependencies")
+	if res.BuildDepends, res.Mixins, err = hpackDependencies(v); err != nil {
+		return res, err
+	}
+
+	language, err := hpackString(m, "language")
+	if err != nil {
+		return res, err
+	}
+
+	if language != "" {
+		if res.DefaultLanguage, err = ParseLanguage(language); err != nil {
+			return res, err
+		}
+	}
+
+	words := []struct {
+		key   string
+		to    *[]string
+		split listSplitter
+	}{
+		{"source-dirs", &res.HSSourceDirs, nil},
+		{"default-extensions", &res.DefaultExtensions, splitOptCommaList},
+		{"other-extensions", &res.OtherExtensions, splitOptCommaList},
+		{"ghc-options", &res.GHCOptions, splitHaskellTokens},
+		{"c-sources", &res.CSources, nil},
+		{"include-dirs", &res.IncludeDirs, nil},
+		{"includes", &res.Includes, nil},
+		{"install-includes", &res.InstallIncludes, nil},
+	}
+
+	for _, w := range words {
+		v, _ := m.get(w.key)
+
+		values, err := hpackStrings(w.key, v)
+		if err != nil {
+			return res, err
+		}
+
+		for _, s := range values {
+			if w.split == nil {
+				*w.to = append(*w.to, s)
+
+				continue
+			}
+
+			split, err := w.split(s)
+			if err != nil {
+				return res, err
+			}
+
+			*w.to = append(*w.to, split...)
+		}
+	}
+
+	modules := []struct {
+		key string
+		to  *[]ModuleName
+	}{
+		{"other-modules", &res.OtherModules},
+		{"generated-other-modules", &res.AutogenModules},
+	}
+
+	for _, mod := range modules {
+		if *mod.to, err = hpackModules(m, mod.key); err != nil {
+			return res, err
+		}
+	}
+
+	res.OtherModules = append(res.OtherModules, res.AutogenModules...)
+
+	when, _ := m.get("when")
+
+	for _, item := range hpackList("when", when) {
+		c, err := hpackConditional(item)
+		if err != nil {
+			return res, err
+		}
+
+		res.Conditionals = append(res.Conditionals, c)
+	}
+
+	return res, nil
+}
+
+// hpackConditional reads a when entry, which holds either the fields of
+// its branch beside the condition or then and else branches.
+func hpackConditional(v interface{}) (*Conditional, error) {
+	m, err := hpackMapping(v)
+	if err != nil {
+		return nil, fmt.Errorf("when: %v", err)
+	}
+
+	condition, err := hpackString(m, "condition")
+	if err != nil {
+		return nil, err
+	}
+
+	if condition == "" {
+		return nil, errors.New("when: condition expected")
+	}
+
+	res := &Conditional{Condition: strings.Join(strings.Fields(condition), " ")}
+
+	if _, err := (&Platform{}).EvalCondition(res.Condition); err != nil {
+		return nil, err
+	}
+
+	then, hasThen := m.get("then")
+	els, hasElse := m.get("else")
+
+	if !hasThen && !hasElse {
+		if res.Then, err = hpackBranch(m.without("condition")); err != nil {
+			return nil, err
+		}
+
+		return res, nil
+	}
+
+	thenMap, err := hpackMapping(then)
+	if err != nil {
+		return nil, fmt.Errorf("when: then: %v", err)
+	}
+
+	if res.Then, err = hpackBranch(thenMap); err != nil {
+		return nil, err
+	}
+
+	if !hasElse {
+		return res, nil
+	}
+
+	elseMap, err := hpackMapping(els)
+	if err != nil {
+		return nil, fmt.Errorf("when: else: %v", err)
+	}
+
+	otherwise, err := hpackBranch(elseMap)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Else = &otherwise
+
+	return res, nil
+}
+
+// hpackBranch reads the fields of a when branch: build info and the
+// component fields exposed-modules, reexported-modules, signatures and main.
+func hpackBranch(m *hpackMap) (ConditionalBranch, error) {
+	var res ConditionalBranch
+
+	bi, err := hpackBuildInfo(m)
+	if err != nil {
+		return res, err
+	}
+
+	res.BuildInfo = bi
+
+	if res.ExposedModules, err = hpackModules(m, "exposed-modules"); err != nil {
+		return res, err
+	}
+
+	if res.Signatures, err = hpackModules(m, "signatures"); err != nil {
+		return res, err
+	}
+
+	if res.ReexportedModules, err = hpackReexports(m); err != nil {
+		return res, err
+	}
+
+	if _, err = hpackMain(m, &res.MainIs, &res.BuildInfo); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// hpackDependencies reads dependencies given as a list of strings or
+// mappings, or as a mapping from package names to a constraint or to a
+// mapping with version and mixin fields. Later entries for the same
+// package replace earlier ones.
+func hpackDependencies(v interface{}) ([]*Dependency, []*Mixin, error) {
+	var (
+		deps   []*Dependency
+		mixins []*Mixin
+	)
+
+	index := make(map[string]int)
+	parser := newDependenciesParser()
+
+	for _, item := range hpackList("dependencies", v) {
+		var (
+			dep *Dependency
+			err error
+		)
+
+		mixin := make([]string, 0)
+
+		switch item := item.(type) {
+		case string:
+			dep, err = parser.ParseString(strings.Join(strings.Fields(item), " "))
+		case *hpackMap:
+			if len(item.keys) != 1 {
+				return nil, nil, fmt.Errorf("dependencies: unexpected mapping: %v", item.keys)
+			}
+
+			name := item.keys[0]
+			constraint := ""
+
+			switch spec := item.values[name].(type) {
+			case nil:
+			case string:
+				constraint = spec
+			case *hpackMap:
+				if constraint, err = hpackString(spec, "version"); err != nil {
+					return nil, nil, err
+				}
+
+				m, _ := spec.get("mixin")
+				if mixin, err = hpackStrings("mixin", m); err != nil {
+					return nil, nil, err
+				}
+			default:
+				return nil, nil, fmt.Errorf("dependencies: unexpected value for %s", name)
+			}
+
+			dep, err = parser.ParseString(name + " " + constraint)
+		default:
+			return nil, nil, fmt.Errorf("dependencies: unexpected value: %v", item)
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if i, ok := index[dep.Name]; ok {
+			deps[i] = dep
+		} else {
+			index[dep.Name] = len(deps)
+			deps = append(deps, dep)
+		}
+
+		if len(mixin) > 0 {
+			m, err := newMixinsParser().ParseString(dep.Name + " " + strings.Join(mixin, " "))
+			if err != nil {
+				return nil, nil, err
+			}
+
+			mixins = append(mixins, m)
+		}
+	}
+
+	return deps, mixins, nil
+}
+
+func hpackModules(m *hpackMap, key string) ([]ModuleName, error) {
+	v, _ := m.get(key)
+
+	names, err := hpackStrings(key, v)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []ModuleName
+
+	for _, n := range names {
+		mod, err := ParseModuleName(n)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, mod)
+	}
+
+	return res, nil
+}
+
+// library builds a library. When the package directory is available,
+// modules missing from exposed-modules and other-modules are inferred from
+// the source directories as hpack does, adding the Paths_ module to
+// other-modules.
+func (p *hpackParser) library(pkg string, m *hpackMap) (*Library, error) {
+	bi, err := hpackBuildInfo(m)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Library{BuildInfo: bi}
+
+	if res.ExposedModules, err = hpackModules(m, "exposed-modules"); err != nil {
+		return nil, err
+	}
+
+	if res.Signatures, err = hpackModules(m, "signatures"); err != nil {
+		return nil, err
+	}
+
+	if res.ReexportedModules, err = hpackReexports(m); err != nil {
+		return nil, err
+	}
+
+	_, hasExposed := m.get("exposed-modules")
+	_, hasOther := m.get("other-modules")
+
+	if p.opts.FS != nil && !(hasExposed && hasOther) {
+		all, err := p.sourceModules(res.HSSourceDirs)
+		if err != nil {
+			return nil, err
+		}
+
+		// modules of when branches are known as well
+		known := make([]ModuleName, 0)
+		for _, modules := range libraryModules(res) {
+			known = append(known, modules...)
+		}
+
+		if hasExposed {
+			res.OtherModules = append(res.OtherModules, excludeModules(all, known)...)
+		} else {
+			res.ExposedModules = excludeModules(all, known)
+		}
+
+		if !hasOther {
+			res.addPathsModule(pkg)
+		}
+	}
+
+	generated, err := hpackModules(m, "generated-exposed-modules")
+	if err != nil {
+		return nil, err
+	}
+
+	res.ExposedModules = append(res.ExposedModules, generated...)
+	res.AutogenModules = append(res.AutogenModules, generated...)
+
+	return res, nil
+}
+
+// executable builds an executable. Main may be a file or a module name;
+// for a module hpack passes -main-is to GHC.
+func (p *hpackParser) executable(pkg string, m *hpackMap) (*Executable, error) {
+	bi, err := hpackBuildInfo(m)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Executable{BuildInfo: bi}
+
+	mainModule, err := hpackMain(m, &res.MainIs, &res.BuildInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, hasOther := m.get("other-modules"); p.opts.FS != nil && !hasOther {
+		all, err := p.sourceModules(res.HSSourceDirs)
+		if err != nil {
+			return nil, err
+		}
+
+		res.OtherModules = append(res.OtherModules, excludeModules(all, append([]ModuleName{mainModule}, otherModules(&res.BuildInfo)...))...)
+		res.addPathsModule(pkg)
+	}
+
+	return res, nil
+}
+
+// hpackMain reads main, a file or a module name, into mainIs and returns
+// the main module. For a module other than Main hpack passes -main-is to
+// GHC.
+func hpackMain(m *hpackMap, mainIs *string, bi *BuildInfo) (ModuleName, error) {
+	main, err := hpackString(m, "main")
+	if err != nil {
+		return "", err
+	}
+
+	var mainModule ModuleName
+
+	switch ext := path.Ext(main); {
+	case main == "":
+	case ext == ".hs" || ext == ".lhs":
+		*mainIs = main
+		mainModule, _ = ParseModuleName(strings.ReplaceAll(strings.TrimSuffix(main, ext), "/", "."))
+	default:
+		if mainModule, err = ParseModuleName(main); err != nil {
+			return "", fmt.Errorf("main: %v", err)
+		}
+
+		*mainIs = mainModule.FilePath(".hs")
+
+		if mainModule != "Main" {
+			bi.GHCOptions = append(bi.GHCOptions, "-main-is", string(mainModule))
+		}
+	}
+
+	return mainModule, nil
+}
+
+func hpackReexports(m *hpackMap) ([]*ModuleReexport, error) {
+	v, _ := m.get("reexported-modules")
+
+	reexports, err := hpackStrings("reexported-modules", v)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*ModuleReexport
+
+	for _, r := range reexports {
+		reexport, err := newReexportsParser().ParseString(r)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, reexport)
+	}
+
+	return res, nil
+}
+
+// sourceModules returns the modules found in the source directories, the
+// package directory when there are none. Missing directories are skipped.
+func (p *hpackParser) sourceModules(dirs []string) ([]ModuleName, error) {
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	res := make([]ModuleName, 0)
+
+	for _, dir := range dirs {
+		modules, err := sourceModules(p.opts.FS, dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		res = append(res, excludeModules(modules, res)...)
+	}
+
+	return res, nil
+}
+
+func (bi *BuildInfo) addPathsModule(pkg string) {
+	paths := ModuleName("Paths_" + strings.ReplaceAll(pkg, "-", "_"))
+
+	if len(excludeModules([]ModuleName{paths}, bi.OtherModules)) > 0 {
+		bi.OtherModules = append(bi.OtherModules, paths)
+	}
+
+	if len(excludeModules([]ModuleName{paths}, bi.AutogenModules)) > 0 {
+		bi.AutogenModules = append(bi.AutogenModules, paths)
+	}
+}
+
+func excludeModules(modules, excluded []ModuleName) []ModuleName {
+	res := make([]ModuleName, 0, len(modules))
+
+	for _, m := range modules {
+		found := false
+
+		for _, e := range excluded {
+			if m == e {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			res = append(res, m)
+		}
+	}
+
+	return res
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
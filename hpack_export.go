@@ -0,0 +1,566 @@
+package gocabalparser
+
+import (
+	"io"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const githubURLPrefix = "https://github.com/"
+
+// HpackExporter writes packages as hpack package.yaml files.
+type HpackExporter interface {
+	Export(w io.Writer, p *CabalPackage) ([]*Diagnostic, error)
+}
+
+type hpackExporter struct{}
+
+func NewHpackExporter() HpackExporter {
+	return &hpackExporter{}
+}
+
+// hpackExport collects the warnings of a single export.
+type hpackExport struct {
+	diagnostics []*Diagnostic
+}
+
+// hpackHoisted holds the build info shared by all components, written at
+// the top level. Dependencies and extensions shared in any order are
+// hoisted; for source-dirs, ghc-options and conditionals, where order
+// matters, only the common prefix is.
+type hpackHoisted struct {
+	dependencies      []string
+	sourceDirs        []string
+	defaultExtensions []string
+	otherExtensions   []string
+	ghcOptions        []string
+	language          Language
+	conditionals      []*Conditional
+}
+
+// Export writes the package as a package.yaml. Build info shared by all
+// components is hoisted to the top level and conditionals become when
+// blocks. Module lists are always written so that hpack doesn't infer
+// them. Anything hpack can't express is left out and reported as a
+// warning.
+func (e *hpackExporter) Export(w io.Writer, p *CabalPackage) ([]*Diagnostic, error) {
+	x := &hpackExport{diagnostics: make([]*Diagnostic, 0)}
+
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{x.packageNode(p)}}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return x.diagnostics, nil
+}
+
+func (x *hpackExport) warn(ruleID, component, format string, args ...interface{}) {
+	x.diagnostics = append(x.diagnostics, newDiagnostic(ruleID, SeverityWarning, component, format, args...))
+}
+
+func (x *hpackExport) packageNode(p *CabalPackage) *yaml.Node {
+	res := yamlMapping()
+
+	if p.CabalVersion != "" {
+		x.warn("hpack-cabal-version", "", "cabal-version %s is left out, hpack derives it", p.CabalVersion)
+	}
+
+	if p.PackageURL != "" {
+		x.warn("hpack-package-url", "", "package-url is not supported by hpack")
+	}
+
+	addYAMLString(res, "name", p.Name)
+	addYAMLString(res, "version", p.Version)
+	addYAMLString(res, "synopsis", strings.Join(p.Synopsis, "\n"))
+	addYAMLString(res, "description", strings.Join(p.Description, "\n"))
+	addYAMLString(res, "category", p.Category)
+	addYAMLString(res, "stability", p.Stability)
+	x.addRepository(res, p)
+	addYAMLString(res, "author", p.Author)
+	addYAMLString(res, "maintainer", p.Maintainer)
+	addYAMLStrings(res, "copyright", p.Copyright)
+	addYAMLString(res, "license", p.License)
+	addYAMLString(res, "license-file", p.LicenseFile)
+	addYAMLString(res, "build-type", p.BuildType)
+	addYAMLString(res, "tested-with", p.TestedWith)
+	addYAMLString(res, "data-dir", p.DataDir)
+	addYAMLStrings(res, "data-files", p.DataFiles)
+	addYAMLStrings(res, "extra-source-files", p.ExtraSourceFiles)
+	addYAMLStrings(res, "extra-doc-files", p.ExtraDocFiles)
+
+	if len(p.Flags) > 0 {
+		flags := yamlMapping()
+
+		for _, name := range sortedKeys(p.Flags) {
+			f := p.Flags[name]
+			flag := yamlMapping()
+			addYAMLString(flag, "description", f.Description)
+			addYAML(flag, "manual", yamlBool(f.Manual))
+			addYAML(flag, "default", yamlBool(f.Default))
+			addYAML(flags, name, flag)
+		}
+
+		addYAML(res, "flags", flags)
+	}
+
+	components := p.buildInfos()
+	hoisted := hoistBuildInfo(components)
+
+	x.addHoisted(res, hoisted)
+
+	if p.Library != nil {
+		addYAML(res, "library", x.libraryNode("library", p.Library, hoisted))
+	}
+
+	if len(p.SubLibraries) > 0 {
+		libs := yamlMapping()
+
+		for _, name := range sortedKeys(p.SubLibraries) {
+			addYAML(libs, name, x.libraryNode("library "+name, p.SubLibraries[name], hoisted))
+		}
+
+		addYAML(res, "internal-libraries", libs)
+	}
+
+	if len(p.Executables) > 0 {
+		exes := yamlMapping()
+
+		for _, name := range sortedKeys(p.Executables) {
+			addYAML(exes, name, x.executableNode("executable "+name, p.Executables[name], hoisted))
+		}
+
+		addYAML(res, "executables", exes)
+	}
+
+	return res
+}
+
+// addRepository writes the head git repository as github or git. A
+// homepage derived by hpack from github is left out.
+func (x *hpackExport) addRepository(to *yaml.Node, p *CabalPackage) {
+	homepage := p.Homepage
+
+	for _, kind := range sortedKeys(p.Repositories) {
+		r := p.Repositories[kind]
+
+		if kind != "head" || r.Type != "git" || r.Tag != "" || r.Location == "" {
+			x.warn("hpack-source-repository", "", "source-repository %s is not supported by hpack", kind)
+
+			continue
+		}
+
+		location := strings.TrimSuffix(r.Location, ".git")
+		repo := strings.TrimPrefix(location, githubURLPrefix)
+
+		if strings.HasPrefix(location, githubURLPrefix) && strings.Count(repo, "/") == 1 {
+			if homepage == githubURLPrefix+repo+"#readme" {
+				homepage = ""
+			}
+
+			addYAMLString(to, "homepage", homepage)
+			addYAMLString(to, "github", repo)
+
+			return
+		}
+
+		addYAMLString(to, "homepage", homepage)
+		addYAMLString(to, "git", r.Location)
+
+		return
+	}
+
+	addYAMLString(to, "homepage", homepage)
+}
+
+// hoistBuildInfo finds the build info shared by all components. Nothing is
+// hoisted from a single component. Dependencies with mixins stay with
+// their components since hpack attaches mixins to dependencies.
+func hoistBuildInfo(components []namedBuildInfo) *hpackHoisted {
+	res := &hpackHoisted{}
+	if len(components) < 2 {
+		return res
+	}
+
+	first := components[0].info
+
+	res.language = first.DefaultLanguage
+	res.dependencies = dependencyStrings(first.BuildDepends)
+	res.sourceDirs = first.HSSourceDirs
+	res.defaultExtensions = first.DefaultExtensions
+	res.otherExtensions = first.OtherExtensions
+	res.ghcOptions = first.GHCOptions
+	res.conditionals = first.Conditionals
+
+	for _, c := range components {
+		bi := c.info
+
+		if bi.DefaultLanguage != res.language {
+			res.language = ""
+		}
+
+		deps := make([]string, 0, len(bi.BuildDepends))
+
+		for _, d := range bi.BuildDepends {
+			if len(dependencyMixins(bi, d)) == 0 {
+				deps = append(deps, d.String())
+			}
+		}
+
+		res.dependencies = commonStrings(res.dependencies, deps)
+		res.defaultExtensions = commonStrings(res.defaultExtensions, bi.DefaultExtensions)
+		res.otherExtensions = commonStrings(res.otherExtensions, bi.OtherExtensions)
+		res.sourceDirs = res.sourceDirs[:commonPrefix(res.sourceDirs, bi.HSSourceDirs)]
+		res.ghcOptions = res.ghcOptions[:commonPrefix(res.ghcOptions, bi.GHCOptions)]
+
+		n := 0
+		for n < len(res.conditionals) && n < len(bi.Conditionals) && reflect.DeepEqual(res.conditionals[n], bi.Conditionals[n]) {
+			n++
+		}
+
+		res.conditionals = res.conditionals[:n]
+	}
+
+	return res
+}
+
+func (x *hpackExport) addHoisted(to *yaml.Node, h *hpackHoisted) {
+	addYAMLStrings(to, "source-dirs", h.sourceDirs)
+	addYAMLStrings(to, "dependencies", h.dependencies)
+	addYAMLString(to, "language", string(h.language))
+	addYAMLStrings(to, "default-extensions", h.defaultExtensions)
+	addYAMLStrings(to, "other-extensions", h.otherExtensions)
+	addYAMLStrings(to, "ghc-options", quoteAll(h.ghcOptions))
+	x.addConditionals(to, "", h.conditionals)
+}
+
+func (x *hpackExport) libraryNode(component string, l *Library, h *hpackHoisted) *yaml.Node {
+	res := yamlMapping()
+
+	exposed, generatedExposed := splitAutogenModules(l.ExposedModules, l.AutogenModules)
+	other, generatedOther := splitAutogenModules(l.OtherModules, l.AutogenModules)
+
+	x.checkAutogenModules(component, &l.BuildInfo, l.ExposedModules)
+
+	addYAMLStrings(res, "source-dirs", l.HSSourceDirs[len(h.sourceDirs):])
+	addYAML(res, "exposed-modules", yamlSequence(moduleLines(exposed)))
+	addYAMLStrings(res, "generated-exposed-modules", moduleLines(generatedExposed))
+	addYAML(res, "other-modules", yamlSequence(moduleLines(other)))
+	addYAMLStrings(res, "generated-other-modules", moduleLines(generatedOther))
+
+	addYAMLStrings(res, "reexported-modules", reexportLines(l.ReexportedModules))
+	addYAMLStrings(res, "signatures", moduleLines(l.Signatures))
+	x.addBuildInfo(res, component, &l.BuildInfo, h)
+
+	return res
+}
+
+func (x *hpackExport) executableNode(component string, e *Executable, h *hpackHoisted) *yaml.Node {
+	res := yamlMapping()
+
+	if ext := path.Ext(e.MainIs); e.MainIs != "" && ext != ".hs" && ext != ".lhs" {
+		x.warn("hpack-main-is", component, "main-is %s is read by hpack as a module name", e.MainIs)
+	}
+
+	other, generatedOther := splitAutogenModules(e.OtherModules, e.AutogenModules)
+
+	x.checkAutogenModules(component, &e.BuildInfo, nil)
+
+	addYAMLString(res, "main", e.MainIs)
+	addYAMLStrings(res, "source-dirs", e.HSSourceDirs[len(h.sourceDirs):])
+	addYAML(res, "other-modules", yamlSequence(moduleLines(other)))
+	addYAMLStrings(res, "generated-other-modules", moduleLines(generatedOther))
+	x.addBuildInfo(res, component, &e.BuildInfo, h)
+
+	return res
+}
+
+// addBuildInfo writes the build info of a component or a conditional
+// branch except modules and source directories, leaving out what was
+// hoisted.
+func (x *hpackExport) addBuildInfo(to *yaml.Node, component string, bi *BuildInfo, h *hpackHoisted) {
+	x.addDependencies(to, component, bi, h.dependencies)
+
+	if bi.DefaultLanguage != h.language {
+		addYAMLString(to, "language", string(bi.DefaultLanguage))
+	}
+
+	addYAMLStrings(to, "default-extensions", excludeStrings(bi.DefaultExtensions, h.defaultExtensions))
+	addYAMLStrings(to, "other-extensions", excludeStrings(bi.OtherExtensions, h.otherExtensions))
+	addYAMLStrings(to, "ghc-options", quoteAll(bi.GHCOptions[len(h.ghcOptions):]))
+	addYAMLStrings(to, "c-sources", bi.CSources)
+	addYAMLStrings(to, "include-dirs", bi.IncludeDirs)
+	addYAMLStrings(to, "includes", bi.Includes)
+	addYAMLStrings(to, "install-includes", bi.InstallIncludes)
+
+	if len(bi.Extensions) > 0 {
+		x.warn("hpack-extensions-field", component, "the extensions field is not supported by hpack: %s",
+			strings.Join(bi.Extensions, ", "))
+	}
+
+	x.addConditionals(to, component, bi.Conditionals[len(h.conditionals):])
+}
+
+// addDependencies writes the dependencies as a list, or as a mapping when
+// some of them carry mixins. hpack supports a single mixin entry per
+// dependency.
+func (x *hpackExport) addDependencies(to *yaml.Node, component string, bi *BuildInfo, hoisted []string) {
+	deps := make([]*Dependency, 0, len(bi.BuildDepends))
+	withMixins := false
+
+	for _, d := range bi.BuildDepends {
+		if !containsString(hoisted, d.String()) {
+			deps = append(deps, d)
+			withMixins = withMixins || len(dependencyMixins(bi, d)) > 0
+		}
+	}
+
+	for _, m := range bi.Mixins {
+		found := false
+
+		for _, d := range bi.BuildDepends {
+			found = found || mixinDependency(m) == d.Name
+		}
+
+		if !found {
+			x.warn("hpack-mixin", component, "mixin %s has no dependency in this block", m)
+		}
+	}
+
+	if !withMixins {
+		addYAMLStrings(to, "dependencies", dependencyStrings(deps))
+
+		return
+	}
+
+	res := yamlMapping()
+
+	for _, d := range deps {
+		mixins := dependencyMixins(bi, d)
+		r := d.VersionRange()
+
+		if len(mixins) == 0 {
+			if r.Kind == VersionRangeAny {
+				addYAML(res, d.Name, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"})
+			} else {
+				addYAML(res, d.Name, yamlString(r.String()))
+			}
+
+			continue
+		}
+
+		spec := yamlMapping()
+		if r.Kind != VersionRangeAny {
+			addYAMLString(spec, "version", r.String())
+		}
+
+		renaming := strings.TrimSpace(strings.TrimPrefix(mixins[0].String(), mixinDependency(mixins[0])))
+		if renaming == "" {
+			x.warn("hpack-mixin", component, "mixin %s without renaming is left out", mixins[0])
+		} else {
+			addYAML(spec, "mixin", yamlSequence([]string{renaming}))
+		}
+
+		for _, m := range mixins[1:] {
+			x.warn("hpack-mixin", component, "mixin %s is left out, hpack takes one mixin per dependency", m)
+		}
+
+		addYAML(res, d.Name, spec)
+	}
+
+	addYAML(to, "dependencies", res)
+}
+
+// addConditionals writes if blocks as when entries, using then and else
+// mappings for blocks with an else branch.
+func (x *hpackExport) addConditionals(to *yaml.Node, component string, conditionals []*Conditional) {
+	if len(conditionals) == 0 {
+		return
+	}
+
+	res := &yaml.Node{Kind: yaml.SequenceNode}
+
+	for _, c := range conditionals {
+		when := yamlMapping()
+		addYAMLString(when, "condition", c.Condition)
+
+		if c.Else == nil {
+			x.addBranch(when, component, &c.Then)
+		} else {
+			then, otherwise := yamlMapping(), yamlMapping()
+			x.addBranch(then, component, &c.Then)
+			x.addBranch(otherwise, component, c.Else)
+			addYAML(when, "then", then)
+			addYAML(when, "else", otherwise)
+		}
+
+		res.Content = append(res.Content, when)
+	}
+
+	addYAML(to, "when", res)
+}
+
+func (x *hpackExport) addBranch(to *yaml.Node, component string, b *ConditionalBranch) {
+	if ext := path.Ext(b.MainIs); b.MainIs != "" && ext != ".hs" && ext != ".lhs" {
+		x.warn("hpack-main-is", component, "main-is %s is read by hpack as a module name", b.MainIs)
+	}
+
+	exposed, generatedExposed := splitAutogenModules(b.ExposedModules, b.AutogenModules)
+	other, generatedOther := splitAutogenModules(b.OtherModules, b.AutogenModules)
+
+	x.checkAutogenModules(component, &b.BuildInfo, b.ExposedModules)
+
+	addYAMLString(to, "main", b.MainIs)
+	addYAMLStrings(to, "source-dirs", b.HSSourceDirs)
+	addYAMLStrings(to, "exposed-modules", moduleLines(exposed))
+	addYAMLStrings(to, "generated-exposed-modules", moduleLines(generatedExposed))
+	addYAMLStrings(to, "other-modules", moduleLines(other))
+	addYAMLStrings(to, "generated-other-modules", moduleLines(generatedOther))
+	addYAMLStrings(to, "reexported-modules", reexportLines(b.ReexportedModules))
+	addYAMLStrings(to, "signatures", moduleLines(b.Signatures))
+	x.addBuildInfo(to, component, &b.BuildInfo, &hpackHoisted{})
+}
+
+// checkAutogenModules reports autogen modules hpack can't declare, those
+// which are neither exposed nor other modules.
+func (x *hpackExport) checkAutogenModules(component string, bi *BuildInfo, exposed []ModuleName) {
+	listed := append(append([]ModuleName{}, exposed...), bi.OtherModules...)
+
+	for _, m := range excludeModules(bi.AutogenModules, listed) {
+		x.warn("hpack-autogen-module", component, "autogen module %s is not an exposed or other module", m)
+	}
+}
+
+// splitAutogenModules separates modules into the ones written by the user
+// and the generated ones.
+func splitAutogenModules(modules, autogen []ModuleName) (written, generated []ModuleName) {
+	written = excludeModules(modules, autogen)
+	generated = excludeModules(modules, written)
+
+	return written, generated
+}
+
+func dependencyMixins(bi *BuildInfo, d *Dependency) []*Mixin {
+	res := make([]*Mixin, 0)
+
+	for _, m := range bi.Mixins {
+		if mixinDependency(m) == d.Name {
+			res = append(res, m)
+		}
+	}
+
+	return res
+}
+
+// mixinDependency returns the build-depends name the mixin refers to.
+func mixinDependency(m *Mixin) string {
+	if m.LibraryName != "" {
+		return m.PackageName + ":" + m.LibraryName
+	}
+
+	return m.PackageName
+}
+
+func dependencyStrings(deps []*Dependency) []string {
+	res := make([]string, len(deps))
+
+	for i, d := range deps {
+		res[i] = d.String()
+	}
+
+	return res
+}
+
+// commonStrings returns the values of a which are also in b, in the order
+// of a.
+func commonStrings(a, b []string) []string {
+	res := make([]string, 0, len(a))
+
+	for _, s := range a {
+		if containsString(b, s) {
+			res = append(res, s)
+		}
+	}
+
+	return res
+}
+
+func excludeStrings(values, excluded []string) []string {
+	res := make([]string, 0, len(values))
+
+	for _, s := range values {
+		if !containsString(excluded, s) {
+			res = append(res, s)
+		}
+	}
+
+	return res
+}
+
+func commonPrefix(a, b []string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+
+	return n
+}
+
+func yamlMapping() *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode}
+}
+
+// yamlString returns a string scalar, quoted by the encoder when it would
+// read as another type, and written as a literal block when multi-line.
+func yamlString(s string) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+	if strings.Contains(s, "\n") {
+		n.Style = yaml.LiteralStyle
+	}
+
+	return n
+}
+
+func yamlBool(b bool) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(b)}
+}
+
+func yamlSequence(values []string) *yaml.Node {
+	res := &yaml.Node{Kind: yaml.SequenceNode, Content: make([]*yaml.Node, len(values))}
+	if len(values) == 0 {
+		res.Style = yaml.FlowStyle
+	}
+
+	for i, v := range values {
+		res.Content[i] = yamlString(v)
+	}
+
+	return res
+}
+
+func addYAML(m *yaml.Node, key string, value *yaml.Node) {
+	m.Content = append(m.Content, yamlString(key), value)
+}
+
+func addYAMLString(m *yaml.Node, key, value string) {
+	if value != "" {
+		addYAML(m, key, yamlString(value))
+	}
+}
+
+func addYAMLStrings(m *yaml.Node, key string, values []string) {
+	if len(values) > 0 {
+		addYAML(m, key, yamlSequence(values))
+	}
+}
@@ -0,0 +1,251 @@
+package gocabalparser
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHpackExporter_Export(t *testing.T) {
+	input := `cabal-version: 2.4
+name: example
+version: 1.0
+synopsis: An example
+homepage: https://github.com/example/example#readme
+license: MIT
+author: Alice
+extra-source-files: README.md
+
+source-repository head
+  type: git
+  location: https://github.com/example/example
+
+flag dev
+  description: Development build
+  default: False
+  manual: True
+
+library
+  hs-source-dirs: src
+  exposed-modules: Example
+  other-modules: Example.Internal, Paths_example
+  autogen-modules: Paths_example
+  build-depends: base >= 4 && < 5, text, str-sig
+  mixins: str-sig requires (Str as Str.Text)
+  default-language: Haskell2010
+  ghc-options: -Wall
+  if flag(dev)
+    ghc-options: -O0
+  if os(windows)
+    build-depends: Win32
+  else
+    build-depends: unix
+
+executable example
+  main-is: Main.hs
+  hs-source-dirs: app
+  build-depends: base >= 4 && < 5, example
+  default-language: Haskell2010
+  extensions: CPP
+  ghc-options: -Wall "-with-rtsopts=-N -A64m"
+  if flag(dev)
+    ghc-options: -O0
+`
+
+	expected := `name: example
+version: "1.0"
+synopsis: An example
+github: example/example
+author: Alice
+license: MIT
+extra-source-files:
+  - README.md
+flags:
+  dev:
+    description: Development build
+    manual: true
+    default: false
+dependencies:
+  - base >= 4 && < 5
+language: Haskell2010
+ghc-options:
+  - -Wall
+when:
+  - condition: flag(dev)
+    ghc-options:
+      - -O0
+library:
+  source-dirs:
+    - src
+  exposed-modules:
+    - Example
+  other-modules:
+    - Example.Internal
+  generated-other-modules:
+    - Paths_example
+  dependencies:
+    text:
+    str-sig:
+      mixin:
+        - requires (Str as Str.Text)
+  when:
+    - condition: os(windows)
+      then:
+        dependencies:
+          - Win32
+      else:
+        dependencies:
+          - unix
+executables:
+  example:
+    main: Main.hs
+    source-dirs:
+      - app
+    other-modules: []
+    dependencies:
+      - example
+    ghc-options:
+      - '"-with-rtsopts=-N -A64m"'
+`
+
+	p, err := NewParser().ParseReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	diagnostics, err := NewHpackExporter().Export(&buf, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := buf.String(); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+
+	rules := make([]string, len(diagnostics))
+	for i, d := range diagnostics {
+		rules[i] = d.Component + " " + d.RuleID
+	}
+
+	expectedRules := []string{" hpack-cabal-version", "executable example hpack-extensions-field"}
+	if !reflect.DeepEqual(rules, expectedRules) {
+		t.Fatalf("expected diagnostics %q, got %q", expectedRules, rules)
+	}
+
+	back, err := NewHpackParser(HpackOptions{}).ParseReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(back.Library.Conditionals, p.Library.Conditionals) {
+		t.Fatalf("expected conditionals %+v, got %+v", p.Library.Conditionals, back.Library.Conditionals)
+	}
+
+	if !reflect.DeepEqual(back.Executables["example"].Conditionals, p.Executables["example"].Conditionals) {
+		t.Fatalf("expected conditionals %+v, got %+v", p.Executables["example"].Conditionals, back.Executables["example"].Conditionals)
+	}
+
+	if !reflect.DeepEqual(back.Executables["example"].GHCOptions, []string{"-Wall", "-with-rtsopts=-N -A64m"}) {
+		t.Fatalf("unexpected ghc-options: %q", back.Executables["example"].GHCOptions)
+	}
+
+	if !reflect.DeepEqual(back.Library.Mixins, p.Library.Mixins) {
+		t.Fatalf("expected mixins %v, got %v", p.Library.Mixins, back.Library.Mixins)
+	}
+}
+
+func TestHpackExporter_Export_roundTrip(t *testing.T) {
+	for _, i := range []int{1, 2, 5, 6, 7, 8, 9} {
+		t.Run(fmt.Sprintf("%d.cabal", i), func(t *testing.T) {
+			f, err := os.Open(fmt.Sprintf("./testdata/%d.cabal", i))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer f.Close()
+
+			p, err := NewParser().ParseReader(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+
+			if _, err := NewHpackExporter().Export(&buf, p); err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := NewHpackParser(HpackOptions{}).ParseReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p.CabalVersion = ""
+
+			if !reflect.DeepEqual(actual, p) {
+				t.Fatalf("expected %+v, got %+v", p, actual)
+			}
+		})
+	}
+}
+
+func TestHpackExporter_Export_warnings(t *testing.T) {
+	tcs := map[string]struct {
+		input    string
+		expected []string
+	}{
+		"package url": {
+			input:    "name: a\nversion: 1\npackage-url: http://example.org\n",
+			expected: []string{"hpack-package-url"},
+		},
+		"darcs repository": {
+			input:    "name: a\nversion: 1\n\nsource-repository head\n  type: darcs\n  location: http://example.org\n",
+			expected: []string{"hpack-source-repository"},
+		},
+		"mixin without dependency": {
+			input:    "name: a\nversion: 1\n\nlibrary\n  mixins: str-sig\n",
+			expected: []string{"hpack-mixin"},
+		},
+		"second mixin": {
+			input: "name: a\nversion: 1\n\nlibrary\n  build-depends: str\n" +
+				"  mixins: str (Str as A), str (Str as B)\n",
+			expected: []string{"hpack-mixin"},
+		},
+		"unlisted autogen module": {
+			input:    "name: a\nversion: 1\n\nlibrary\n  autogen-modules: Paths_a\n",
+			expected: []string{"hpack-autogen-module"},
+		},
+		"main module": {
+			input:    "name: a\nversion: 1\n\nexecutable a\n  main-is: Main.hsc\n",
+			expected: []string{"hpack-main-is"},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewParser().ParseReader(strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			diagnostics, err := NewHpackExporter().Export(&bytes.Buffer{}, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rules := make([]string, len(diagnostics))
+			for i, d := range diagnostics {
+				rules[i] = d.RuleID
+			}
+
+			if !reflect.DeepEqual(rules, tc.expected) {
+				t.Fatalf("expected %q, got %q", tc.expected, rules)
+			}
+		})
+	}
+}
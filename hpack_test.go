@@ -0,0 +1,341 @@
+package gocabalparser
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func hpackTestFS(t *testing.T) fstest.MapFS {
+	defaults, err := os.ReadFile("./testdata/hpack/defaults.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fstest.MapFS{
+		"LICENSE":                      {},
+		"defaults.yaml":                {Data: defaults},
+		"src/HpackExample.hs":          {},
+		"src/HpackExample/Internal.hs": {},
+		"app/Main.hs":                  {},
+		"app/Options.hs":               {},
+	}
+}
+
+func TestHpackParser_ParseReader(t *testing.T) {
+	base := mustParseDependency("base >= 4.14 && < 5")
+	paths := []ModuleName{"Paths_hpack_example"}
+	dev := &Conditional{Condition: "flag(dev)", Then: ConditionalBranch{BuildInfo: BuildInfo{GHCOptions: []string{"-Werror"}}}}
+
+	expected := &CabalPackage{
+		Name:             "hpack-example",
+		Version:          "1.10.0",
+		License:          "BSD-3-Clause",
+		LicenseFile:      "LICENSE",
+		Copyright:        []string{"2024 Alice"},
+		Author:           "Alice, Bob",
+		Maintainer:       "alice@example.org",
+		Homepage:         "https://github.com/example/hpack-example#readme",
+		Synopsis:         []string{"An hpack package"},
+		Description:      []string{"First line.", ".", "Second line."},
+		Category:         "Development",
+		TestedWith:       "GHC == 9.4.8, GHC == 9.6.4",
+		ExtraSourceFiles: []string{"README.md"},
+		Repositories: map[string]*SourceRepository{
+			"head": {Type: "git", Location: "https://github.com/example/hpack-example"},
+		},
+		Flags: map[string]*Flag{
+			"dev": {Description: "Development build", Manual: true},
+		},
+		Library: &Library{
+			BuildInfo: BuildInfo{
+				BuildDepends: []*Dependency{
+					base,
+					mustParseDependency("containers >= 0.6"),
+					mustParseDependency("text"),
+					mustParseDependency("str-bytestring ^>= 1.0"),
+				},
+				Mixins: []*Mixin{
+					{
+						PackageName: "str-bytestring",
+						Includes: ModuleRenaming{
+							Kind:    ModuleRenamingExplicit,
+							Renames: []ModuleRename{{From: "Str", To: "Str.ByteString"}},
+						},
+					},
+				},
+				DefaultLanguage:   GHC2021,
+				DefaultExtensions: []string{"OverloadedStrings"},
+				OtherModules:      paths,
+				AutogenModules:    paths,
+				HSSourceDirs:      []string{"src"},
+				GHCOptions:        []string{"-threaded", "-Wall"},
+				Conditionals: []*Conditional{
+					dev,
+					{
+						Condition: "os(windows)",
+						Then:      ConditionalBranch{BuildInfo: BuildInfo{BuildDepends: []*Dependency{mustParseDependency("Win32")}}},
+						Else:      &ConditionalBranch{BuildInfo: BuildInfo{BuildDepends: []*Dependency{mustParseDependency("unix")}}},
+					},
+				},
+			},
+			ExposedModules: []ModuleName{"HpackExample", "HpackExample.Internal"},
+		},
+		Executables: map[string]*Executable{
+			"hpack-example": {
+				BuildInfo: BuildInfo{
+					BuildDepends:      []*Dependency{base, mustParseDependency("hpack-example")},
+					DefaultLanguage:   GHC2021,
+					DefaultExtensions: []string{"OverloadedStrings"},
+					OtherModules:      []ModuleName{"Options", "Paths_hpack_example"},
+					AutogenModules:    paths,
+					HSSourceDirs:      []string{"app"},
+					GHCOptions:        []string{"-threaded"},
+					Conditionals:      []*Conditional{dev},
+				},
+				MainIs: "Main.hs",
+			},
+		},
+	}
+
+	f, err := os.Open("./testdata/hpack/package.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	actual, err := NewHpackParser(HpackOptions{FS: hpackTestFS(t)}).ParseReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestHpackParser_ParseReader_when(t *testing.T) {
+	input := `name: a
+library:
+  source-dirs: src
+  when:
+    - condition: os(windows) && !flag(dev)
+      ghc-options: -O2
+      exposed-modules: Windows
+      when:
+        condition: arch(x86_64)
+        c-sources: cbits/x86.c
+    - condition: impl(ghc >= 9.6)
+      then:
+        dependencies: base >= 4.18
+      else:
+        dependencies: base < 4.18
+`
+
+	fsys := fstest.MapFS{
+		"src/A.hs":       {},
+		"src/Windows.hs": {},
+	}
+
+	p, err := NewHpackParser(HpackOptions{FS: fsys}).ParseReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*Conditional{
+		{
+			Condition: "os(windows) && !flag(dev)",
+			Then: ConditionalBranch{
+				BuildInfo: BuildInfo{
+					GHCOptions: []string{"-O2"},
+					Conditionals: []*Conditional{
+						{Condition: "arch(x86_64)", Then: ConditionalBranch{BuildInfo: BuildInfo{CSources: []string{"cbits/x86.c"}}}},
+					},
+				},
+				ExposedModules: []ModuleName{"Windows"},
+			},
+		},
+		{
+			Condition: "impl(ghc >= 9.6)",
+			Then:      ConditionalBranch{BuildInfo: BuildInfo{BuildDepends: []*Dependency{mustParseDependency("base >= 4.18")}}},
+			Else:      &ConditionalBranch{BuildInfo: BuildInfo{BuildDepends: []*Dependency{mustParseDependency("base < 4.18")}}},
+		},
+	}
+
+	if !reflect.DeepEqual(p.Library.Conditionals, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, p.Library.Conditionals)
+	}
+
+	if !reflect.DeepEqual(p.Library.ExposedModules, []ModuleName{"A"}) {
+		t.Fatalf("unexpected exposed modules: %v", p.Library.ExposedModules)
+	}
+}
+
+func TestHpackParser_ParseReader_shorthand(t *testing.T) {
+	input := `
+name: short
+defaults: owner/defaults@v1
+dependencies: base
+executable:
+  main: Short.Main
+  dependencies:
+    - text >= 2
+    - base < 5
+`
+	cache := fstest.MapFS{
+		"owner/defaults/v1/.hpack/defaults.yaml": {Data: []byte("ghc-options: -Wall\n")},
+	}
+
+	expected := &CabalPackage{
+		Name:    "short",
+		Version: "0.0.0",
+		Executables: map[string]*Executable{
+			"short": {
+				BuildInfo: BuildInfo{
+					BuildDepends: []*Dependency{mustParseDependency("base < 5"), mustParseDependency("text >= 2")},
+					GHCOptions:   []string{"-Wall", "-main-is", "Short.Main"},
+				},
+				MainIs: "Short/Main.hs",
+			},
+		},
+	}
+
+	actual, err := NewHpackParser(HpackOptions{DefaultsCache: cache}).ParseReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %+v, got %+v", expected.Executables["short"], actual.Executables["short"])
+	}
+}
+
+func TestHpackParser_ParseReader_description(t *testing.T) {
+	input := `name: acme
+synopsis: |
+  A package
+
+  for tests
+description: |
+
+  First paragraph
+  on two lines.
+
+
+  Second paragraph.
+
+`
+
+	p, err := NewHpackParser(HpackOptions{}).ParseReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"First paragraph", "on two lines.", ".", "Second paragraph."}
+	if !reflect.DeepEqual(p.Description, expected) {
+		t.Fatalf("expected description %q, got %q", expected, p.Description)
+	}
+
+	if expected := []string{"A package", "for tests"}; !reflect.DeepEqual(p.Synopsis, expected) {
+		t.Fatalf("expected synopsis %q, got %q", expected, p.Synopsis)
+	}
+
+	var buf bytes.Buffer
+
+	if err := NewPrinter().Print(&buf, p); err != nil {
+		t.Fatal(err)
+	}
+
+	back, err := NewParser().ParseReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(back.Description, expected) {
+		t.Fatalf("expected printed description %q, got %q", expected, back.Description)
+	}
+}
+
+func TestHpackParser_Parse_unmodeled(t *testing.T) {
+	input := `name: acme
+includes: acme.h
+cpp-options: -DACME
+library:
+  extra-libraries: z
+  when:
+    - condition: os(windows)
+      then:
+        buildable: false
+      else:
+        pkg-config-dependencies: zlib
+executables:
+  acme:
+    main: Main.hs
+    ld-options: -static
+`
+
+	p, diagnostics, err := NewHpackParser(HpackOptions{}).Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(p.Library.Includes, []string{"acme.h"}) {
+		t.Fatalf("expected includes inherited by the library, got %q", p.Library.Includes)
+	}
+
+	expected := []string{
+		"warning [hpack-unmodeled-field]: cpp-options is not modeled and is left out",
+		"warning [hpack-unmodeled-field]: library: extra-libraries is not modeled and is left out",
+		"warning [hpack-unmodeled-field]: library: buildable is not modeled and is left out",
+		"warning [hpack-unmodeled-field]: library: pkg-config-dependencies is not modeled and is left out",
+		"warning [hpack-unmodeled-field]: executable acme: ld-options is not modeled and is left out",
+	}
+
+	actual := make([]string, 0)
+
+	for _, d := range diagnostics {
+		actual = append(actual, d.String())
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestHpackParser_ParseReader_errors(t *testing.T) {
+	tcs := map[string]string{
+		"not a mapping":        "- name\n",
+		"invalid yaml":         "name: [\n",
+		"bad dependency":       "name: a\nlibrary:\n  dependencies: base >=\n",
+		"bad language":         "name: a\nlanguage: Haskell3000\nlibrary: {}\n",
+		"no condition":         "name: a\nlibrary:\n  when:\n    ghc-options: -O2\n",
+		"bad condition":        "name: a\nlibrary:\n  when:\n    condition: os(linux) &&\n",
+		"missing defaults":     "name: a\ndefaults: owner/repo@v1\n",
+		"defaults without ref": "name: a\ndefaults: owner/repo\n",
+		"bad github":           "name: a\ngithub: example\n",
+		"flag without default": "name: a\nflags:\n  dev:\n    manual: true\n",
+		"flag without manual":  "name: a\nflags:\n  dev:\n    default: false\n",
+		"flag value":           "name: a\nflags:\n  dev:\n    manual: true\n    default: maybe\n",
+	}
+
+	for name, input := range tcs {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewHpackParser(HpackOptions{}).ParseReader(strings.NewReader(input)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func mustParseDependency(s string) *Dependency {
+	d, err := newDependenciesParser().ParseString(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return d
+}
@@ -0,0 +1,376 @@
+package gocabalparser
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+type IndexEntryKind int
+
+const (
+	// IndexEntryCabal is a <pkg>/<version>/<pkg>.cabal file.
+	IndexEntryCabal IndexEntryKind = iota
+	// IndexEntryPreferredVersions is a <pkg>/preferred-versions file.
+	IndexEntryPreferredVersions
+	// IndexEntryPackageJSON is a <pkg>/<version>/package.json file holding
+	// the hashes and length of the source tarball.
+	IndexEntryPackageJSON
+)
+
+// IndexTarget is a file described by package.json, usually the sdist.
+type IndexTarget struct {
+	Length int64
+	Hashes map[string]string
+}
+
+// IndexEntry is a file of the index. Revision counts the earlier .cabal
+// files of the same version, so the original upload is revision 0.
+// Err is set when the file can't be parsed, such as when a field holds an
+// invalid value; the other entries are still read. Common stanzas are
+// inlined and test suites, benchmarks and unmodeled fields skipped as
+// described at SyntaxTree.Package.
+type IndexEntry struct {
+	Kind              IndexEntryKind
+	Package           string
+	Version           Version
+	Revision          int
+	Time              time.Time
+	Data              []byte
+	Cabal             *CabalPackage
+	PreferredVersions *VersionRange
+	Targets           map[string]*IndexTarget
+	Err               error
+}
+
+type IndexReader interface {
+	// Next reads the next entry and reports whether there was one.
+	Next() bool
+	Entry() *IndexEntry
+	// Err returns the error which stopped the reader, if any.
+	Err() error
+}
+
+type indexReader struct {
+	tr        *tar.Reader
+	curr      *IndexEntry
+	err       error
+	revisions map[string]int
+}
+
+// NewIndexReader returns a reader of a Hackage index tarball such as
+// 01-index.tar. The tarball is read entry by entry; wrap r in a gzip
+// reader for 01-index.tar.gz. Files other than cabal files, preferred
+// versions and package.json are skipped.
+func NewIndexReader(r io.Reader) IndexReader {
+	return &indexReader{
+		tr:        tar.NewReader(r),
+		revisions: make(map[string]int),
+	}
+}
+
+func (r *indexReader) Next() bool {
+	r.curr = nil
+
+	for r.err == nil {
+		hdr, err := r.tr.Next()
+		if err == io.EOF {
+			return false
+		}
+
+		if err != nil {
+			r.err = err
+
+			return false
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		entry, ok := newIndexEntry(hdr.Name)
+		if !ok {
+			continue
+		}
+
+		if entry.Data, err = io.ReadAll(r.tr); err != nil {
+			r.err = fmt.Errorf("%s: %v", hdr.Name, err)
+
+			return false
+		}
+
+		entry.Time = hdr.ModTime
+
+		if entry.Err == nil {
+			r.parse(entry)
+		}
+
+		if entry.Err != nil {
+			entry.Err = fmt.Errorf("%s: %v", hdr.Name, entry.Err)
+		}
+
+		r.curr = entry
+
+		return true
+	}
+
+	return false
+}
+
+func (r *indexReader) Entry() *IndexEntry {
+	return r.curr
+}
+
+func (r *indexReader) Err() error {
+	return r.err
+}
+
+func (r *indexReader) parse(entry *IndexEntry) {
+	switch entry.Kind {
+	case IndexEntryCabal:
+		key := entry.Package + "/" + entry.Version.String()
+		entry.Revision = r.revisions[key]
+		r.revisions[key]++
+
+		entry.Cabal, entry.Err = NewParser().ParseReader(bytes.NewReader(entry.Data))
+	case IndexEntryPreferredVersions:
+		entry.PreferredVersions, entry.Err = parsePreferredVersions(entry.Package, entry.Data)
+	case IndexEntryPackageJSON:
+		entry.Targets, entry.Err = parseIndexPackageJSON(entry.Data)
+	}
+}
+
+// newIndexEntry recognizes the entry kind from the file name.
+func newIndexEntry(name string) (*IndexEntry, bool) {
+	parts := strings.Split(path.Clean(name), "/")
+
+	switch {
+	case len(parts) == 2 && parts[1] == "preferred-versions":
+		return &IndexEntry{Kind: IndexEntryPreferredVersions, Package: parts[0]}, true
+	case len(parts) == 3 && (parts[2] == parts[0]+".cabal" || parts[2] == "package.json"):
+		entry := &IndexEntry{Kind: IndexEntryCabal, Package: parts[0]}
+		if parts[2] == "package.json" {
+			entry.Kind = IndexEntryPackageJSON
+		}
+
+		entry.Version, entry.Err = ParseVersion(parts[1])
+
+		return entry, true
+	default:
+		return nil, false
+	}
+}
+
+// parsePreferredVersions parses lines such as pkg < 1.2 || > 1.3, which
+// are combined into a union. Empty files mean no preference and give nil.
+func parsePreferredVersions(pkg string, data []byte) (*VersionRange, error) {
+	var res *VersionRange
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+
+		end := packageNameEnd(line)
+		if line[:end] != pkg {
+			return nil, fmt.Errorf("preferred versions of %s expected, got: %s", pkg, line)
+		}
+
+		r := &VersionRange{Kind: VersionRangeAny}
+
+		if s := strings.TrimSpace(line[end:]); s != "" {
+			var err error
+
+			if r, err = ParseVersionRange(s); err != nil {
+				return nil, err
+			}
+		}
+
+		if res == nil {
+			res = r
+		} else {
+			res = &VersionRange{Kind: VersionRangeUnion, Left: res, Right: r}
+		}
+	}
+
+	return res, scanner.Err()
+}
+
+func parseIndexPackageJSON(data []byte) (map[string]*IndexTarget, error) {
+	var doc struct {
+		Signed struct {
+			Targets map[string]struct {
+				Length int64             `json:"length"`
+				Hashes map[string]string `json:"hashes"`
+			} `json:"targets"`
+		} `json:"signed"`
+	}
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]*IndexTarget, len(doc.Signed.Targets))
+
+	for name, t := range doc.Signed.Targets {
+		res[name] = &IndexTarget{Length: t.Length, Hashes: t.Hashes}
+	}
+
+	return res, nil
+}
+
+// Index holds the packages of a Hackage index with every revision of
+// their cabal files.
+type Index struct {
+	packages map[string]*indexPackage
+}
+
+type indexPackage struct {
+	preferred *VersionRange
+	releases  map[string]*IndexRelease
+}
+
+// IndexRelease is a version of a package. Revisions holds its cabal files
+// in upload order, starting with the original; entries which failed to
+// parse are kept with their error so revision numbers stay intact.
+type IndexRelease struct {
+	Version   Version
+	Revisions []*IndexEntry
+	Targets   map[string]*IndexTarget
+}
+
+func NewIndex() *Index {
+	return &Index{packages: make(map[string]*indexPackage)}
+}
+
+// ReadIndex reads a whole index tarball into memory.
+func ReadIndex(r io.Reader) (*Index, error) {
+	res := NewIndex()
+	reader := NewIndexReader(r)
+
+	for reader.Next() {
+		res.Add(reader.Entry())
+	}
+
+	if err := reader.Err(); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Add adds an entry read from an index. Cabal file entries are appended
+// as the next revision of their version; entries without a version are
+// ignored.
+func (idx *Index) Add(entry *IndexEntry) {
+	pkg := idx.packages[entry.Package]
+	if pkg == nil {
+		pkg = &indexPackage{releases: make(map[string]*IndexRelease)}
+		idx.packages[entry.Package] = pkg
+	}
+
+	if entry.Kind == IndexEntryPreferredVersions {
+		if entry.Err == nil {
+			pkg.preferred = entry.PreferredVersions
+		}
+
+		return
+	}
+
+	if entry.Version == nil {
+		return
+	}
+
+	key := entry.Version.String()
+
+	release := pkg.releases[key]
+	if release == nil {
+		release = &IndexRelease{Version: entry.Version}
+		pkg.releases[key] = release
+	}
+
+	switch entry.Kind {
+	case IndexEntryCabal:
+		entry.Revision = len(release.Revisions)
+		release.Revisions = append(release.Revisions, entry)
+	case IndexEntryPackageJSON:
+		release.Targets = entry.Targets
+	}
+}
+
+// Packages returns the sorted package names.
+func (idx *Index) Packages() []string {
+	return sortedKeys(idx.packages)
+}
+
+// Releases returns the versions of a package with at least one cabal
+// file, in ascending order.
+func (idx *Index) Releases(name string) []*IndexRelease {
+	pkg := idx.packages[name]
+	if pkg == nil {
+		return nil
+	}
+
+	res := make([]*IndexRelease, 0, len(pkg.releases))
+
+	for _, r := range pkg.releases {
+		if len(r.Revisions) > 0 {
+			res = append(res, r)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Version.Compare(res[j].Version) < 0
+	})
+
+	return res
+}
+
+// Release returns a version of a package, or nil if it has no cabal file.
+func (idx *Index) Release(name string, v Version) *IndexRelease {
+	pkg := idx.packages[name]
+	if pkg == nil {
+		return nil
+	}
+
+	r := pkg.releases[v.String()]
+	if r == nil || len(r.Revisions) == 0 {
+		return nil
+	}
+
+	return r
+}
+
+// PreferredVersions returns the preferred versions of a package, or nil
+// when all versions are preferred.
+func (idx *Index) PreferredVersions(name string) *VersionRange {
+	if pkg := idx.packages[name]; pkg != nil {
+		return pkg.preferred
+	}
+
+	return nil
+}
+
+// Latest returns the last revision.
+func (r *IndexRelease) Latest() *IndexEntry {
+	return r.Revisions[len(r.Revisions)-1]
+}
+
+// Revision returns a revision by number, 0 being the original upload.
+func (r *IndexRelease) Revision(n int) (*IndexEntry, bool) {
+	if n < 0 || n >= len(r.Revisions) {
+		return nil, false
+	}
+
+	return r.Revisions[n], true
+}
@@ -0,0 +1,281 @@
+package gocabalparser
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type indexTestFile struct {
+	name string
+	data string
+}
+
+func indexTestTar(t *testing.T, files []indexTestFile) *bytes.Buffer {
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+
+	for i, f := range files {
+		hdr := &tar.Header{
+			Name:     f.name,
+			Mode:     0644,
+			Size:     int64(len(f.data)),
+			ModTime:  time.Unix(int64(1700000000+i), 0),
+			Typeflag: tar.TypeReg,
+		}
+
+		if strings.HasSuffix(f.name, "/") {
+			hdr.Typeflag, hdr.Size = tar.TypeDir, 0
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write([]byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &buf
+}
+
+// indexTestCabal has the sections and fields of a typical upload the
+// parser skips or inlines.
+const indexTestCabal = `cabal-version: 3.0
+name:          acme
+version:       1.1
+x-curation:    uncurated
+
+common warnings
+  ghc-options: -Wall
+
+library
+  import:           warnings
+  exposed-modules:  Acme
+  build-depends:    base >= 4.14 && < 5
+  build-tool-depends: hspec-discover:hspec-discover
+  default-language: Haskell2010
+
+test-suite spec
+  import:         warnings
+  type:           exitcode-stdio-1.0
+  main-is:        Spec.hs
+  build-depends:  base, acme, hspec
+  if os(windows)
+    buildable: False
+
+benchmark bench
+  type:          exitcode-stdio-1.0
+  main-is:       Bench.hs
+  build-depends: base, acme
+`
+
+func TestIndexReader(t *testing.T) {
+	buf := indexTestTar(t, []indexTestFile{
+		{"acme/", ""},
+		{"acme/1.0/acme.cabal", "name: acme\nversion: 1.0\n"},
+		{"acme/1.0/package.json", `{"signatures":[],"signed":{"_type":"Targets","expires":null,` +
+			`"targets":{"<repo>/package/acme-1.0.tar.gz":{"hashes":{"md5":"aa","sha256":"bb"},"length":42}},"version":0}}`},
+		{"acme/1.0/acme.cabal", "name: acme\nversion: 1.0\nsynopsis: Revised\n"},
+		{"acme/1.1/acme.cabal", indexTestCabal},
+		{"acme/1.2/acme.cabal", "name: acme\nversion: 1.2\nunknown-field: x\n"},
+		{"acme/preferred-versions", "-- deprecated\nacme < 1.1\nacme == 1.2.*\n"},
+		{"other/preferred-versions", ""},
+		{"acme/1.0/README", "skipped"},
+	})
+
+	r := NewIndexReader(buf)
+
+	var entries []*IndexEntry
+	for r.Next() {
+		entries = append(entries, r.Entry())
+	}
+
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	summary := make([]string, len(entries))
+	for i, e := range entries {
+		summary[i] = e.Package + " " + e.Version.String()
+	}
+
+	expected := []string{"acme 1.0", "acme 1.0", "acme 1.0", "acme 1.1", "acme 1.2", "acme ", "other "}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Fatalf("expected %q, got %q", expected, summary)
+	}
+
+	if e := entries[0]; e.Kind != IndexEntryCabal || e.Revision != 0 || e.Err != nil || e.Cabal.Name != "acme" {
+		t.Fatalf("unexpected first entry: %+v", e)
+	}
+
+	if e := entries[0]; !e.Time.Equal(time.Unix(1700000001, 0)) {
+		t.Fatalf("unexpected time: %v", e.Time)
+	}
+
+	expectedTargets := map[string]*IndexTarget{
+		"<repo>/package/acme-1.0.tar.gz": {Length: 42, Hashes: map[string]string{"md5": "aa", "sha256": "bb"}},
+	}
+
+	if e := entries[1]; e.Kind != IndexEntryPackageJSON || !reflect.DeepEqual(e.Targets, expectedTargets) {
+		t.Fatalf("unexpected package.json entry: %+v", e)
+	}
+
+	if e := entries[2]; e.Revision != 1 || e.Err != nil || !reflect.DeepEqual(e.Cabal.Synopsis, []string{"Revised"}) {
+		t.Fatalf("unexpected revision entry: %+v", e)
+	}
+
+	if e := entries[3]; e.Err != nil || !reflect.DeepEqual(e.Cabal.Library.BuildDepends, []*Dependency{mustParseDependency("base >= 4.14 && < 5")}) {
+		t.Fatalf("unexpected entry with a test suite: %+v", e)
+	}
+
+	if e := entries[3]; !reflect.DeepEqual(e.Cabal.Library.GHCOptions, []string{"-Wall"}) {
+		t.Fatalf("expected imported ghc-options, got %q", e.Cabal.Library.GHCOptions)
+	}
+
+	if e := entries[4]; e.Revision != 0 || e.Err == nil || e.Data == nil {
+		t.Fatalf("expected parse error with data, got %+v", e)
+	}
+
+	if e := entries[5]; e.Kind != IndexEntryPreferredVersions || e.PreferredVersions.String() != "< 1.1 || == 1.2.*" {
+		t.Fatalf("unexpected preferred versions: %+v", e)
+	}
+
+	if e := entries[6]; e.Err != nil || e.PreferredVersions != nil {
+		t.Fatalf("expected no preference, got %+v", e)
+	}
+}
+
+func TestIndexReader_hackageFiles(t *testing.T) {
+	files := make([]indexTestFile, 0)
+
+	for _, name := range []string{"1.cabal", "6.cabal", "8.cabal", "9.cabal", "10.cabal", "11.cabal"} {
+		data, err := os.ReadFile("./testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pkg := "pkg" + strings.TrimSuffix(name, ".cabal")
+		files = append(files, indexTestFile{pkg + "/1.0/" + pkg + ".cabal", string(data)})
+	}
+
+	r := NewIndexReader(indexTestTar(t, files))
+
+	n := 0
+	for r.Next() {
+		if e := r.Entry(); e.Err != nil || e.Cabal == nil {
+			t.Fatalf("%s: %v", files[n].name, e.Err)
+		}
+
+		n++
+	}
+
+	if r.Err() != nil || n != len(files) {
+		t.Fatalf("expected %d entries, got %d and %v", len(files), n, r.Err())
+	}
+}
+
+func TestIndexReader_errors(t *testing.T) {
+	buf := indexTestTar(t, []indexTestFile{
+		{"acme/x/acme.cabal", "name: acme\n"},
+		{"acme/preferred-versions", "other < 1\n"},
+		{"acme/1.0/package.json", "{"},
+	})
+
+	r := NewIndexReader(buf)
+
+	n := 0
+	for r.Next() {
+		if r.Entry().Err == nil {
+			t.Fatalf("expected error for %s", r.Entry().Package)
+		}
+
+		n++
+	}
+
+	if r.Err() != nil || n != 3 {
+		t.Fatalf("expected 3 entries with errors, got %d and %v", n, r.Err())
+	}
+
+	r = NewIndexReader(strings.NewReader("not a tarball, but long enough to hold a header block"))
+	if r.Next() || r.Err() == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestReadIndex(t *testing.T) {
+	buf := indexTestTar(t, []indexTestFile{
+		{"acme/1.0/acme.cabal", "name: acme\nversion: 1.0\n"},
+		{"acme/1.10/acme.cabal", "name: acme\nversion: 1.10\n"},
+		{"acme/1.0/acme.cabal", "name: acme\nversion: 1.0\nx-revision: 1\n"},
+		{"acme/1.2/package.json", `{"signed":{"targets":{"acme-1.2.tar.gz":{"length":1}}}}`},
+		{"acme/1.0/acme.cabal", "name: acme\nversion: 1.0\nx-revision: 2\nsynopsis: Latest\n"},
+		{"acme/preferred-versions", "acme < 1.10\n"},
+		{"base/4.18.0.0/base.cabal", "name: base\nversion: 4.18.0.0\n"},
+	})
+
+	idx, err := ReadIndex(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if packages := idx.Packages(); !reflect.DeepEqual(packages, []string{"acme", "base"}) {
+		t.Fatalf("unexpected packages: %q", packages)
+	}
+
+	releases := idx.Releases("acme")
+
+	versions := make([]string, len(releases))
+	for i, r := range releases {
+		versions[i] = r.Version.String()
+	}
+
+	if !reflect.DeepEqual(versions, []string{"1.0", "1.10"}) {
+		t.Fatalf("expected releases with cabal files in order, got %q", versions)
+	}
+
+	release := idx.Release("acme", Version{1, 0})
+	if release == nil || len(release.Revisions) != 3 {
+		t.Fatalf("expected 3 revisions, got %+v", release)
+	}
+
+	for i, e := range release.Revisions {
+		if e.Revision != i || e.Cabal.Revision != i {
+			t.Fatalf("revision %d: got entry %d with x-revision %d", i, e.Revision, e.Cabal.Revision)
+		}
+	}
+
+	if latest := release.Latest(); !reflect.DeepEqual(latest.Cabal.Synopsis, []string{"Latest"}) {
+		t.Fatalf("unexpected latest revision: %+v", latest.Cabal)
+	}
+
+	if e, ok := release.Revision(1); !ok || e.Cabal.Revision != 1 {
+		t.Fatalf("unexpected revision 1: %+v", e)
+	}
+
+	if _, ok := release.Revision(3); ok {
+		t.Fatal("expected no revision 3")
+	}
+
+	if idx.Release("acme", Version{1, 2}) != nil || idx.Release("missing", Version{1}) != nil {
+		t.Fatal("expected no release without cabal file")
+	}
+
+	if r := idx.PreferredVersions("acme"); r == nil || r.String() != "< 1.10" {
+		t.Fatalf("unexpected preferred versions: %v", r)
+	}
+
+	if idx.PreferredVersions("base") != nil {
+		t.Fatal("expected no preferred versions for base")
+	}
+}
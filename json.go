@@ -0,0 +1,698 @@
+package gocabalparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JSONSchemaVersion is the version of the JSON representation of
+// CabalPackage. It is increased on every incompatible change and checked
+// when decoding.
+const JSONSchemaVersion = 1
+
+const (
+	jsonComponentLibrary    = "library"
+	jsonComponentExecutable = "executable"
+)
+
+type jsonPackage struct {
+	SchemaVersion      int                     `json:"schemaVersion" doc:"Version of the representation, see JSONSchemaVersion."`
+	Name               string                  `json:"name"`
+	Version            string                  `json:"version"`
+	Revision           int                     `json:"revision,omitempty" doc:"Hackage metadata revision, the x-revision field."`
+	CabalVersion       string                  `json:"cabalVersion,omitempty"`
+	BuildType          string                  `json:"buildType,omitempty"`
+	License            string                  `json:"license,omitempty"`
+	LicenseFile        string                  `json:"licenseFile,omitempty"`
+	Copyright          []string                `json:"copyright,omitempty" doc:"Lines of the copyright field."`
+	Author             string                  `json:"author,omitempty"`
+	Maintainer         string                  `json:"maintainer,omitempty"`
+	Stability          string                  `json:"stability,omitempty"`
+	Homepage           string                  `json:"homepage,omitempty"`
+	PackageURL         string                  `json:"packageUrl,omitempty"`
+	Synopsis           []string                `json:"synopsis,omitempty" doc:"Lines of the synopsis field."`
+	Description        []string                `json:"description,omitempty" doc:"Lines of the description field."`
+	Category           string                  `json:"category,omitempty"`
+	TestedWith         string                  `json:"testedWith,omitempty"`
+	DataDir            string                  `json:"dataDir,omitempty"`
+	DataFiles          []string                `json:"dataFiles,omitempty"`
+	ExtraSourceFiles   []string                `json:"extraSourceFiles,omitempty"`
+	ExtraDocFiles      []string                `json:"extraDocFiles,omitempty"`
+	SourceRepositories []*jsonSourceRepository `json:"sourceRepositories" doc:"Source repositories ordered by kind."`
+	Flags              []*jsonFlag             `json:"flags,omitempty" doc:"Flags ordered by name."`
+	Components         []*jsonComponent        `json:"components" doc:"The main library, sub-libraries and executables, each group ordered by name."`
+}
+
+type jsonSourceRepository struct {
+	Kind     string `json:"kind" doc:"Repository kind, e.g. head or this."`
+	Type     string `json:"type,omitempty"`
+	Location string `json:"location,omitempty"`
+	Tag      string `json:"tag,omitempty"`
+}
+
+type jsonFlag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     bool   `json:"default"`
+	Manual      bool   `json:"manual"`
+}
+
+type jsonComponent struct {
+	Type              string          `json:"type" enum:"library,executable"`
+	Name              string          `json:"name,omitempty" doc:"Component name, absent for the main library."`
+	ExposedModules    []ModuleName    `json:"exposedModules,omitempty"`
+	ReexportedModules []*jsonReexport `json:"reexportedModules,omitempty"`
+	Signatures        []ModuleName    `json:"signatures,omitempty"`
+	MainIs            string          `json:"mainIs,omitempty"`
+	jsonBuildInfo
+}
+
+type jsonBuildInfo struct {
+	BuildDepends      []*jsonDependency  `json:"buildDepends,omitempty"`
+	Mixins            []*jsonMixin       `json:"mixins,omitempty"`
+	DefaultLanguage   string             `json:"defaultLanguage,omitempty"`
+	Extensions        []string           `json:"extensions,omitempty"`
+	DefaultExtensions []string           `json:"defaultExtensions,omitempty"`
+	OtherExtensions   []string           `json:"otherExtensions,omitempty"`
+	OtherModules      []ModuleName       `json:"otherModules,omitempty"`
+	AutogenModules    []ModuleName       `json:"autogenModules,omitempty"`
+	HSSourceDirs      []string           `json:"hsSourceDirs,omitempty"`
+	GHCOptions        []string           `json:"ghcOptions,omitempty"`
+	CSources          []string           `json:"cSources,omitempty"`
+	IncludeDirs       []string           `json:"includeDirs,omitempty"`
+	Includes          []string           `json:"includes,omitempty"`
+	InstallIncludes   []string           `json:"installIncludes,omitempty"`
+	Conditionals      []*jsonConditional `json:"conditionals,omitempty"`
+}
+
+type jsonConditional struct {
+	Condition string      `json:"condition" doc:"Condition in cabal syntax, e.g. os(windows) && !flag(dev)."`
+	Then      *jsonBranch `json:"then"`
+	Else      *jsonBranch `json:"else,omitempty"`
+}
+
+type jsonBranch struct {
+	ExposedModules    []ModuleName    `json:"exposedModules,omitempty"`
+	ReexportedModules []*jsonReexport `json:"reexportedModules,omitempty"`
+	Signatures        []ModuleName    `json:"signatures,omitempty"`
+	MainIs            string          `json:"mainIs,omitempty"`
+	jsonBuildInfo
+}
+
+type jsonDependency struct {
+	Package    string            `json:"package"`
+	Constraint string            `json:"constraint" doc:"Version range in cabal syntax, -any when unconstrained."`
+	Range      *jsonVersionRange `json:"range"`
+}
+
+type jsonVersionRange struct {
+	Op       string              `json:"op" enum:"any,none,eq,gt,ge,lt,le,wildcard,majorBound,union,intersection"`
+	Version  string              `json:"version,omitempty" doc:"Version of bound operators; for wildcard the prefix before .*."`
+	Operands []*jsonVersionRange `json:"operands,omitempty" doc:"At least two ranges combined by union or intersection."`
+}
+
+type jsonMixin struct {
+	Package  string              `json:"package"`
+	Library  string              `json:"library,omitempty"`
+	Includes *jsonModuleRenaming `json:"includes,omitempty" doc:"Absent when all modules are included under their own names."`
+	Requires *jsonModuleRenaming `json:"requires,omitempty" doc:"Absent when all signatures are required under their own names."`
+}
+
+type jsonModuleRenaming struct {
+	Kind    string             `json:"kind" enum:"explicit,hiding"`
+	Renames []jsonModuleRename `json:"renames,omitempty"`
+	Hiding  []ModuleName       `json:"hiding,omitempty"`
+}
+
+type jsonModuleRename struct {
+	From ModuleName `json:"from"`
+	To   ModuleName `json:"to"`
+}
+
+type jsonReexport struct {
+	Package string     `json:"package,omitempty"`
+	Module  ModuleName `json:"module"`
+	As      ModuleName `json:"as"`
+}
+
+// MarshalJSON encodes the package in the representation described by
+// JSONSchema.
+func (p CabalPackage) MarshalJSON() ([]byte, error) {
+	res := &jsonPackage{
+		SchemaVersion:      JSONSchemaVersion,
+		Name:               p.Name,
+		Version:            p.Version,
+		Revision:           p.Revision,
+		CabalVersion:       p.CabalVersion,
+		BuildType:          p.BuildType,
+		License:            p.License,
+		LicenseFile:        p.LicenseFile,
+		Copyright:          p.Copyright,
+		Author:             p.Author,
+		Maintainer:         p.Maintainer,
+		Stability:          p.Stability,
+		Homepage:           p.Homepage,
+		PackageURL:         p.PackageURL,
+		Synopsis:           p.Synopsis,
+		Description:        p.Description,
+		Category:           p.Category,
+		TestedWith:         p.TestedWith,
+		DataDir:            p.DataDir,
+		DataFiles:          p.DataFiles,
+		ExtraSourceFiles:   p.ExtraSourceFiles,
+		ExtraDocFiles:      p.ExtraDocFiles,
+		SourceRepositories: make([]*jsonSourceRepository, 0, len(p.Repositories)),
+		Components:         make([]*jsonComponent, 0),
+	}
+
+	for _, kind := range sortedKeys(p.Repositories) {
+		r := p.Repositories[kind]
+		res.SourceRepositories = append(res.SourceRepositories, &jsonSourceRepository{
+			Kind:     kind,
+			Type:     r.Type,
+			Location: r.Location,
+			Tag:      r.Tag,
+		})
+	}
+
+	for _, name := range sortedKeys(p.Flags) {
+		f := p.Flags[name]
+		res.Flags = append(res.Flags, &jsonFlag{
+			Name:        name,
+			Description: f.Description,
+			Default:     f.Default,
+			Manual:      f.Manual,
+		})
+	}
+
+	if p.Library != nil {
+		res.Components = append(res.Components, newJSONLibrary("", p.Library))
+	}
+
+	for _, name := range sortedKeys(p.SubLibraries) {
+		res.Components = append(res.Components, newJSONLibrary(name, p.SubLibraries[name]))
+	}
+
+	for _, name := range sortedKeys(p.Executables) {
+		e := p.Executables[name]
+		c := newJSONComponent(jsonComponentExecutable, name, &e.BuildInfo)
+		c.MainIs = e.MainIs
+		res.Components = append(res.Components, c)
+	}
+
+	return marshalJSON(res)
+}
+
+// UnmarshalJSON decodes a package encoded by MarshalJSON.
+func (p *CabalPackage) UnmarshalJSON(data []byte) error {
+	var src jsonPackage
+
+	if err := json.Unmarshal(data, &src); err != nil {
+		return err
+	}
+
+	if src.SchemaVersion != JSONSchemaVersion {
+		return fmt.Errorf("unsupported schema version: %d", src.SchemaVersion)
+	}
+
+	res := CabalPackage{
+		Name:             src.Name,
+		Version:          src.Version,
+		Revision:         src.Revision,
+		CabalVersion:     src.CabalVersion,
+		BuildType:        src.BuildType,
+		License:          src.License,
+		LicenseFile:      src.LicenseFile,
+		Copyright:        src.Copyright,
+		Author:           src.Author,
+		Maintainer:       src.Maintainer,
+		Stability:        src.Stability,
+		Homepage:         src.Homepage,
+		PackageURL:       src.PackageURL,
+		Synopsis:         src.Synopsis,
+		Description:      src.Description,
+		Category:         src.Category,
+		TestedWith:       src.TestedWith,
+		DataDir:          src.DataDir,
+		DataFiles:        src.DataFiles,
+		ExtraSourceFiles: src.ExtraSourceFiles,
+		ExtraDocFiles:    src.ExtraDocFiles,
+	}
+
+	for _, r := range src.SourceRepositories {
+		if res.Repositories == nil {
+			res.Repositories = make(map[string]*SourceRepository)
+		}
+
+		res.Repositories[r.Kind] = &SourceRepository{Type: r.Type, Location: r.Location, Tag: r.Tag}
+	}
+
+	for _, f := range src.Flags {
+		if f.Name == "" {
+			return fmt.Errorf("flag name expected")
+		}
+
+		if res.Flags == nil {
+			res.Flags = make(map[string]*Flag)
+		}
+
+		res.Flags[f.Name] = &Flag{Description: f.Description, Default: f.Default, Manual: f.Manual}
+	}
+
+	for _, c := range src.Components {
+		if err := c.addTo(&res); err != nil {
+			return err
+		}
+	}
+
+	*p = res
+
+	return nil
+}
+
+// MarshalJSON encodes the dependency with its range in both cabal syntax
+// and structured form.
+func (d Dependency) MarshalJSON() ([]byte, error) {
+	return marshalJSON(newJSONDependency(&d))
+}
+
+func (d *Dependency) UnmarshalJSON(data []byte) error {
+	var src jsonDependency
+
+	if err := json.Unmarshal(data, &src); err != nil {
+		return err
+	}
+
+	res, err := src.dependency()
+	if err != nil {
+		return err
+	}
+
+	*d = *res
+
+	return nil
+}
+
+// MarshalJSON encodes the range in structured form.
+func (r VersionRange) MarshalJSON() ([]byte, error) {
+	return marshalJSON(newJSONVersionRange(&r))
+}
+
+func (r *VersionRange) UnmarshalJSON(data []byte) error {
+	var src jsonVersionRange
+
+	if err := json.Unmarshal(data, &src); err != nil {
+		return err
+	}
+
+	res, err := src.versionRange()
+	if err != nil {
+		return err
+	}
+
+	*r = *res
+
+	return nil
+}
+
+func newJSONLibrary(name string, l *Library) *jsonComponent {
+	c := newJSONComponent(jsonComponentLibrary, name, &l.BuildInfo)
+	c.ExposedModules = l.ExposedModules
+	c.ReexportedModules = newJSONReexports(l.ReexportedModules)
+	c.Signatures = l.Signatures
+
+	return c
+}
+
+func newJSONReexports(reexports []*ModuleReexport) []*jsonReexport {
+	var res []*jsonReexport
+
+	for _, r := range reexports {
+		res = append(res, &jsonReexport{
+			Package: r.OriginalPackage,
+			Module:  r.OriginalName,
+			As:      r.NewName,
+		})
+	}
+
+	return res
+}
+
+func moduleReexports(reexports []*jsonReexport) []*ModuleReexport {
+	var res []*ModuleReexport
+
+	for _, r := range reexports {
+		res = append(res, &ModuleReexport{
+			OriginalPackage: r.Package,
+			OriginalName:    r.Module,
+			NewName:         r.As,
+		})
+	}
+
+	return res
+}
+
+func newJSONBranch(b *ConditionalBranch) *jsonBranch {
+	return &jsonBranch{
+		ExposedModules:    b.ExposedModules,
+		ReexportedModules: newJSONReexports(b.ReexportedModules),
+		Signatures:        b.Signatures,
+		MainIs:            b.MainIs,
+		jsonBuildInfo:     *newJSONBuildInfo(&b.BuildInfo),
+	}
+}
+
+func (b *jsonBranch) branch() (*ConditionalBranch, error) {
+	bi, err := b.buildInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConditionalBranch{
+		BuildInfo:         *bi,
+		ExposedModules:    b.ExposedModules,
+		ReexportedModules: moduleReexports(b.ReexportedModules),
+		Signatures:        b.Signatures,
+		MainIs:            b.MainIs,
+	}, nil
+}
+
+func newJSONComponent(typ, name string, bi *BuildInfo) *jsonComponent {
+	return &jsonComponent{Type: typ, Name: name, jsonBuildInfo: *newJSONBuildInfo(bi)}
+}
+
+func newJSONBuildInfo(bi *BuildInfo) *jsonBuildInfo {
+	res := &jsonBuildInfo{
+		DefaultLanguage:   string(bi.DefaultLanguage),
+		Extensions:        bi.Extensions,
+		DefaultExtensions: bi.DefaultExtensions,
+		OtherExtensions:   bi.OtherExtensions,
+		OtherModules:      bi.OtherModules,
+		AutogenModules:    bi.AutogenModules,
+		HSSourceDirs:      bi.HSSourceDirs,
+		GHCOptions:        bi.GHCOptions,
+		CSources:          bi.CSources,
+		IncludeDirs:       bi.IncludeDirs,
+		Includes:          bi.Includes,
+		InstallIncludes:   bi.InstallIncludes,
+	}
+
+	for _, d := range bi.BuildDepends {
+		res.BuildDepends = append(res.BuildDepends, newJSONDependency(d))
+	}
+
+	for _, m := range bi.Mixins {
+		res.Mixins = append(res.Mixins, &jsonMixin{
+			Package:  m.PackageName,
+			Library:  m.LibraryName,
+			Includes: newJSONModuleRenaming(m.Includes),
+			Requires: newJSONModuleRenaming(m.Requires),
+		})
+	}
+
+	for _, c := range bi.Conditionals {
+		jc := &jsonConditional{Condition: c.Condition, Then: newJSONBranch(&c.Then)}
+		if c.Else != nil {
+			jc.Else = newJSONBranch(c.Else)
+		}
+
+		res.Conditionals = append(res.Conditionals, jc)
+	}
+
+	return res
+}
+
+func (bi *jsonBuildInfo) buildInfo() (*BuildInfo, error) {
+	res := &BuildInfo{
+		DefaultLanguage:   Language(bi.DefaultLanguage),
+		Extensions:        bi.Extensions,
+		DefaultExtensions: bi.DefaultExtensions,
+		OtherExtensions:   bi.OtherExtensions,
+		OtherModules:      bi.OtherModules,
+		AutogenModules:    bi.AutogenModules,
+		HSSourceDirs:      bi.HSSourceDirs,
+		GHCOptions:        bi.GHCOptions,
+		CSources:          bi.CSources,
+		IncludeDirs:       bi.IncludeDirs,
+		Includes:          bi.Includes,
+		InstallIncludes:   bi.InstallIncludes,
+	}
+
+	for _, d := range bi.BuildDepends {
+		dep, err := d.dependency()
+		if err != nil {
+			return nil, err
+		}
+
+		res.BuildDepends = append(res.BuildDepends, dep)
+	}
+
+	for _, m := range bi.Mixins {
+		includes, err := m.Includes.moduleRenaming()
+		if err != nil {
+			return nil, err
+		}
+
+		requires, err := m.Requires.moduleRenaming()
+		if err != nil {
+			return nil, err
+		}
+
+		res.Mixins = append(res.Mixins, &Mixin{
+			PackageName: m.Package,
+			LibraryName: m.Library,
+			Includes:    includes,
+			Requires:    requires,
+		})
+	}
+
+	for _, c := range bi.Conditionals {
+		if c.Then == nil {
+			return nil, fmt.Errorf("then branch expected for %s", c.Condition)
+		}
+
+		if _, err := (&Platform{}).EvalCondition(c.Condition); err != nil {
+			return nil, err
+		}
+
+		then, err := c.Then.branch()
+		if err != nil {
+			return nil, err
+		}
+
+		res.Conditionals = append(res.Conditionals, &Conditional{Condition: c.Condition, Then: *then})
+
+		if c.Else != nil {
+			if res.Conditionals[len(res.Conditionals)-1].Else, err = c.Else.branch(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func (c *jsonComponent) addTo(p *CabalPackage) error {
+	info, err := c.buildInfo()
+	if err != nil {
+		return err
+	}
+
+	bi := *info
+
+	switch c.Type {
+	case jsonComponentLibrary:
+		lib := &Library{
+			BuildInfo:         bi,
+			ExposedModules:    c.ExposedModules,
+			ReexportedModules: moduleReexports(c.ReexportedModules),
+			Signatures:        c.Signatures,
+		}
+
+		if c.Name == "" {
+			if p.Library != nil {
+				return fmt.Errorf("duplicate main library")
+			}
+
+			p.Library = lib
+
+			return nil
+		}
+
+		if p.SubLibraries == nil {
+			p.SubLibraries = make(map[string]*Library)
+		}
+
+		p.SubLibraries[c.Name] = lib
+	case jsonComponentExecutable:
+		if c.Name == "" {
+			return fmt.Errorf("executable name expected")
+		}
+
+		if p.Executables == nil {
+			p.Executables = make(map[string]*Executable)
+		}
+
+		p.Executables[c.Name] = &Executable{BuildInfo: bi, MainIs: c.MainIs}
+	default:
+		return fmt.Errorf("unknown component type: %s", c.Type)
+	}
+
+	return nil
+}
+
+func newJSONDependency(d *Dependency) *jsonDependency {
+	r := d.VersionRange()
+
+	return &jsonDependency{
+		Package:    d.Name,
+		Constraint: r.String(),
+		Range:      newJSONVersionRange(r),
+	}
+}
+
+// dependency decodes the dependency from the structured range, falling
+// back to the constraint when the range is absent.
+func (d *jsonDependency) dependency() (*Dependency, error) {
+	if d.Package == "" {
+		return nil, fmt.Errorf("dependency package expected")
+	}
+
+	if d.Range != nil {
+		r, err := d.Range.versionRange()
+		if err != nil {
+			return nil, err
+		}
+
+		return newDependency(d.Package, r), nil
+	}
+
+	if strings.TrimSpace(d.Constraint) == "" {
+		return newDependency(d.Package, &VersionRange{Kind: VersionRangeAny}), nil
+	}
+
+	r, err := ParseVersionRange(d.Constraint)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDependency(d.Package, r), nil
+}
+
+// newJSONVersionRange flattens nested unions and intersections into a
+// single list of operands.
+func newJSONVersionRange(r *VersionRange) *jsonVersionRange {
+	res := &jsonVersionRange{Op: r.Kind.String()}
+
+	switch r.Kind {
+	case VersionRangeAny, VersionRangeNone:
+	case VersionRangeUnion, VersionRangeIntersection:
+		for _, o := range []*VersionRange{r.Left, r.Right} {
+			if operand := newJSONVersionRange(o); o.Kind == r.Kind {
+				res.Operands = append(res.Operands, operand.Operands...)
+			} else {
+				res.Operands = append(res.Operands, operand)
+			}
+		}
+	default:
+		res.Version = r.Version.String()
+	}
+
+	return res
+}
+
+func (r *jsonVersionRange) versionRange() (*VersionRange, error) {
+	for kind := VersionRangeAny; kind <= VersionRangeIntersection; kind++ {
+		if kind.String() != r.Op {
+			continue
+		}
+
+		switch kind {
+		case VersionRangeAny, VersionRangeNone:
+			return &VersionRange{Kind: kind}, nil
+		case VersionRangeUnion, VersionRangeIntersection:
+			if len(r.Operands) < 2 {
+				return nil, fmt.Errorf("at least two operands expected for %s", r.Op)
+			}
+
+			var res *VersionRange
+
+			for _, o := range r.Operands {
+				operand, err := o.versionRange()
+				if err != nil {
+					return nil, err
+				}
+
+				if res == nil {
+					res = operand
+				} else {
+					res = &VersionRange{Kind: kind, Left: res, Right: operand}
+				}
+			}
+
+			return res, nil
+		default:
+			v, err := ParseVersion(r.Version)
+			if err != nil {
+				return nil, err
+			}
+
+			return &VersionRange{Kind: kind, Version: v}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown version range operator: %s", r.Op)
+}
+
+func newJSONModuleRenaming(r ModuleRenaming) *jsonModuleRenaming {
+	switch r.Kind {
+	case ModuleRenamingExplicit:
+		res := &jsonModuleRenaming{Kind: "explicit", Renames: make([]jsonModuleRename, len(r.Renames))}
+		for i, rename := range r.Renames {
+			res.Renames[i] = jsonModuleRename{From: rename.From, To: rename.To}
+		}
+
+		return res
+	case ModuleRenamingHiding:
+		return &jsonModuleRenaming{Kind: "hiding", Hiding: r.Hiding}
+	default:
+		return nil
+	}
+}
+
+func (r *jsonModuleRenaming) moduleRenaming() (ModuleRenaming, error) {
+	if r == nil {
+		return ModuleRenaming{}, nil
+	}
+
+	switch r.Kind {
+	case "explicit":
+		res := ModuleRenaming{Kind: ModuleRenamingExplicit, Renames: make([]ModuleRename, len(r.Renames))}
+		for i, rename := range r.Renames {
+			res.Renames[i] = ModuleRename{From: rename.From, To: rename.To}
+		}
+
+		return res, nil
+	case "hiding":
+		return ModuleRenaming{Kind: ModuleRenamingHiding, Hiding: r.Hiding}, nil
+	default:
+		return ModuleRenaming{}, fmt.Errorf("unknown module renaming kind: %s", r.Kind)
+	}
+}
+
+// marshalJSON encodes v leaving <, > and & unescaped, as they are common
+// in version ranges; the caller's encoder decides about escaping.
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
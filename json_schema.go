@@ -0,0 +1,103 @@
+package gocabalparser
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// JSONSchema returns a JSON Schema (draft 2020-12) document describing
+// the JSON representation of CabalPackage. It is generated from the
+// types used for encoding, so it always matches MarshalJSON.
+func JSONSchema() ([]byte, error) {
+	g := &jsonSchemaGenerator{defs: make(map[string]interface{})}
+
+	root := g.object(reflect.TypeOf(jsonPackage{}))
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["title"] = "CabalPackage"
+	root["description"] = "A Haskell package description parsed from a .cabal file."
+	root["$defs"] = g.defs
+
+	properties := root["properties"].(map[string]interface{})
+	properties["schemaVersion"].(map[string]interface{})["const"] = JSONSchemaVersion
+
+	return json.MarshalIndent(root, "", "  ")
+}
+
+type jsonSchemaGenerator struct {
+	defs map[string]interface{}
+}
+
+func (g *jsonSchemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schema(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Struct:
+		name := strings.TrimPrefix(t.Name(), "json")
+		if _, ok := g.defs[name]; !ok {
+			// reserve the name first, the type may refer to itself
+			g.defs[name] = nil
+			g.defs[name] = g.object(t)
+		}
+
+		return map[string]interface{}{"$ref": "#/$defs/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// object describes a struct from its json tags. Fields without omitempty
+// are required; doc and enum tags become description and enum keywords.
+func (g *jsonSchemaGenerator) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		// embedded structs are flattened by encoding/json
+		if f.Anonymous {
+			embedded := g.object(f.Type)
+
+			for name, s := range embedded["properties"].(map[string]interface{}) {
+				properties[name] = s
+			}
+
+			required = append(required, embedded["required"].([]string)...)
+
+			continue
+		}
+
+		tag := strings.Split(f.Tag.Get("json"), ",")
+
+		s := g.schema(f.Type)
+		if doc := f.Tag.Get("doc"); doc != "" {
+			s["description"] = doc
+		}
+
+		if enum := f.Tag.Get("enum"); enum != "" {
+			s["enum"] = strings.Split(enum, ",")
+		}
+
+		properties[tag[0]] = s
+
+		if len(tag) == 1 {
+			required = append(required, tag[0])
+		}
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
@@ -0,0 +1,309 @@
+package gocabalparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+var updateSchema = flag.Bool("update", false, "regenerate schema/cabal-package.schema.json")
+
+func TestCabalPackage_MarshalJSON(t *testing.T) {
+	p := &CabalPackage{
+		Name:    "example",
+		Version: "1.0",
+		Repositories: map[string]*SourceRepository{
+			"head": {Type: "git", Location: "https://example.org/example.git"},
+		},
+		Library: &Library{
+			BuildInfo: BuildInfo{
+				BuildDepends: []*Dependency{
+					{Name: "base", GreaterOrEqualThan: 4.14, LessThan: 5, Range: mustParseVersionRange(">= 4.14 && < 5")},
+					{Name: "text", Range: mustParseVersionRange("^>= 1.2.4 || ^>= 2.0")},
+				},
+				Mixins: []*Mixin{
+					{PackageName: "str-bytestring", Requires: ModuleRenaming{Kind: ModuleRenamingHiding, Hiding: []ModuleName{"Str"}}},
+				},
+			},
+			ExposedModules: []ModuleName{"Example"},
+		},
+		Executables: map[string]*Executable{
+			"example": {MainIs: "Main.hs"},
+		},
+	}
+
+	expected := `{
+  "schemaVersion": 1,
+  "name": "example",
+  "version": "1.0",
+  "sourceRepositories": [
+    {
+      "kind": "head",
+      "type": "git",
+      "location": "https://example.org/example.git"
+    }
+  ],
+  "components": [
+    {
+      "type": "library",
+      "exposedModules": [
+        "Example"
+      ],
+      "buildDepends": [
+        {
+          "package": "base",
+          "constraint": ">= 4.14 && < 5",
+          "range": {
+            "op": "intersection",
+            "operands": [
+              {
+                "op": "ge",
+                "version": "4.14"
+              },
+              {
+                "op": "lt",
+                "version": "5"
+              }
+            ]
+          }
+        },
+        {
+          "package": "text",
+          "constraint": "^>= 1.2.4 || ^>= 2.0",
+          "range": {
+            "op": "union",
+            "operands": [
+              {
+                "op": "majorBound",
+                "version": "1.2.4"
+              },
+              {
+                "op": "majorBound",
+                "version": "2.0"
+              }
+            ]
+          }
+        }
+      ],
+      "mixins": [
+        {
+          "package": "str-bytestring",
+          "requires": {
+            "kind": "hiding",
+            "hiding": [
+              "Str"
+            ]
+          }
+        }
+      ]
+    },
+    {
+      "type": "executable",
+      "name": "example",
+      "mainIs": "Main.hs"
+    }
+  ]
+}`
+
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(p); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := buf.String(); actual != expected+"\n" {
+		t.Fatalf("expected:\n%s\nactual:\n%s", expected, actual)
+	}
+}
+
+func TestCabalPackage_JSON_roundTrip(t *testing.T) {
+	for _, name := range []string{"1.cabal", "2.cabal", "3.cabal", "4.cabal", "5.cabal", "6.cabal", "7.cabal", "8.cabal", "9.cabal", "10.cabal", "11.cabal"} {
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(fmt.Sprintf("./testdata/%s", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer f.Close()
+
+			expected, err := NewParser().ParseReader(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := json.Marshal(expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual := &CabalPackage{}
+			if err := json.Unmarshal(data, actual); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(expected, actual) {
+				t.Fatalf("decoded package differs from the original:\n%s", data)
+			}
+		})
+	}
+}
+
+func TestCabalPackage_MarshalJSON_values(t *testing.T) {
+	p := CabalPackage{
+		Name:    "example",
+		Version: "1.0",
+		Library: &Library{
+			BuildInfo: BuildInfo{
+				BuildDepends: []*Dependency{mustParseDependency("base >= 4 && < 5")},
+			},
+		},
+	}
+
+	expected, err := json.Marshal(&p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := json.Marshal(map[string]CabalPackage{"example": p})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(actual) != `{"example":`+string(expected)+`}` {
+		t.Fatalf("expected package in map encoded as %s, got %s", expected, actual)
+	}
+
+	d := *p.Library.BuildDepends[0]
+
+	if expected, err = json.Marshal(&d); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual, err = json.Marshal([]Dependency{d}); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(actual) != "["+string(expected)+"]" {
+		t.Fatalf("expected dependency in slice encoded as %s, got %s", expected, actual)
+	}
+
+	if actual, err = json.Marshal(*d.Range); err != nil || !bytes.HasPrefix(actual, []byte(`{"op":"intersection"`)) {
+		t.Fatalf("unexpected version range encoding: %s, %v", actual, err)
+	}
+}
+
+func TestCabalPackage_UnmarshalJSON(t *testing.T) {
+	data := `{
+		"schemaVersion": 1,
+		"name": "example",
+		"version": "1.0",
+		"components": [
+			{"type": "library", "name": "internal", "buildDepends": [{"package": "base", "constraint": ">= 4 && < 5"}],
+			 "conditionals": [{"condition": "os(windows)", "then": {"exposedModules": ["Internal.Windows"]},
+			   "else": {"reexportedModules": [{"package": "unix", "module": "System.Posix", "as": "Internal.Posix"}]}}]}
+		]
+	}`
+
+	expected := &CabalPackage{
+		Name:    "example",
+		Version: "1.0",
+		SubLibraries: map[string]*Library{
+			"internal": {
+				BuildInfo: BuildInfo{
+					BuildDepends: []*Dependency{
+						{Name: "base", GreaterOrEqualThan: 4, LessThan: 5, Range: mustParseVersionRange(">= 4 && < 5")},
+					},
+					Conditionals: []*Conditional{
+						{
+							Condition: "os(windows)",
+							Then:      ConditionalBranch{ExposedModules: []ModuleName{"Internal.Windows"}},
+							Else: &ConditionalBranch{ReexportedModules: []*ModuleReexport{
+								{OriginalPackage: "unix", OriginalName: "System.Posix", NewName: "Internal.Posix"},
+							}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	actual := &CabalPackage{}
+	if err := json.Unmarshal([]byte(data), actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestCabalPackage_UnmarshalJSON_errors(t *testing.T) {
+	tcs := map[string]string{
+		"schema version":  `{"schemaVersion": 2, "name": "a", "version": "1"}`,
+		"component type":  `{"schemaVersion": 1, "components": [{"type": "benchmark", "name": "a"}]}`,
+		"two libraries":   `{"schemaVersion": 1, "components": [{"type": "library"}, {"type": "library"}]}`,
+		"unnamed exe":     `{"schemaVersion": 1, "components": [{"type": "executable"}]}`,
+		"range operator":  `{"schemaVersion": 1, "components": [{"type": "library", "buildDepends": [{"package": "a", "range": {"op": "approx"}}]}]}`,
+		"range operands":  `{"schemaVersion": 1, "components": [{"type": "library", "buildDepends": [{"package": "a", "range": {"op": "union", "operands": [{"op": "any"}]}}]}]}`,
+		"constraint":      `{"schemaVersion": 1, "components": [{"type": "library", "buildDepends": [{"package": "a", "constraint": ">="}]}]}`,
+		"renaming kind":   `{"schemaVersion": 1, "components": [{"type": "library", "mixins": [{"package": "a", "includes": {"kind": "all"}}]}]}`,
+		"missing package": `{"schemaVersion": 1, "components": [{"type": "library", "buildDepends": [{"constraint": ">= 1"}]}]}`,
+		"condition":       `{"schemaVersion": 1, "components": [{"type": "library", "conditionals": [{"condition": "os(", "then": {}}]}]}`,
+		"missing then":    `{"schemaVersion": 1, "components": [{"type": "library", "conditionals": [{"condition": "os(linux)"}]}]}`,
+		"unnamed flag":    `{"schemaVersion": 1, "flags": [{"default": true, "manual": false}]}`,
+	}
+
+	for name, data := range tcs {
+		t.Run(name, func(t *testing.T) {
+			if err := json.Unmarshal([]byte(data), &CabalPackage{}); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	const path = "./schema/cabal-package.schema.json"
+
+	actual, err := JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual = append(actual, '\n')
+
+	if *updateSchema {
+		if err := os.WriteFile(path, actual, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(expected, actual) {
+		t.Fatalf("%s is out of date, run go test -run TestJSONSchema -update", path)
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(actual, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	defs := schema["$defs"].(map[string]interface{})
+	for _, name := range []string{"SourceRepository", "Component", "Dependency", "VersionRange", "Mixin", "ModuleRenaming", "Reexport"} {
+		if _, ok := defs[name]; !ok {
+			t.Fatalf("schema has no definition of %s", name)
+		}
+	}
+}
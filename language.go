@@ -0,0 +1,247 @@
+package gocabalparser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Language is a Haskell language edition set by default-language.
+type Language string
+
+const (
+	Haskell98   Language = "Haskell98"
+	Haskell2010 Language = "Haskell2010"
+	GHC2021     Language = "GHC2021"
+	GHC2024     Language = "GHC2024"
+
+	// DefaultLanguage is used by components without default-language,
+	// matching the default of current GHC releases.
+	DefaultLanguage = GHC2021
+)
+
+var (
+	haskell98Extensions = []string{
+		"CUSKs",
+		"DatatypeContexts",
+		"DeepSubsumption",
+		"FieldSelectors",
+		"ImplicitPrelude",
+		"ListTuplePuns",
+		"MonomorphismRestriction",
+		"NPlusKPatterns",
+		"NondecreasingIndentation",
+		"StarIsType",
+		"TraditionalRecordSyntax",
+	}
+
+	haskell2010Extensions = []string{
+		"CUSKs",
+		"DatatypeContexts",
+		"DeepSubsumption",
+		"DoAndIfThenElse",
+		"EmptyDataDecls",
+		"FieldSelectors",
+		"ForeignFunctionInterface",
+		"ImplicitPrelude",
+		"ListTuplePuns",
+		"MonomorphismRestriction",
+		"PatternGuards",
+		"RelaxedPolyRec",
+		"StarIsType",
+		"TraditionalRecordSyntax",
+	}
+
+	ghc2021Extensions = []string{
+		"BangPatterns",
+		"BinaryLiterals",
+		"ConstrainedClassMethods",
+		"ConstraintKinds",
+		"DeriveDataTypeable",
+		"DeriveFoldable",
+		"DeriveFunctor",
+		"DeriveGeneric",
+		"DeriveLift",
+		"DeriveTraversable",
+		"DoAndIfThenElse",
+		"EmptyCase",
+		"EmptyDataDecls",
+		"EmptyDataDeriving",
+		"ExistentialQuantification",
+		"ExplicitForAll",
+		"FieldSelectors",
+		"FlexibleContexts",
+		"FlexibleInstances",
+		"ForeignFunctionInterface",
+		"GADTSyntax",
+		"GeneralizedNewtypeDeriving",
+		"HexFloatLiterals",
+		"ImplicitPrelude",
+		"ImportQualifiedPost",
+		"InstanceSigs",
+		"KindSignatures",
+		"ListTuplePuns",
+		"MonomorphismRestriction",
+		"MultiParamTypeClasses",
+		"NamedFieldPuns",
+		"NamedWildCards",
+		"NumericUnderscores",
+		"PatternGuards",
+		"PolyKinds",
+		"PostfixOperators",
+		"RankNTypes",
+		"RelaxedPolyRec",
+		"ScopedTypeVariables",
+		"StandaloneDeriving",
+		"StandaloneKindSignatures",
+		"StarIsType",
+		"TraditionalRecordSyntax",
+		"TupleSections",
+		"TypeApplications",
+		"TypeOperators",
+		"TypeSynonymInstances",
+	}
+
+	ghc2024Extensions = append([]string{
+		"DataKinds",
+		"DerivingStrategies",
+		"DisambiguateRecordFields",
+		"ExplicitNamespaces",
+		"GADTs",
+		"LambdaCase",
+		"MonoLocalBinds",
+		"RoleAnnotations",
+	}, ghc2021Extensions...)
+
+	// extensionSynonyms maps alternative spellings to canonical names.
+	extensionSynonyms = map[string]string{
+		"DoRec":                      "RecursiveDo",
+		"GeneralisedNewtypeDeriving": "GeneralizedNewtypeDeriving",
+		"NullaryTypeClasses":         "MultiParamTypeClasses",
+		"PatternSignatures":          "ScopedTypeVariables",
+		"PolymorphicComponents":      "RankNTypes",
+		"Rank2Types":                 "RankNTypes",
+		"RecordPuns":                 "NamedFieldPuns",
+	}
+
+	// extensionImplications lists extensions switched on, or off when
+	// No-prefixed, by enabling an extension.
+	extensionImplications = map[string][]string{
+		"DeriveTraversable":         {"DeriveFunctor", "DeriveFoldable"},
+		"DerivingVia":               {"DerivingStrategies"},
+		"ExistentialQuantification": {"ExplicitForAll"},
+		"FlexibleInstances":         {"TypeSynonymInstances"},
+		"FunctionalDependencies":    {"MultiParamTypeClasses"},
+		"GADTs":                     {"GADTSyntax", "MonoLocalBinds"},
+		"ImpredicativeTypes":        {"RankNTypes"},
+		"JavaScriptFFI":             {"InterruptibleFFI"},
+		"LiberalTypeSynonyms":       {"ExplicitForAll"},
+		"MultiParamTypeClasses":     {"ConstrainedClassMethods"},
+		"ParallelArrays":            {"ParallelListComp"},
+		"PolyKinds":                 {"KindSignatures"},
+		"QuantifiedConstraints":     {"ExplicitForAll"},
+		"RankNTypes":                {"ExplicitForAll"},
+		"RebindableSyntax":          {"NoImplicitPrelude"},
+		"RecordWildCards":           {"DisambiguateRecordFields"},
+		"ScopedTypeVariables":       {"ExplicitForAll"},
+		"StandaloneKindSignatures":  {"NoCUSKs"},
+		"Strict":                    {"StrictData"},
+		"TemplateHaskell":           {"TemplateHaskellQuotes"},
+		"TypeFamilies":              {"ExplicitNamespaces", "KindSignatures", "MonoLocalBinds"},
+		"TypeFamilyDependencies":    {"TypeFamilies"},
+		"TypeInType":                {"DataKinds", "PolyKinds", "KindSignatures"},
+		"TypeOperators":             {"ExplicitNamespaces"},
+		"UnliftedDatatypes":         {"DataKinds", "StandaloneKindSignatures"},
+	}
+)
+
+// ParseLanguage validates s as a known language edition.
+func ParseLanguage(s string) (Language, error) {
+	switch l := Language(s); l {
+	case Haskell98, Haskell2010, GHC2021, GHC2024:
+		return l, nil
+	default:
+		return "", fmt.Errorf("unknown language: %s", s)
+	}
+}
+
+// Extensions returns the extensions enabled by the language edition.
+func (l Language) Extensions() []string {
+	var exts []string
+
+	switch l {
+	case Haskell98:
+		exts = haskell98Extensions
+	case Haskell2010:
+		exts = haskell2010Extensions
+	case GHC2021:
+		exts = ghc2021Extensions
+	case GHC2024:
+		exts = ghc2024Extensions
+	}
+
+	res := append([]string(nil), exts...)
+	sort.Strings(res)
+
+	return res
+}
+
+// EffectiveExtensions returns the sorted set of extensions enabled in the
+// component: those of its language, then extensions and default-extensions
+// applied in order, each switching on the extensions it implies.
+// other-extensions are only declarations and are not included.
+func (bi *BuildInfo) EffectiveExtensions() []string {
+	language := bi.DefaultLanguage
+	if language == "" {
+		language = DefaultLanguage
+	}
+
+	enabled := make(map[string]struct{})
+
+	for _, e := range language.Extensions() {
+		enabled[e] = struct{}{}
+	}
+
+	for _, e := range bi.Extensions {
+		setExtension(enabled, e)
+	}
+
+	for _, e := range bi.DefaultExtensions {
+		setExtension(enabled, e)
+	}
+
+	return sortedKeys(enabled)
+}
+
+// setExtension enables or, for No-prefixed names, disables an extension.
+// As in GHC, disabling an extension leaves the ones it implies untouched.
+func setExtension(enabled map[string]struct{}, name string) {
+	if positive, ok := negatedExtension(name); ok {
+		delete(enabled, canonicalExtension(positive))
+
+		return
+	}
+
+	name = canonicalExtension(name)
+	enabled[name] = struct{}{}
+
+	for _, implied := range extensionImplications[name] {
+		setExtension(enabled, implied)
+	}
+}
+
+func canonicalExtension(name string) string {
+	if canonical, ok := extensionSynonyms[name]; ok {
+		return canonical
+	}
+
+	return name
+}
+
+func negatedExtension(name string) (string, bool) {
+	if _, ok := knownExtensions[name]; ok || !strings.HasPrefix(name, "No") {
+		return "", false
+	}
+
+	return name[2:], true
+}
@@ -0,0 +1,124 @@
+package gocabalparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLanguage_Extensions(t *testing.T) {
+	ghc2021 := GHC2021.Extensions()
+	ghc2024 := GHC2024.Extensions()
+
+	if len(ghc2024) != len(ghc2021)+8 {
+		t.Fatalf("expected GHC2024 to extend GHC2021 with 8 extensions, got %d and %d", len(ghc2024), len(ghc2021))
+	}
+
+	for _, l := range []Language{Haskell98, Haskell2010, GHC2021, GHC2024} {
+		for _, e := range l.Extensions() {
+			if !IsKnownExtension(e) {
+				t.Fatalf("%s implies unknown extension %s", l, e)
+			}
+		}
+	}
+
+	if _, err := ParseLanguage("Haskell2020"); err == nil {
+		t.Fatal("expected error for unknown language")
+	}
+}
+
+func TestBuildInfo_EffectiveExtensions(t *testing.T) {
+	cases := []struct {
+		name      string
+		buildInfo BuildInfo
+		contains  []string
+		excludes  []string
+	}{
+		{
+			name:      "language only",
+			buildInfo: BuildInfo{DefaultLanguage: Haskell98},
+			contains:  []string{"NPlusKPatterns", "ImplicitPrelude"},
+			excludes:  []string{"PatternGuards", "ExplicitForAll"},
+		},
+		{
+			name:      "default language",
+			buildInfo: BuildInfo{},
+			contains:  []string{"ImportQualifiedPost", "TypeApplications"},
+			excludes:  []string{"NPlusKPatterns", "GADTs"},
+		},
+		{
+			name: "implications",
+			buildInfo: BuildInfo{
+				DefaultLanguage:   Haskell2010,
+				DefaultExtensions: []string{"TypeFamilies", "Rank2Types", "DeriveTraversable"},
+			},
+			contains: []string{"TypeFamilies", "MonoLocalBinds", "KindSignatures", "RankNTypes", "ExplicitForAll", "DeriveFunctor", "DeriveFoldable"},
+			excludes: []string{"Rank2Types"},
+		},
+		{
+			name: "negations",
+			buildInfo: BuildInfo{
+				DefaultLanguage:   Haskell2010,
+				Extensions:        []string{"GADTs"},
+				DefaultExtensions: []string{"NoImplicitPrelude", "NoMonoLocalBinds", "StandaloneKindSignatures"},
+			},
+			contains: []string{"GADTs", "GADTSyntax", "StandaloneKindSignatures"},
+			excludes: []string{"ImplicitPrelude", "MonoLocalBinds", "CUSKs"},
+		},
+		{
+			name: "other extensions are not enabled",
+			buildInfo: BuildInfo{
+				DefaultLanguage: Haskell2010,
+				OtherExtensions: []string{"TemplateHaskell"},
+			},
+			excludes: []string{"TemplateHaskell"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			enabled := make(map[string]struct{})
+
+			for _, e := range tc.buildInfo.EffectiveExtensions() {
+				enabled[e] = struct{}{}
+			}
+
+			for _, e := range tc.contains {
+				if _, ok := enabled[e]; !ok {
+					t.Errorf("expected %s to be enabled", e)
+				}
+			}
+
+			for _, e := range tc.excludes {
+				if _, ok := enabled[e]; ok {
+					t.Errorf("expected %s to be disabled", e)
+				}
+			}
+		})
+	}
+}
+
+func TestExecutable_EffectiveExtensions(t *testing.T) {
+	ex := &Executable{
+		BuildInfo: BuildInfo{
+			DefaultLanguage:   Haskell98,
+			DefaultExtensions: []string{"NoImplicitPrelude"},
+		},
+	}
+
+	expected := []string{
+		"CUSKs",
+		"DatatypeContexts",
+		"DeepSubsumption",
+		"FieldSelectors",
+		"ListTuplePuns",
+		"MonomorphismRestriction",
+		"NPlusKPatterns",
+		"NondecreasingIndentation",
+		"StarIsType",
+		"TraditionalRecordSyntax",
+	}
+
+	if actual := ex.EffectiveExtensions(); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+}
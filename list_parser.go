@@ -0,0 +1,182 @@
+package gocabalparser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// listSplitter splits raw field contents into list elements.
+type listSplitter func(s string) ([]string, error)
+
+// splitCommaList splits comma separated lists such as build-depends.
+// Elements may span several lines, and a single leading or trailing
+// comma is allowed as in cabal 2.2 and later. Commas inside parentheses,
+// as in mixins renamings, or braces, as in version sets and sub-library
+// sets, do not separate elements.
+func splitCommaList(s string) ([]string, error) {
+	chunks, err := splitOutsideQuotes(s, func(c byte) bool { return c == ',' }, true)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(chunks))
+
+	for i, c := range chunks {
+		c = strings.TrimSpace(c)
+
+		if c == "" {
+			if i == 0 || i == len(chunks)-1 {
+				continue
+			}
+
+			return nil, errors.New("empty list element")
+		}
+
+		res = append(res, strings.Join(strings.Fields(c), " "))
+	}
+
+	return res, nil
+}
+
+// splitOptCommaList splits lists whose elements are separated by commas,
+// whitespace or both, such as other-modules or hs-source-dirs. Quoted
+// elements may contain spaces.
+func splitOptCommaList(s string) ([]string, error) {
+	chunks, err := splitOutsideQuotes(s, func(c byte) bool { return c == ',' || isSpace(c) }, false)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(chunks))
+
+	for _, c := range chunks {
+		if c == "" {
+			continue
+		}
+
+		v, err := unquote(c)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, v)
+	}
+
+	return res, nil
+}
+
+// splitHaskellTokens splits whitespace separated tokens such as
+// ghc-options, where "quoted strings" form a single token.
+func splitHaskellTokens(s string) ([]string, error) {
+	chunks, err := splitOutsideQuotes(s, isSpace, false)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(chunks))
+
+	for _, c := range chunks {
+		if c == "" {
+			continue
+		}
+
+		v, err := unquote(c)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, v)
+	}
+
+	return res, nil
+}
+
+// splitOutsideQuotes splits s at separators outside quoted strings and,
+// when nested is set, outside parentheses and braces.
+func splitOutsideQuotes(s string, isSep func(c byte) bool, nested bool) ([]string, error) {
+	var (
+		res     []string
+		start   int
+		closing []byte
+		inQuote bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case inQuote && c == '\\':
+			i++
+		case c == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case nested && c == '(':
+			closing = append(closing, ')')
+		case nested && c == '{':
+			closing = append(closing, '}')
+		case nested && (c == ')' || c == '}'):
+			if len(closing) == 0 || closing[len(closing)-1] != c {
+				return nil, fmt.Errorf("unbalanced brackets: %s", s)
+			}
+
+			closing = closing[:len(closing)-1]
+		case len(closing) == 0 && isSep(c):
+			res = append(res, s[start:i])
+			start = i + 1
+		}
+	}
+
+	if inQuote {
+		return nil, fmt.Errorf("unterminated string: %s", s[start:])
+	}
+
+	if len(closing) != 0 {
+		return nil, fmt.Errorf("unbalanced brackets: %s", s)
+	}
+
+	return append(res, s[start:]), nil
+}
+
+func unquote(s string) (string, error) {
+	if !strings.ContainsRune(s, '"') {
+		return s, nil
+	}
+
+	var (
+		b       strings.Builder
+		inQuote bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case inQuote && c == '\\':
+			if i+1 >= len(s) {
+				return "", fmt.Errorf("unterminated escape: %s", s)
+			}
+
+			i++
+
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(s[i])
+			}
+		case c == '"':
+			inQuote = !inQuote
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String(), nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,133 @@
+package gocabalparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestListSplitters(t *testing.T) {
+	cases := []struct {
+		name     string
+		split    listSplitter
+		input    string
+		expected []string
+	}{
+		{
+			name:     "comma list",
+			split:    splitCommaList,
+			input:    "base >= 4 && < 5, containers",
+			expected: []string{"base >= 4 && < 5", "containers"},
+		},
+		{
+			name:     "comma list over lines",
+			split:    splitCommaList,
+			input:    "base   >= 3.0 && < 5,\nGLUT   >= 2.4 && < 2.8",
+			expected: []string{"base >= 3.0 && < 5", "GLUT >= 2.4 && < 2.8"},
+		},
+		{
+			name:     "comma list with leading comma",
+			split:    splitCommaList,
+			input:    ", base\n, containers",
+			expected: []string{"base", "containers"},
+		},
+		{
+			name:     "comma list with trailing comma",
+			split:    splitCommaList,
+			input:    "base,\ncontainers,",
+			expected: []string{"base", "containers"},
+		},
+		{
+			name:     "comma list with version set",
+			split:    splitCommaList,
+			input:    "base ^>= {4.14, 4.15},\ntext == { 1.2.5.0 , 2.0 }",
+			expected: []string{"base ^>= {4.14, 4.15}", "text == { 1.2.5.0 , 2.0 }"},
+		},
+		{
+			name:     "comma list with sub-library set",
+			split:    splitCommaList,
+			input:    "foo:{a, b} >= 1, bar",
+			expected: []string{"foo:{a, b} >= 1", "bar"},
+		},
+		{
+			name:     "optional comma list with spaces",
+			split:    splitOptCommaList,
+			input:    "src src/mountains",
+			expected: []string{"src", "src/mountains"},
+		},
+		{
+			name:     "optional comma list with commas",
+			split:    splitOptCommaList,
+			input:    "Data.Map,Data.Set,\nData.Sequence",
+			expected: []string{"Data.Map", "Data.Set", "Data.Sequence"},
+		},
+		{
+			name:     "optional comma list with quoted element",
+			split:    splitOptCommaList,
+			input:    `src "my sources"`,
+			expected: []string{"src", "my sources"},
+		},
+		{
+			name:     "haskell tokens",
+			split:    splitHaskellTokens,
+			input:    "-Wall -threaded\n-rtsopts",
+			expected: []string{"-Wall", "-threaded", "-rtsopts"},
+		},
+		{
+			name:     "haskell tokens with quoted strings",
+			split:    splitHaskellTokens,
+			input:    `-optP-include -optP"dist/build/cabal macros.h" "-with-rtsopts=-N -A64m"`,
+			expected: []string{"-optP-include", "-optPdist/build/cabal macros.h", "-with-rtsopts=-N -A64m"},
+		},
+		{
+			name:     "haskell tokens keep commas",
+			split:    splitHaskellTokens,
+			input:    "-fplugin-opt=Plugin:a,b",
+			expected: []string{"-fplugin-opt=Plugin:a,b"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := tc.split(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Fatalf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestListSplitters_errors(t *testing.T) {
+	cases := []struct {
+		name  string
+		split listSplitter
+		input string
+	}{
+		{
+			name:  "empty comma list element",
+			split: splitCommaList,
+			input: "base,,containers",
+		},
+		{
+			name:  "mismatched brackets",
+			split: splitCommaList,
+			input: "base ^>= {4.14, (4.15}), text",
+		},
+		{
+			name:  "unterminated string",
+			split: splitHaskellTokens,
+			input: `-optP"foo`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.split(tc.input); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package gocabalparser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type mixinsParser struct {
+	words []string
+	index int
+}
+
+func newMixinsParser() *mixinsParser {
+	return &mixinsParser{}
+}
+
+// ParseString parses a single mixins entry, e.g.
+// str-bytestring (Str as Str.ByteString) requires (Str as Str.Sig).
+func (p *mixinsParser) ParseString(s string) (*Mixin, error) {
+	p.words = splitMixinWords(s)
+	p.index = 0
+
+	name, ok := p.next()
+	if !ok {
+		return nil, errors.New("empty mixin")
+	}
+
+	if !isPackageNameWord(name) {
+		return nil, fmt.Errorf("invalid mixin package name: %s", name)
+	}
+
+	mixin := &Mixin{PackageName: name}
+
+	if w, ok := p.peek(); ok && w == ":" {
+		p.next()
+
+		lib, ok := p.next()
+		if !ok || !isPackageNameWord(lib) {
+			return nil, fmt.Errorf("library name expected after '%s:'", name)
+		}
+
+		mixin.LibraryName = lib
+	}
+
+	if w, ok := p.peek(); ok && w != "requires" {
+		renaming, err := p.parseRenaming()
+		if err != nil {
+			return nil, err
+		}
+
+		mixin.Includes = renaming
+	}
+
+	if w, ok := p.peek(); ok && w == "requires" {
+		p.next()
+
+		renaming, err := p.parseRenaming()
+		if err != nil {
+			return nil, err
+		}
+
+		mixin.Requires = renaming
+	}
+
+	if w, ok := p.peek(); ok {
+		return nil, fmt.Errorf("unexpected token in mixin: %s", w)
+	}
+
+	return mixin, nil
+}
+
+func (p *mixinsParser) parseRenaming() (ModuleRenaming, error) {
+	w, ok := p.next()
+	if !ok {
+		return ModuleRenaming{}, errors.New("module renaming expected")
+	}
+
+	if w == "hiding" {
+		modules := make([]ModuleName, 0)
+
+		err := p.parseParens(func() error {
+			m, err := p.parseModuleName()
+			if err != nil {
+				return err
+			}
+
+			modules = append(modules, m)
+
+			return nil
+		})
+		if err != nil {
+			return ModuleRenaming{}, err
+		}
+
+		return ModuleRenaming{Kind: ModuleRenamingHiding, Hiding: modules}, nil
+	}
+
+	p.index--
+
+	renames := make([]ModuleRename, 0)
+
+	err := p.parseParens(func() error {
+		from, err := p.parseModuleName()
+		if err != nil {
+			return err
+		}
+
+		to := from
+
+		if w, ok := p.peek(); ok && w == "as" {
+			p.next()
+
+			if to, err = p.parseModuleName(); err != nil {
+				return err
+			}
+		}
+
+		renames = append(renames, ModuleRename{From: from, To: to})
+
+		return nil
+	})
+	if err != nil {
+		return ModuleRenaming{}, err
+	}
+
+	return ModuleRenaming{Kind: ModuleRenamingExplicit, Renames: renames}, nil
+}
+
+func (p *mixinsParser) parseParens(item func() error) error {
+	if w, ok := p.next(); !ok || w != "(" {
+		return errors.New("'(' expected in module renaming")
+	}
+
+	if w, ok := p.peek(); ok && w == ")" {
+		p.next()
+
+		return nil
+	}
+
+	for {
+		if err := item(); err != nil {
+			return err
+		}
+
+		w, ok := p.next()
+		if !ok {
+			return errors.New("')' expected in module renaming")
+		}
+
+		switch w {
+		case ",":
+			continue
+		case ")":
+			return nil
+		default:
+			return fmt.Errorf("unexpected token in module renaming: %s", w)
+		}
+	}
+}
+
+func (p *mixinsParser) parseModuleName() (ModuleName, error) {
+	w, ok := p.next()
+	if !ok {
+		return "", errors.New("module name expected")
+	}
+
+	return ParseModuleName(w)
+}
+
+func (p *mixinsParser) next() (string, bool) {
+	if p.index >= len(p.words) {
+		return "", false
+	}
+
+	p.index++
+
+	return p.words[p.index-1], true
+}
+
+func (p *mixinsParser) peek() (string, bool) {
+	if p.index >= len(p.words) {
+		return "", false
+	}
+
+	return p.words[p.index], true
+}
+
+func splitMixinWords(s string) []string {
+	words := make([]string, 0)
+	start := -1
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if !isSpace(c) && !strings.ContainsRune("(),:", rune(c)) {
+			if start < 0 {
+				start = i
+			}
+
+			continue
+		}
+
+		if start >= 0 {
+			words = append(words, s[start:i])
+			start = -1
+		}
+
+		if !isSpace(c) {
+			words = append(words, string(c))
+		}
+	}
+
+	if start >= 0 {
+		words = append(words, s[start:])
+	}
+
+	return words
+}
+
+func isPackageNameWord(w string) bool {
+	if w == "" || w == "requires" || w == "hiding" {
+		return false
+	}
+
+	for _, r := range w {
+		if r != '-' && !('a' <= r && r <= 'z') && !('A' <= r && r <= 'Z') && !('0' <= r && r <= '9') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,131 @@
+package gocabalparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMixinsParser_ParseString(t *testing.T) {
+	cases := []struct {
+		name        string
+		mixinString string
+		expected    *Mixin
+	}{
+		{
+			name:        "package only",
+			mixinString: "str-bytestring",
+			expected: &Mixin{
+				PackageName: "str-bytestring",
+			},
+		},
+		{
+			name:        "sub-library",
+			mixinString: "str-impls:bytestring",
+			expected: &Mixin{
+				PackageName: "str-impls",
+				LibraryName: "bytestring",
+			},
+		},
+		{
+			name:        "renaming with requires",
+			mixinString: "str-bytestring (Str as Str.ByteString) requires (Str as Str.Sig)",
+			expected: &Mixin{
+				PackageName: "str-bytestring",
+				Includes: ModuleRenaming{
+					Kind: ModuleRenamingExplicit,
+					Renames: []ModuleRename{
+						{From: "Str", To: "Str.ByteString"},
+					},
+				},
+				Requires: ModuleRenaming{
+					Kind: ModuleRenamingExplicit,
+					Renames: []ModuleRename{
+						{From: "Str", To: "Str.Sig"},
+					},
+				},
+			},
+		},
+		{
+			name:        "several renamings",
+			mixinString: "containers (Data.Map, Data.Set as Set)",
+			expected: &Mixin{
+				PackageName: "containers",
+				Includes: ModuleRenaming{
+					Kind: ModuleRenamingExplicit,
+					Renames: []ModuleRename{
+						{From: "Data.Map", To: "Data.Map"},
+						{From: "Data.Set", To: "Set"},
+					},
+				},
+			},
+		},
+		{
+			name:        "hiding",
+			mixinString: "base hiding (Prelude)",
+			expected: &Mixin{
+				PackageName: "base",
+				Includes: ModuleRenaming{
+					Kind:   ModuleRenamingHiding,
+					Hiding: []ModuleName{"Prelude"},
+				},
+			},
+		},
+		{
+			name:        "requires hiding only",
+			mixinString: "regex-indef requires hiding (Str)",
+			expected: &Mixin{
+				PackageName: "regex-indef",
+				Requires: ModuleRenaming{
+					Kind:   ModuleRenamingHiding,
+					Hiding: []ModuleName{"Str"},
+				},
+			},
+		},
+		{
+			name:        "empty renaming",
+			mixinString: "str-sig ()",
+			expected: &Mixin{
+				PackageName: "str-sig",
+				Includes: ModuleRenaming{
+					Kind:    ModuleRenamingExplicit,
+					Renames: []ModuleRename{},
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := newMixinsParser().ParseString(tc.mixinString)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Logf("expected: %+v", tc.expected)
+				t.Logf("actual: %+v", actual)
+
+				t.FailNow()
+			}
+		})
+	}
+}
+
+func TestMixinsParser_ParseString_errors(t *testing.T) {
+	cases := []string{
+		"",
+		"str (Str as)",
+		"str (Str as Foo",
+		"str (str)",
+		"str requires",
+		"str (Str) extra",
+	}
+
+	for _, tc := range cases {
+		t.Run(tc, func(t *testing.T) {
+			if _, err := newMixinsParser().ParseString(tc); err == nil {
+				t.Fatalf("expected error for '%s'", tc)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package gocabalparser
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ModuleName is a hierarchical Haskell module name such as Data.Map.Strict.
+type ModuleName string
+
+// ParseModuleName validates s as a Haskell module name.
+func ParseModuleName(s string) (ModuleName, error) {
+	if s == "" {
+		return "", fmt.Errorf("empty module name")
+	}
+
+	for _, c := range strings.Split(s, ".") {
+		if err := validateModuleComponent(c); err != nil {
+			return "", fmt.Errorf("invalid module name '%s': %v", s, err)
+		}
+	}
+
+	return ModuleName(s), nil
+}
+
+// Components returns the dot separated parts of the module name.
+func (m ModuleName) Components() []string {
+	return strings.Split(string(m), ".")
+}
+
+// FilePath returns the path of the module source relative to a source
+// directory, e.g. Data/Map/Strict.hs for ext ".hs".
+func (m ModuleName) FilePath(ext string) string {
+	return path.Join(m.Components()...) + ext
+}
+
+func (m ModuleName) String() string {
+	return string(m)
+}
+
+func validateModuleComponent(c string) error {
+	if c == "" {
+		return fmt.Errorf("empty component")
+	}
+
+	first, _ := utf8.DecodeRuneInString(c)
+	if !unicode.IsUpper(first) {
+		return fmt.Errorf("component '%s' must start with a capital letter", c)
+	}
+
+	for _, r := range c {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '\'' {
+			return fmt.Errorf("unexpected character '%c' in component '%s'", r, c)
+		}
+	}
+
+	return nil
+}
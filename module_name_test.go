@@ -0,0 +1,70 @@
+package gocabalparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseModuleName(t *testing.T) {
+	cases := []struct {
+		name       string
+		input      string
+		components []string
+		filePath   string
+	}{
+		{
+			name:       "single component",
+			input:      "Utilities",
+			components: []string{"Utilities"},
+			filePath:   "Utilities.hs",
+		},
+		{
+			name:       "hierarchical",
+			input:      "Data.Map.Strict",
+			components: []string{"Data", "Map", "Strict"},
+			filePath:   "Data/Map/Strict.hs",
+		},
+		{
+			name:       "digits, primes and underscores",
+			input:      "Paths_foo.V2'",
+			components: []string{"Paths_foo", "V2'"},
+			filePath:   "Paths_foo/V2'.hs",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := ParseModuleName(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(m.Components(), tc.components) {
+				t.Fatalf("expected components %q, got %q", tc.components, m.Components())
+			}
+
+			if m.FilePath(".hs") != tc.filePath {
+				t.Fatalf("expected file path %s, got %s", tc.filePath, m.FilePath(".hs"))
+			}
+		})
+	}
+}
+
+func TestParseModuleName_errors(t *testing.T) {
+	cases := []string{
+		"",
+		"data.Map",
+		"Data..Map",
+		"Data.Map.",
+		"Data-Map",
+		"Data/Map",
+	}
+
+	for _, tc := range cases {
+		t.Run(tc, func(t *testing.T) {
+			if _, err := ParseModuleName(tc); err == nil {
+				t.Fatalf("expected error for '%s'", tc)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package gocabalparser
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -13,12 +14,97 @@ var (
 		"tag":      {},
 	}
 
+	flagProperties = map[string]struct{}{
+		"description": {},
+		"default":     {},
+		"manual":      {},
+	}
+
+	buildInfoProperties = map[string]struct{}{
+		"build-depends":      {},
+		"mixins":             {},
+		"default-language":   {},
+		"extensions":         {},
+		"default-extensions": {},
+		"other-extensions":   {},
+		"other-modules":      {},
+		"autogen-modules":    {},
+		"hs-source-dirs":     {},
+		"ghc-options":        {},
+		"c-sources":          {},
+		"include-dirs":       {},
+		"includes":           {},
+		"install-includes":   {},
+		"if":                 {},
+	}
+
+	libraryProperties = map[string]struct{}{
+		"exposed-modules":    {},
+		"reexported-modules": {},
+		"signatures":         {},
+	}
+
 	executableProperties = map[string]struct{}{
-		"build-depends":  {},
-		"extensions":     {},
-		"main-is":        {},
-		"other-modules":  {},
-		"hs-source-dirs": {},
+		"main-is": {},
+	}
+
+	// unmodeledSections and unmodeledFields are valid in cabal files but
+	// have no place in CabalPackage, so they are skipped before parsing,
+	// along with x- custom fields other than x-revision. Common stanzas
+	// are skipped once inlined where they are imported. The fields are
+	// package description fields, build info fields and component fields,
+	// in that order.
+	unmodeledSections = map[string]struct{}{
+		"test-suite":      {},
+		"benchmark":       {},
+		"foreign-library": {},
+		"custom-setup":    {},
+		"common":          {},
+	}
+
+	unmodeledFields = map[string]struct{}{
+		"license-files":   {},
+		"bug-reports":     {},
+		"extra-tmp-files": {},
+
+		"buildable":                      {},
+		"build-tool-depends":             {},
+		"build-tools":                    {},
+		"other-languages":                {},
+		"hs-source-dir":                  {},
+		"virtual-modules":                {},
+		"autogen-includes":               {},
+		"cpp-options":                    {},
+		"asm-options":                    {},
+		"cmm-options":                    {},
+		"cc-options":                     {},
+		"cxx-options":                    {},
+		"ld-options":                     {},
+		"hsc2hs-options":                 {},
+		"ghc-prof-options":               {},
+		"ghc-shared-options":             {},
+		"ghcjs-options":                  {},
+		"ghcjs-prof-options":             {},
+		"ghcjs-shared-options":           {},
+		"asm-sources":                    {},
+		"cmm-sources":                    {},
+		"cxx-sources":                    {},
+		"js-sources":                     {},
+		"pkgconfig-depends":              {},
+		"frameworks":                     {},
+		"extra-framework-dirs":           {},
+		"extra-libraries":                {},
+		"extra-libraries-static":         {},
+		"extra-bundled-libraries":        {},
+		"extra-ghci-libraries":           {},
+		"extra-lib-dirs":                 {},
+		"extra-lib-dirs-static":          {},
+		"extra-library-flavours":         {},
+		"extra-dynamic-library-flavours": {},
+
+		"visibility": {},
+		"exposed":    {},
+		"scope":      {},
 	}
 )
 
@@ -46,6 +132,8 @@ func (p *tokensParser) Parse(tokens []*token) (*CabalPackage, error) {
 			err = parseString(&res.Name, iterator)
 		case "version":
 			err = parseString(&res.Version, iterator)
+		case "x-revision":
+			err = parseInt(&res.Revision, iterator)
 		case "cabal-version":
 			err = parseString(&res.CabalVersion, iterator)
 		case "build-type":
@@ -68,6 +156,14 @@ func (p *tokensParser) Parse(tokens []*token) (*CabalPackage, error) {
 			err = parseString(&res.Category, iterator)
 		case "tested-with":
 			err = parseString(&res.TestedWith, iterator)
+		case "data-dir":
+			err = parseString(&res.DataDir, iterator)
+		case "data-files":
+			err = parseList(&res.DataFiles, splitOptCommaList, iterator)
+		case "extra-source-files":
+			err = parseList(&res.ExtraSourceFiles, splitOptCommaList, iterator)
+		case "extra-doc-files":
+			err = parseList(&res.ExtraDocFiles, splitOptCommaList, iterator)
 		case "copyright":
 			err = parseStringArr(&res.Copyright, iterator)
 		case "description":
@@ -80,6 +176,14 @@ func (p *tokensParser) Parse(tokens []*token) (*CabalPackage, error) {
 			}
 
 			err = parseRepository(res.Repositories, iterator)
+		case "flag":
+			if res.Flags == nil {
+				res.Flags = make(map[string]*Flag)
+			}
+
+			err = parseFlag(res.Flags, iterator)
+		case "library":
+			err = parseLibrary(res, iterator)
 		case "executable":
 			if res.Executables == nil {
 				res.Executables = make(map[string]*Executable)
@@ -113,6 +217,42 @@ func parseString(to *string, iterator *tokensIterator) error {
 	return nil
 }
 
+func parseBool(to *bool, iterator *tokensIterator) error {
+	var s string
+
+	if err := parseString(&s, iterator); err != nil {
+		return err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true":
+		*to = true
+	case "false":
+		*to = false
+	default:
+		return fmt.Errorf("boolean expected, but got: %s", s)
+	}
+
+	return nil
+}
+
+func parseInt(to *int, iterator *tokensIterator) error {
+	var s string
+
+	if err := parseString(&s, iterator); err != nil {
+		return err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 0 {
+		return fmt.Errorf("non-negative integer expected, but got: %s", s)
+	}
+
+	*to = n
+
+	return nil
+}
+
 func parseStringArr(to *[]string, iterator *tokensIterator) error {
 	nextToken, ok := iterator.Seek()
 	if !ok || nextToken.Type != tokenTypeValue {
@@ -132,10 +272,27 @@ func parseStringArr(to *[]string, iterator *tokensIterator) error {
 	return nil
 }
 
+func parseList(to *[]string, split listSplitter, iterator *tokensIterator) error {
+	lines := make([]string, 0)
+
+	if err := parseStringArr(&lines, iterator); err != nil {
+		return err
+	}
+
+	values, err := split(strings.Join(lines, "\n"))
+	if err != nil {
+		return err
+	}
+
+	*to = append(*to, values...)
+
+	return nil
+}
+
 func parseDependencies(to *[]*Dependency, iterator *tokensIterator) error {
 	stringDeps := make([]string, 0)
 
-	if err := parseStringArr(&stringDeps, iterator); err != nil {
+	if err := parseList(&stringDeps, splitCommaList, iterator); err != nil {
 		return err
 	}
 
@@ -153,6 +310,84 @@ func parseDependencies(to *[]*Dependency, iterator *tokensIterator) error {
 	return nil
 }
 
+func parseMixins(to *[]*Mixin, iterator *tokensIterator) error {
+	stringMixins := make([]string, 0)
+
+	if err := parseList(&stringMixins, splitCommaList, iterator); err != nil {
+		return err
+	}
+
+	p := newMixinsParser()
+
+	for _, m := range stringMixins {
+		mixin, err := p.ParseString(m)
+		if err != nil {
+			return err
+		}
+
+		*to = append(*to, mixin)
+	}
+
+	return nil
+}
+
+func parseReexports(to *[]*ModuleReexport, iterator *tokensIterator) error {
+	stringReexports := make([]string, 0)
+
+	if err := parseList(&stringReexports, splitCommaList, iterator); err != nil {
+		return err
+	}
+
+	p := newReexportsParser()
+
+	for _, r := range stringReexports {
+		reexport, err := p.ParseString(r)
+		if err != nil {
+			return err
+		}
+
+		*to = append(*to, reexport)
+	}
+
+	return nil
+}
+
+func parseLanguage(to *Language, iterator *tokensIterator) error {
+	var s string
+
+	if err := parseString(&s, iterator); err != nil {
+		return err
+	}
+
+	l, err := ParseLanguage(strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+
+	*to = l
+
+	return nil
+}
+
+func parseModules(to *[]ModuleName, iterator *tokensIterator) error {
+	names := make([]string, 0)
+
+	if err := parseList(&names, splitOptCommaList, iterator); err != nil {
+		return err
+	}
+
+	for _, n := range names {
+		m, err := ParseModuleName(n)
+		if err != nil {
+			return err
+		}
+
+		*to = append(*to, m)
+	}
+
+	return nil
+}
+
 func parseRepository(to map[string]*SourceRepository, iterator *tokensIterator) error {
 	if !iterator.Next() {
 		return errors.New("repository name expected")
@@ -197,6 +432,50 @@ func parseRepository(to map[string]*SourceRepository, iterator *tokensIterator)
 	return nil
 }
 
+func parseFlag(to map[string]*Flag, iterator *tokensIterator) error {
+	if !iterator.Next() {
+		return errors.New("flag name expected")
+	}
+
+	token := iterator.Val()
+	if token.Type != tokenTypeScopeName || strings.TrimSpace(token.Value) == "" {
+		return errors.New("flag name expected")
+	}
+
+	flag := &Flag{Default: true}
+	flagName := strings.TrimSpace(token.Value)
+
+	for {
+		token, ok := iterator.Seek()
+		if !ok || !isOneOfProperties(token, flagProperties) {
+			break
+		}
+
+		iterator.Next()
+
+		var err error
+
+		switch strings.ToLower(token.Value) {
+		case "description":
+			lines := make([]string, 0)
+			err = parseStringArr(&lines, iterator)
+			flag.Description = strings.Join(lines, "\n")
+		case "default":
+			err = parseBool(&flag.Default, iterator)
+		case "manual":
+			err = parseBool(&flag.Manual, iterator)
+		}
+
+		if err != nil {
+			return fmt.Errorf("flag %s: %v", flagName, err)
+		}
+	}
+
+	to[flagName] = flag
+
+	return nil
+}
+
 func parseExecutable(to map[string]*Executable, iterator *tokensIterator) error {
 	if !iterator.Next() {
 		return errors.New("executable name expected")
@@ -220,19 +499,13 @@ func parseExecutable(to map[string]*Executable, iterator *tokensIterator) error
 
 		var err error
 
-		switch strings.ToLower(token.Value) {
-		case "build-depends":
-			err = parseDependencies(&ex.BuildDepends, iterator)
-		case "extensions":
-			err = parseStringArr(&ex.Extensions, iterator)
+		switch property := strings.ToLower(token.Value); property {
 		case "main-is":
 			err = parseString(&ex.MainIs, iterator)
-		case "other-modules":
-			err = parseStringArr(&ex.OtherModules, iterator)
-		case "hs-source-dirs":
-			err = parseStringArr(&ex.HSSourceDirs, iterator)
+		case "if":
+			err = parseConditional(&ex.Conditionals, executableProperties, iterator)
 		default:
-			return fmt.Errorf("unsupported executable property: '%s'", token.Value)
+			err = parseBuildInfo(&ex.BuildInfo, property, iterator)
 		}
 
 		if err != nil {
@@ -245,6 +518,288 @@ func parseExecutable(to map[string]*Executable, iterator *tokensIterator) error
 	return nil
 }
 
+func parseLibrary(to *CabalPackage, iterator *tokensIterator) error {
+	if !iterator.Next() {
+		return errors.New("library name expected")
+	}
+
+	token := iterator.Val()
+	if token.Type != tokenTypeScopeName {
+		return errors.New("library name expected")
+	}
+
+	lib := &Library{}
+	libName := strings.TrimSpace(token.Value)
+
+	for {
+		token, ok := iterator.Seek()
+		if !ok || !isLibraryProperty(token) {
+			break
+		}
+
+		iterator.Next()
+
+		var err error
+
+		switch property := strings.ToLower(token.Value); property {
+		case "exposed-modules":
+			err = parseModules(&lib.ExposedModules, iterator)
+		case "reexported-modules":
+			err = parseReexports(&lib.ReexportedModules, iterator)
+		case "signatures":
+			err = parseModules(&lib.Signatures, iterator)
+		case "if":
+			err = parseConditional(&lib.Conditionals, libraryProperties, iterator)
+		default:
+			err = parseBuildInfo(&lib.BuildInfo, property, iterator)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if libName == "" {
+		if to.Library != nil {
+			return errors.New("duplicate library declaration")
+		}
+
+		to.Library = lib
+
+		return nil
+	}
+
+	if to.SubLibraries == nil {
+		to.SubLibraries = make(map[string]*Library)
+	}
+
+	to.SubLibraries[libName] = lib
+
+	return nil
+}
+
+func parseBuildInfo(to *BuildInfo, property string, iterator *tokensIterator) error {
+	switch property {
+	case "build-depends":
+		return parseDependencies(&to.BuildDepends, iterator)
+	case "mixins":
+		return parseMixins(&to.Mixins, iterator)
+	case "default-language":
+		return parseLanguage(&to.DefaultLanguage, iterator)
+	case "extensions":
+		return parseList(&to.Extensions, splitOptCommaList, iterator)
+	case "default-extensions":
+		return parseList(&to.DefaultExtensions, splitOptCommaList, iterator)
+	case "other-extensions":
+		return parseList(&to.OtherExtensions, splitOptCommaList, iterator)
+	case "other-modules":
+		return parseModules(&to.OtherModules, iterator)
+	case "autogen-modules":
+		return parseModules(&to.AutogenModules, iterator)
+	case "hs-source-dirs":
+		return parseList(&to.HSSourceDirs, splitOptCommaList, iterator)
+	case "ghc-options":
+		return parseList(&to.GHCOptions, splitHaskellTokens, iterator)
+	case "c-sources":
+		return parseList(&to.CSources, splitOptCommaList, iterator)
+	case "include-dirs":
+		return parseList(&to.IncludeDirs, splitOptCommaList, iterator)
+	case "includes":
+		return parseList(&to.Includes, splitOptCommaList, iterator)
+	case "install-includes":
+		return parseList(&to.InstallIncludes, splitOptCommaList, iterator)
+	default:
+		return fmt.Errorf("unsupported build info property: '%s'", property)
+	}
+}
+
+// parseConditional parses an if block and its optional else block. An
+// elif block becomes an else branch holding a single nested conditional.
+// component holds the fields of the enclosing component allowed in the
+// branches besides build info.
+func parseConditional(to *[]*Conditional, component map[string]struct{}, iterator *tokensIterator) error {
+	if !iterator.Next() || iterator.Val().Type != tokenTypeScopeName {
+		return errors.New("condition expected")
+	}
+
+	c := &Conditional{Condition: strings.Join(strings.Fields(iterator.Val().Value), " ")}
+
+	if _, err := (&Platform{}).EvalCondition(c.Condition); err != nil {
+		return err
+	}
+
+	if err := parseConditionalBranch(&c.Then, component, iterator); err != nil {
+		return err
+	}
+
+	token, ok := iterator.Seek()
+	if ok && token.Type == tokenTypeKey && strings.EqualFold(token.Value, "elif") {
+		iterator.Next()
+
+		c.Else = &ConditionalBranch{}
+
+		if err := parseConditional(&c.Else.Conditionals, component, iterator); err != nil {
+			return err
+		}
+	} else if ok && token.Type == tokenTypeKey && strings.EqualFold(token.Value, "else") {
+		iterator.Next()
+
+		if !iterator.Next() || iterator.Val().Type != tokenTypeScopeName || strings.TrimSpace(iterator.Val().Value) != "" {
+			return errors.New("else takes no arguments")
+		}
+
+		c.Else = &ConditionalBranch{}
+
+		if err := parseConditionalBranch(c.Else, component, iterator); err != nil {
+			return err
+		}
+	}
+
+	*to = append(*to, c)
+
+	return nil
+}
+
+// parseConditionalBranch parses the fields of an if or else block up to
+// the end of its scope.
+func parseConditionalBranch(to *ConditionalBranch, component map[string]struct{}, iterator *tokensIterator) error {
+	for iterator.Next() {
+		token := iterator.Val()
+		if token.Type == tokenTypeScopeEnd {
+			return nil
+		}
+
+		if !isOneOfProperties(token, buildInfoProperties, component) {
+			return fmt.Errorf("unsupported conditional property: %s", token.Value)
+		}
+
+		var err error
+
+		switch property := strings.ToLower(token.Value); property {
+		case "exposed-modules":
+			err = parseModules(&to.ExposedModules, iterator)
+		case "reexported-modules":
+			err = parseReexports(&to.ReexportedModules, iterator)
+		case "signatures":
+			err = parseModules(&to.Signatures, iterator)
+		case "main-is":
+			err = parseString(&to.MainIs, iterator)
+		case "if":
+			err = parseConditional(&to.Conditionals, component, iterator)
+		default:
+			err = parseBuildInfo(&to.BuildInfo, property, iterator)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return errors.New("end of conditional expected")
+}
+
+// inlineImports replaces import fields by the contents of the common
+// stanzas they name, conditionals included, resolving imports of common
+// stanzas as well.
+func inlineImports(nodes []*SyntaxNode) ([]*SyntaxNode, error) {
+	common := make(map[string]*SyntaxNode)
+
+	for _, n := range nodes {
+		if n.Kind == SyntaxSection && strings.EqualFold(n.Name, "common") {
+			common[strings.TrimSpace(n.Args)] = n
+		}
+	}
+
+	return expandImports(nodes, common, nil)
+}
+
+func expandImports(nodes []*SyntaxNode, common map[string]*SyntaxNode, importing []string) ([]*SyntaxNode, error) {
+	res := make([]*SyntaxNode, 0, len(nodes))
+
+	for _, n := range nodes {
+		switch {
+		case n.Kind == SyntaxField && strings.EqualFold(n.Name, "import"):
+			names, err := splitOptCommaList(strings.Join(n.Value(), "\n"))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, name := range names {
+				stanza, ok := common[name]
+				if !ok {
+					return nil, fmt.Errorf("unknown common stanza: %s", name)
+				}
+
+				if containsString(importing, name) {
+					return nil, fmt.Errorf("cyclic import of common stanza: %s", name)
+				}
+
+				children, err := expandImports(stanza.Children, common, append(importing, name))
+				if err != nil {
+					return nil, err
+				}
+
+				res = append(res, children...)
+			}
+		case n.Kind == SyntaxSection:
+			children, err := expandImports(n.Children, common, importing)
+			if err != nil {
+				return nil, err
+			}
+
+			section := *n
+			section.Children = children
+			res = append(res, &section)
+		default:
+			res = append(res, n)
+		}
+	}
+
+	return res, nil
+}
+
+// modeledNodes returns the nodes without unmodeled sections and fields,
+// skipping them in section contents as well. Fields left empty, which
+// cabal reads as unset, are skipped too.
+func modeledNodes(nodes []*SyntaxNode) []*SyntaxNode {
+	res := make([]*SyntaxNode, 0, len(nodes))
+
+	for _, n := range nodes {
+		name := strings.ToLower(n.Name)
+
+		switch n.Kind {
+		case SyntaxSection:
+			if _, ok := unmodeledSections[name]; ok {
+				continue
+			}
+
+			section := *n
+			section.Children = modeledNodes(n.Children)
+			n = &section
+		case SyntaxField:
+			if _, ok := unmodeledFields[name]; ok {
+				continue
+			}
+
+			if strings.HasPrefix(name, "x-") && name != "x-revision" {
+				continue
+			}
+
+			if len(n.Value()) == 0 {
+				continue
+			}
+		}
+
+		res = append(res, n)
+	}
+
+	return res
+}
+
+func isConditionalSection(name string) bool {
+	return strings.EqualFold(name, "if") || strings.EqualFold(name, "elif") || strings.EqualFold(name, "else")
+}
+
 func isRepoProperty(t *token) bool {
 	if t.Type != tokenTypeKey {
 		return false
@@ -255,12 +810,24 @@ func isRepoProperty(t *token) bool {
 	return ok
 }
 
+func isLibraryProperty(t *token) bool {
+	return isOneOfProperties(t, buildInfoProperties, libraryProperties)
+}
+
 func isExecutableProperty(t *token) bool {
+	return isOneOfProperties(t, buildInfoProperties, executableProperties)
+}
+
+func isOneOfProperties(t *token, sets ...map[string]struct{}) bool {
 	if t.Type != tokenTypeKey {
 		return false
 	}
 
-	_, ok := executableProperties[strings.ToLower(t.Value)]
+	for _, properties := range sets {
+		if _, ok := properties[strings.ToLower(t.Value)]; ok {
+			return true
+		}
+	}
 
-	return ok
+	return false
 }
@@ -2,6 +2,7 @@ package gocabalparser
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -24,10 +25,13 @@ func TestTokensParser(t *testing.T) {
 				testMakeToken(tokenTypeValue, "Simple"),
 				testMakeToken(tokenTypeKey, "License"),
 				testMakeToken(tokenTypeValue, "BSD3"),
+				testMakeToken(tokenTypeKey, "x-revision"),
+				testMakeToken(tokenTypeValue, "3"),
 			},
 			expected: &CabalPackage{
 				Name:         "Some name",
 				Version:      "v1.0.0.0",
+				Revision:     3,
 				CabalVersion: "v1.0.1.1",
 				BuildType:    "Simple",
 				License:      "BSD3",
@@ -120,9 +124,9 @@ func TestTokensParser(t *testing.T) {
 				testMakeToken(tokenTypeKey, "Executable"),
 				testMakeToken(tokenTypeScopeName, "mountains"),
 				testMakeToken(tokenTypeKey, "Build-Depends"),
-				testMakeToken(tokenTypeValue, "base   >= 3.0 && < 5"),
-				testMakeToken(tokenTypeValue, "GLUT   >= 2.4 && < 2.8"),
-				testMakeToken(tokenTypeValue, "OpenGL >= 2.8 && < 3.1"),
+				testMakeToken(tokenTypeValue, "base   >= 3.0 && < 5,"),
+				testMakeToken(tokenTypeValue, "GLUT   >= 2.4 && < 2.8,"),
+				testMakeToken(tokenTypeValue, "OpenGL >= 2.8 && < 3.1,"),
 				testMakeToken(tokenTypeValue, "random >= 1.0 && < 1.2"),
 				testMakeToken(tokenTypeKey, "Extensions"),
 				testMakeToken(tokenTypeValue, "FlexibleContexts"),
@@ -135,8 +139,8 @@ func TestTokensParser(t *testing.T) {
 				testMakeToken(tokenTypeKey, "Executable"),
 				testMakeToken(tokenTypeScopeName, "l-systems"),
 				testMakeToken(tokenTypeKey, "Build-Depends"),
-				testMakeToken(tokenTypeValue, "base   >= 3.0 && < 5"),
-				testMakeToken(tokenTypeValue, "GLUT   >= 2.4 && < 2.8"),
+				testMakeToken(tokenTypeValue, "base   >= 3.0 && < 5,"),
+				testMakeToken(tokenTypeValue, "GLUT   >= 2.4 && < 2.8,"),
 				testMakeToken(tokenTypeValue, "OpenGL >= 2.8 && < 3.1"),
 				testMakeToken(tokenTypeKey, "Extensions"),
 				testMakeToken(tokenTypeValue, "FlexibleContexts"),
@@ -156,72 +160,259 @@ func TestTokensParser(t *testing.T) {
 			expected: &CabalPackage{
 				Executables: map[string]*Executable{
 					"mountains": {
-						BuildDepends: []*Dependency{
-							{
-								Name:               "base",
-								GreaterOrEqualThan: 3.0,
-								LessThan:           5,
+						BuildInfo: BuildInfo{
+							BuildDepends: []*Dependency{
+								{
+									Name:               "base",
+									GreaterOrEqualThan: 3.0,
+									LessThan:           5,
+									Range:              mustParseVersionRange(">= 3.0 && < 5"),
+								},
+								{
+									Name:               "GLUT",
+									GreaterOrEqualThan: 2.4,
+									LessThan:           2.8,
+									Range:              mustParseVersionRange(">= 2.4 && < 2.8"),
+								},
+								{
+									Name:               "OpenGL",
+									GreaterOrEqualThan: 2.8,
+									LessThan:           3.1,
+									Range:              mustParseVersionRange(">= 2.8 && < 3.1"),
+								},
+								{
+									Name:               "random",
+									GreaterOrEqualThan: 1.0,
+									LessThan:           1.2,
+									Range:              mustParseVersionRange(">= 1.0 && < 1.2"),
+								},
 							},
-							{
-								Name:               "GLUT",
-								GreaterOrEqualThan: 2.4,
-								LessThan:           2.8,
+							Extensions: []string{
+								"FlexibleContexts",
 							},
-							{
-								Name:               "OpenGL",
-								GreaterOrEqualThan: 2.8,
-								LessThan:           3.1,
+							OtherModules: []ModuleName{
+								"Utilities",
 							},
-							{
-								Name:               "random",
-								GreaterOrEqualThan: 1.0,
-								LessThan:           1.2,
+							HSSourceDirs: []string{
+								"src",
+								"src/mountains",
 							},
 						},
-						Extensions: []string{
-							"FlexibleContexts",
-						},
 						MainIs: "Mountains.hs",
-						OtherModules: []string{
-							"Utilities",
-						},
-						HSSourceDirs: []string{
-							"src src/mountains",
-						},
 					},
 					"l-systems": {
-						BuildDepends: []*Dependency{
+						BuildInfo: BuildInfo{
+							BuildDepends: []*Dependency{
+								{
+									Name:               "base",
+									GreaterOrEqualThan: 3.0,
+									LessThan:           5,
+									Range:              mustParseVersionRange(">= 3.0 && < 5"),
+								},
+								{
+									Name:               "GLUT",
+									GreaterOrEqualThan: 2.4,
+									LessThan:           2.8,
+									Range:              mustParseVersionRange(">= 2.4 && < 2.8"),
+								},
+								{
+									Name:               "OpenGL",
+									GreaterOrEqualThan: 2.8,
+									LessThan:           3.1,
+									Range:              mustParseVersionRange(">= 2.8 && < 3.1"),
+								},
+							},
+							Extensions: []string{
+								"FlexibleContexts",
+							},
+							OtherModules: []ModuleName{
+								"Utilities",
+								"ConiferLSystem",
+								"IslandLSystem",
+								"KochLSystem",
+								"LSystem",
+								"TreeLSystem",
+								"Turtle",
+							},
+							HSSourceDirs: []string{
+								"src",
+								"src/l-systems",
+							},
+						},
+						MainIs: "LSystems.hs",
+					},
+				},
+			},
+		},
+		{
+			name: "backpack library fields",
+			tokens: tokens{
+				testMakeToken(tokenTypeKey, "library"),
+				testMakeToken(tokenTypeScopeName, ""),
+				testMakeToken(tokenTypeKey, "signatures"),
+				testMakeToken(tokenTypeValue, "Str"),
+				testMakeToken(tokenTypeKey, "exposed-modules"),
+				testMakeToken(tokenTypeValue, "Regex"),
+				testMakeToken(tokenTypeKey, "mixins"),
+				testMakeToken(tokenTypeValue, "str-bytestring (Str as Str.ByteString, Str.Internal)"),
+				testMakeToken(tokenTypeValue, "  requires (Str as Str.Sig),"),
+				testMakeToken(tokenTypeValue, "str-text:impl"),
+			},
+			expected: &CabalPackage{
+				Library: &Library{
+					BuildInfo: BuildInfo{
+						Mixins: []*Mixin{
 							{
-								Name:               "base",
-								GreaterOrEqualThan: 3.0,
-								LessThan:           5,
+								PackageName: "str-bytestring",
+								Includes: ModuleRenaming{
+									Kind: ModuleRenamingExplicit,
+									Renames: []ModuleRename{
+										{From: "Str", To: "Str.ByteString"},
+										{From: "Str.Internal", To: "Str.Internal"},
+									},
+								},
+								Requires: ModuleRenaming{
+									Kind: ModuleRenamingExplicit,
+									Renames: []ModuleRename{
+										{From: "Str", To: "Str.Sig"},
+									},
+								},
 							},
 							{
-								Name:               "GLUT",
-								GreaterOrEqualThan: 2.4,
-								LessThan:           2.8,
+								PackageName: "str-text",
+								LibraryName: "impl",
 							},
+						},
+					},
+					ExposedModules: []ModuleName{"Regex"},
+					Signatures:     []ModuleName{"Str"},
+				},
+			},
+		},
+		{
+			name: "reexported modules",
+			tokens: tokens{
+				testMakeToken(tokenTypeKey, "library"),
+				testMakeToken(tokenTypeScopeName, ""),
+				testMakeToken(tokenTypeKey, "build-depends"),
+				testMakeToken(tokenTypeValue, "containers"),
+				testMakeToken(tokenTypeKey, "reexported-modules"),
+				testMakeToken(tokenTypeValue, "containers:Data.Map as Map,"),
+				testMakeToken(tokenTypeValue, "Data.Set"),
+			},
+			expected: &CabalPackage{
+				Library: &Library{
+					BuildInfo: BuildInfo{
+						BuildDepends: []*Dependency{
 							{
-								Name:               "OpenGL",
-								GreaterOrEqualThan: 2.8,
-								LessThan:           3.1,
+								Name:     "containers",
+								IsLatest: true,
+								Range:    &VersionRange{},
 							},
 						},
-						Extensions: []string{
-							"FlexibleContexts",
+					},
+					ReexportedModules: []*ModuleReexport{
+						{
+							OriginalPackage: "containers",
+							OriginalName:    "Data.Map",
+							NewName:         "Map",
 						},
-						MainIs: "LSystems.hs",
-						OtherModules: []string{
-							"Utilities",
-							"ConiferLSystem",
-							"IslandLSystem",
-							"KochLSystem",
-							"LSystem",
-							"TreeLSystem",
-							"Turtle",
+						{
+							OriginalName: "Data.Set",
+							NewName:      "Data.Set",
 						},
-						HSSourceDirs: []string{
-							"src src/l-systems",
+					},
+				},
+			},
+		},
+		{
+			name: "c sources",
+			tokens: tokens{
+				testMakeToken(tokenTypeKey, "library"),
+				testMakeToken(tokenTypeScopeName, ""),
+				testMakeToken(tokenTypeKey, "c-sources"),
+				testMakeToken(tokenTypeValue, "cbits/a.c,"),
+				testMakeToken(tokenTypeValue, "cbits/b.c"),
+				testMakeToken(tokenTypeKey, "include-dirs"),
+				testMakeToken(tokenTypeValue, "include"),
+				testMakeToken(tokenTypeKey, "includes"),
+				testMakeToken(tokenTypeValue, "stdio.h"),
+				testMakeToken(tokenTypeKey, "install-includes"),
+				testMakeToken(tokenTypeValue, "a.h b.h"),
+			},
+			expected: &CabalPackage{
+				Library: &Library{
+					BuildInfo: BuildInfo{
+						CSources:        []string{"cbits/a.c", "cbits/b.c"},
+						IncludeDirs:     []string{"include"},
+						Includes:        []string{"stdio.h"},
+						InstallIncludes: []string{"a.h", "b.h"},
+					},
+				},
+			},
+		},
+		{
+			name: "component fields in conditionals",
+			tokens: tokens{
+				testMakeToken(tokenTypeKey, "library"),
+				testMakeToken(tokenTypeScopeName, ""),
+				testMakeToken(tokenTypeKey, "if"),
+				testMakeToken(tokenTypeScopeName, "os(windows)"),
+				testMakeToken(tokenTypeKey, "exposed-modules"),
+				testMakeToken(tokenTypeValue, "System.Win32"),
+				testMakeToken(tokenTypeScopeEnd, ""),
+				testMakeToken(tokenTypeKey, "else"),
+				testMakeToken(tokenTypeScopeName, ""),
+				testMakeToken(tokenTypeKey, "reexported-modules"),
+				testMakeToken(tokenTypeValue, "unix:System.Posix"),
+				testMakeToken(tokenTypeKey, "if"),
+				testMakeToken(tokenTypeScopeName, "flag(sig)"),
+				testMakeToken(tokenTypeKey, "signatures"),
+				testMakeToken(tokenTypeValue, "Str"),
+				testMakeToken(tokenTypeScopeEnd, ""),
+				testMakeToken(tokenTypeScopeEnd, ""),
+				testMakeToken(tokenTypeKey, "executable"),
+				testMakeToken(tokenTypeScopeName, "acme"),
+				testMakeToken(tokenTypeKey, "if"),
+				testMakeToken(tokenTypeScopeName, "flag(dev)"),
+				testMakeToken(tokenTypeKey, "main-is"),
+				testMakeToken(tokenTypeValue, "Dev.hs"),
+				testMakeToken(tokenTypeScopeEnd, ""),
+			},
+			expected: &CabalPackage{
+				Library: &Library{
+					BuildInfo: BuildInfo{
+						Conditionals: []*Conditional{
+							{
+								Condition: "os(windows)",
+								Then:      ConditionalBranch{ExposedModules: []ModuleName{"System.Win32"}},
+								Else: &ConditionalBranch{
+									BuildInfo: BuildInfo{
+										Conditionals: []*Conditional{
+											{
+												Condition: "flag(sig)",
+												Then:      ConditionalBranch{Signatures: []ModuleName{"Str"}},
+											},
+										},
+									},
+									ReexportedModules: []*ModuleReexport{
+										{
+											OriginalPackage: "unix",
+											OriginalName:    "System.Posix",
+											NewName:         "System.Posix",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				Executables: map[string]*Executable{
+					"acme": {
+						BuildInfo: BuildInfo{
+							Conditionals: []*Conditional{
+								{Condition: "flag(dev)", Then: ConditionalBranch{MainIs: "Dev.hs"}},
+							},
 						},
 					},
 				},
@@ -245,3 +436,205 @@ func TestTokensParser(t *testing.T) {
 		})
 	}
 }
+
+func TestTokensParser_errors(t *testing.T) {
+	cases := []struct {
+		name   string
+		tokens tokens
+	}{
+		{
+			name: "invalid module name",
+			tokens: tokens{
+				testMakeToken(tokenTypeKey, "library"),
+				testMakeToken(tokenTypeScopeName, ""),
+				testMakeToken(tokenTypeKey, "exposed-modules"),
+				testMakeToken(tokenTypeValue, "data.Map"),
+			},
+		},
+		{
+			name: "invalid condition",
+			tokens: tokens{
+				testMakeToken(tokenTypeKey, "library"),
+				testMakeToken(tokenTypeScopeName, ""),
+				testMakeToken(tokenTypeKey, "if"),
+				testMakeToken(tokenTypeScopeName, "os(windows) &&"),
+				testMakeToken(tokenTypeScopeEnd, ""),
+			},
+		},
+		{
+			name: "unsupported conditional property",
+			tokens: tokens{
+				testMakeToken(tokenTypeKey, "executable"),
+				testMakeToken(tokenTypeScopeName, "acme"),
+				testMakeToken(tokenTypeKey, "if"),
+				testMakeToken(tokenTypeScopeName, "os(windows)"),
+				testMakeToken(tokenTypeKey, "exposed-modules"),
+				testMakeToken(tokenTypeValue, "Data.Map"),
+				testMakeToken(tokenTypeScopeEnd, ""),
+			},
+		},
+		{
+			name: "unterminated conditional",
+			tokens: tokens{
+				testMakeToken(tokenTypeKey, "library"),
+				testMakeToken(tokenTypeScopeName, ""),
+				testMakeToken(tokenTypeKey, "if"),
+				testMakeToken(tokenTypeScopeName, "os(windows)"),
+				testMakeToken(tokenTypeKey, "ghc-options"),
+				testMakeToken(tokenTypeValue, "-Wall"),
+			},
+		},
+		{
+			name: "else with arguments",
+			tokens: tokens{
+				testMakeToken(tokenTypeKey, "library"),
+				testMakeToken(tokenTypeScopeName, ""),
+				testMakeToken(tokenTypeKey, "if"),
+				testMakeToken(tokenTypeScopeName, "os(windows)"),
+				testMakeToken(tokenTypeScopeEnd, ""),
+				testMakeToken(tokenTypeKey, "else"),
+				testMakeToken(tokenTypeScopeName, "os(linux)"),
+				testMakeToken(tokenTypeScopeEnd, ""),
+			},
+		},
+		{
+			name: "revision",
+			tokens: tokens{
+				testMakeToken(tokenTypeKey, "x-revision"),
+				testMakeToken(tokenTypeValue, "two"),
+			},
+		},
+		{
+			name: "flag default",
+			tokens: tokens{
+				testMakeToken(tokenTypeKey, "flag"),
+				testMakeToken(tokenTypeScopeName, "dev"),
+				testMakeToken(tokenTypeKey, "default"),
+				testMakeToken(tokenTypeValue, "yes"),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := newTokensParser().Parse(tc.tokens); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestParser_ParseReader_versionSets(t *testing.T) {
+	p, err := NewParser().ParseReader(strings.NewReader(`name: acme
+version: 1.0
+library
+  build-depends: base ^>= {4.14, 4.15},
+                 text == { 1.2.5.0, 2.0 },
+                 foo:{bar, baz} >= 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := make([]string, 0)
+	for _, d := range p.Library.BuildDepends {
+		actual = append(actual, d.String())
+	}
+
+	expected := []string{"base ^>= 4.14 || ^>= 4.15", "text == 1.2.5.0 || == 2.0", "foo:{bar, baz} >= 1"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestParser_ParseReader_elif(t *testing.T) {
+	p, err := NewParser().ParseReader(strings.NewReader(`name: acme
+version: 1.0
+library
+  build-depends: base
+  if os(windows)
+    build-depends: Win32
+  elif os(osx)
+    buildable: False
+  elif flag(pkg-config)
+    pkgconfig-depends: zlib
+  else
+    extra-libraries: z
+    build-depends: unix
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conds := p.Library.Conditionals
+	if len(conds) != 1 || conds[0].Condition != "os(windows)" || conds[0].Else == nil {
+		t.Fatalf("unexpected conditionals: %+v", conds)
+	}
+
+	osx := conds[0].Else.Conditionals
+	if len(osx) != 1 || osx[0].Condition != "os(osx)" || osx[0].Else == nil {
+		t.Fatalf("unexpected elif: %+v", osx)
+	}
+
+	pkgConfig := osx[0].Else.Conditionals
+	if len(pkgConfig) != 1 || pkgConfig[0].Condition != "flag(pkg-config)" || pkgConfig[0].Else == nil {
+		t.Fatalf("unexpected second elif: %+v", pkgConfig)
+	}
+
+	expected := []*Dependency{mustParseDependency("unix")}
+	if deps := pkgConfig[0].Else.BuildDepends; !reflect.DeepEqual(deps, expected) {
+		t.Fatalf("unexpected else: %+v", pkgConfig[0].Else)
+	}
+}
+
+func TestParser_ParseReader_commonStanzas(t *testing.T) {
+	p, err := NewParser().ParseReader(strings.NewReader(`cabal-version: 3.0
+name: acme
+version: 1.0
+
+common deps
+  build-depends: base >= 4 && < 5
+
+common warnings
+  import: deps
+  ghc-options: -Wall
+  if flag(dev)
+    ghc-options: -Werror
+
+library
+  import: warnings
+  exposed-modules: Acme
+  build-depends: text
+
+executable acme
+  import: deps
+  main-is: Main.hs
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lib := p.Library
+
+	expectedDeps := []*Dependency{mustParseDependency("base >= 4 && < 5"), mustParseDependency("text")}
+	if !reflect.DeepEqual(lib.BuildDepends, expectedDeps) {
+		t.Fatalf("expected %v, got %v", expectedDeps, lib.BuildDepends)
+	}
+
+	if !reflect.DeepEqual(lib.GHCOptions, []string{"-Wall"}) || len(lib.Conditionals) != 1 || lib.Conditionals[0].Condition != "flag(dev)" {
+		t.Fatalf("unexpected imported build info: %+v", lib.BuildInfo)
+	}
+
+	if deps := p.Executables["acme"].BuildDepends; !reflect.DeepEqual(deps, expectedDeps[:1]) {
+		t.Fatalf("unexpected executable dependencies: %v", deps)
+	}
+
+	for name, input := range map[string]string{
+		"unknown stanza": "name: acme\nlibrary\n  import: missing\n",
+		"cyclic import":  "name: acme\ncommon a\n  import: b\ncommon b\n  import: a\nlibrary\n  import: a\n",
+	} {
+		if _, err := NewParser().ParseReader(strings.NewReader(input)); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package gocabalparser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const stanzaIndent = "  "
+
+type Printer interface {
+	Print(w io.Writer, p *CabalPackage) error
+}
+
+type printer struct{}
+
+func NewPrinter() Printer {
+	return &printer{}
+}
+
+// printField is a single field of a rendered block. Lines holds one line
+// per element; commaList marks lists rendered with leading commas.
+// Comments, when set, holds the comment lines written before each line.
+type printField struct {
+	name      string
+	lines     []string
+	commaList bool
+	comments  [][]string
+}
+
+// Print renders the package as a canonical .cabal file: package fields
+// first, then source repositories, the main library, sub-libraries and
+// executables. Field values are aligned within each block.
+func (pr *printer) Print(w io.Writer, p *CabalPackage) error {
+	bw := bufio.NewWriter(w)
+
+	writeFields(bw, "", packageFields(p))
+
+	for _, name := range sortedKeys(p.Repositories) {
+		fmt.Fprintf(bw, "\nsource-repository %s\n", name)
+		writeFields(bw, stanzaIndent, repositoryFields(p.Repositories[name]))
+	}
+
+	for _, name := range sortedKeys(p.Flags) {
+		fmt.Fprintf(bw, "\nflag %s\n", name)
+		writeFields(bw, stanzaIndent, flagFields(p.Flags[name]))
+	}
+
+	if p.Library != nil {
+		bw.WriteString("\nlibrary\n")
+		writeFields(bw, stanzaIndent, libraryFields(p.Library))
+		writeConditionals(bw, stanzaIndent, p.Library.Conditionals)
+	}
+
+	for _, name := range sortedKeys(p.SubLibraries) {
+		fmt.Fprintf(bw, "\nlibrary %s\n", name)
+		writeFields(bw, stanzaIndent, libraryFields(p.SubLibraries[name]))
+		writeConditionals(bw, stanzaIndent, p.SubLibraries[name].Conditionals)
+	}
+
+	for _, name := range sortedKeys(p.Executables) {
+		fmt.Fprintf(bw, "\nexecutable %s\n", name)
+		writeFields(bw, stanzaIndent, executableFields(p.Executables[name]))
+		writeConditionals(bw, stanzaIndent, p.Executables[name].Conditionals)
+	}
+
+	return bw.Flush()
+}
+
+func packageFields(p *CabalPackage) []printField {
+	return nonEmptyFields(
+		stringField("cabal-version", p.CabalVersion),
+		stringField("name", p.Name),
+		stringField("version", p.Version),
+		revisionField(p.Revision),
+		printField{name: "synopsis", lines: p.Synopsis},
+		printField{name: "description", lines: p.Description},
+		stringField("homepage", p.Homepage),
+		stringField("package-url", p.PackageURL),
+		stringField("license", p.License),
+		stringField("license-file", p.LicenseFile),
+		printField{name: "copyright", lines: p.Copyright},
+		stringField("author", p.Author),
+		stringField("maintainer", p.Maintainer),
+		stringField("stability", p.Stability),
+		stringField("category", p.Category),
+		stringField("build-type", p.BuildType),
+		stringField("tested-with", p.TestedWith),
+		stringField("data-dir", p.DataDir),
+		printField{name: "data-files", lines: quoteAll(p.DataFiles)},
+		printField{name: "extra-source-files", lines: quoteAll(p.ExtraSourceFiles)},
+		printField{name: "extra-doc-files", lines: quoteAll(p.ExtraDocFiles)},
+	)
+}
+
+func repositoryFields(r *SourceRepository) []printField {
+	return nonEmptyFields(
+		stringField("type", r.Type),
+		stringField("location", r.Location),
+		stringField("tag", r.Tag),
+	)
+}
+
+func flagFields(f *Flag) []printField {
+	var description []string
+	if f.Description != "" {
+		description = strings.Split(f.Description, "\n")
+	}
+
+	return nonEmptyFields(
+		printField{name: "description", lines: description},
+		stringField("default", strconv.FormatBool(f.Default)),
+		stringField("manual", strconv.FormatBool(f.Manual)),
+	)
+}
+
+func libraryFields(l *Library) []printField {
+	fields := []printField{
+		{name: "exposed-modules", lines: moduleLines(l.ExposedModules)},
+		{name: "reexported-modules", lines: reexportLines(l.ReexportedModules), commaList: true},
+		{name: "signatures", lines: moduleLines(l.Signatures)},
+	}
+
+	return nonEmptyFields(append(fields, buildInfoFields(&l.BuildInfo)...)...)
+}
+
+// branchFields returns the fields of an if or else block, the component
+// fields first as in libraryFields and executableFields.
+func branchFields(b *ConditionalBranch) []printField {
+	fields := []printField{
+		stringField("main-is", b.MainIs),
+		{name: "exposed-modules", lines: moduleLines(b.ExposedModules)},
+		{name: "reexported-modules", lines: reexportLines(b.ReexportedModules), commaList: true},
+		{name: "signatures", lines: moduleLines(b.Signatures)},
+	}
+
+	return nonEmptyFields(append(fields, buildInfoFields(&b.BuildInfo)...)...)
+}
+
+func reexportLines(reexports []*ModuleReexport) []string {
+	res := make([]string, len(reexports))
+	for i, r := range reexports {
+		res[i] = r.String()
+	}
+
+	return res
+}
+
+func executableFields(e *Executable) []printField {
+	fields := []printField{
+		stringField("main-is", e.MainIs),
+	}
+
+	return nonEmptyFields(append(fields, buildInfoFields(&e.BuildInfo)...)...)
+}
+
+func buildInfoFields(bi *BuildInfo) []printField {
+	deps := make([]string, len(bi.BuildDepends))
+	for i, d := range bi.BuildDepends {
+		deps[i] = d.String()
+	}
+
+	mixins := make([]string, len(bi.Mixins))
+	for i, m := range bi.Mixins {
+		mixins[i] = m.String()
+	}
+
+	ghcOptions := ""
+	if len(bi.GHCOptions) > 0 {
+		ghcOptions = strings.Join(quoteAll(bi.GHCOptions), " ")
+	}
+
+	return []printField{
+		{name: "other-modules", lines: moduleLines(bi.OtherModules)},
+		{name: "autogen-modules", lines: moduleLines(bi.AutogenModules)},
+		{name: "hs-source-dirs", lines: quoteAll(bi.HSSourceDirs)},
+		{name: "build-depends", lines: deps, commaList: true},
+		{name: "mixins", lines: mixins, commaList: true},
+		stringField("default-language", string(bi.DefaultLanguage)),
+		{name: "default-extensions", lines: bi.DefaultExtensions},
+		{name: "other-extensions", lines: bi.OtherExtensions},
+		{name: "extensions", lines: bi.Extensions},
+		stringField("ghc-options", ghcOptions),
+		{name: "c-sources", lines: quoteAll(bi.CSources)},
+		{name: "include-dirs", lines: quoteAll(bi.IncludeDirs)},
+		{name: "includes", lines: quoteAll(bi.Includes)},
+		{name: "install-includes", lines: quoteAll(bi.InstallIncludes)},
+	}
+}
+
+// writeConditionals writes if blocks below the fields of a component,
+// nesting the blocks of each branch one level deeper.
+func writeConditionals(w *bufio.Writer, indent string, conditionals []*Conditional) {
+	for _, c := range conditionals {
+		fmt.Fprintf(w, "%sif %s\n", indent, c.Condition)
+		writeFields(w, indent+stanzaIndent, branchFields(&c.Then))
+		writeConditionals(w, indent+stanzaIndent, c.Then.Conditionals)
+
+		if c.Else != nil {
+			fmt.Fprintf(w, "%selse\n", indent)
+			writeFields(w, indent+stanzaIndent, branchFields(c.Else))
+			writeConditionals(w, indent+stanzaIndent, c.Else.Conditionals)
+		}
+	}
+}
+
+func writeFields(w *bufio.Writer, indent string, fields []printField) {
+	width := 0
+
+	for _, f := range fields {
+		if len(f.name) > width {
+			width = len(f.name)
+		}
+	}
+
+	for _, f := range fields {
+		writeField(w, indent, width, f)
+	}
+}
+
+// writeField writes a field with its value aligned after a name column of
+// the given width. An empty first line leaves the header without a value,
+// empty continuation lines are written as blank lines.
+func writeField(w *bufio.Writer, indent string, width int, f printField) {
+	for i, line := range f.lines {
+		if i < len(f.comments) {
+			for _, c := range f.comments[i] {
+				if i == 0 {
+					fmt.Fprintf(w, "%s%s\n", indent, c)
+				} else {
+					fmt.Fprintf(w, "%s%*s %s\n", indent, width+1, "", c)
+				}
+			}
+		}
+
+		switch {
+		case i == 0 && line == "":
+			fmt.Fprintf(w, "%s%s:\n", indent, f.name)
+		case i == 0:
+			fmt.Fprintf(w, "%s%-*s %s\n", indent, width+1, f.name+":", line)
+		case line == "":
+			w.WriteString("\n")
+		case f.commaList:
+			fmt.Fprintf(w, "%s%*s, %s\n", indent, width, "", line)
+		default:
+			fmt.Fprintf(w, "%s%*s %s\n", indent, width+1, "", line)
+		}
+	}
+}
+
+func stringField(name, value string) printField {
+	if value == "" {
+		return printField{name: name}
+	}
+
+	return printField{name: name, lines: []string{value}}
+}
+
+func revisionField(revision int) printField {
+	if revision == 0 {
+		return printField{name: "x-revision"}
+	}
+
+	return stringField("x-revision", strconv.Itoa(revision))
+}
+
+func nonEmptyFields(fields ...printField) []printField {
+	res := make([]printField, 0, len(fields))
+
+	for _, f := range fields {
+		if len(f.lines) > 0 {
+			res = append(res, f)
+		}
+	}
+
+	return res
+}
+
+func moduleLines(modules []ModuleName) []string {
+	res := make([]string, len(modules))
+
+	for i, m := range modules {
+		res[i] = string(m)
+	}
+
+	return res
+}
+
+// quoteAll quotes the values which would otherwise be split apart.
+func quoteAll(values []string) []string {
+	res := make([]string, len(values))
+
+	for i, v := range values {
+		if v == "" || strings.ContainsAny(v, " \t\n,\"\\") {
+			v = strconv.Quote(v)
+		}
+
+		res[i] = v
+	}
+
+	return res
+}
+
+func (d *Dependency) String() string {
+	if r := d.VersionRange(); r.Kind != VersionRangeAny {
+		return d.Name + " " + r.String()
+	}
+
+	return d.Name
+}
+
+func (r ModuleRename) String() string {
+	if r.From == r.To {
+		return string(r.From)
+	}
+
+	return fmt.Sprintf("%s as %s", r.From, r.To)
+}
+
+func (r ModuleRenaming) String() string {
+	switch r.Kind {
+	case ModuleRenamingExplicit:
+		renames := make([]string, len(r.Renames))
+		for i, rename := range r.Renames {
+			renames[i] = rename.String()
+		}
+
+		return "(" + strings.Join(renames, ", ") + ")"
+	case ModuleRenamingHiding:
+		return "hiding (" + strings.Join(moduleLines(r.Hiding), ", ") + ")"
+	default:
+		return ""
+	}
+}
+
+func (m *Mixin) String() string {
+	res := m.PackageName
+	if m.LibraryName != "" {
+		res += ":" + m.LibraryName
+	}
+
+	if m.Includes.Kind != ModuleRenamingDefault {
+		res += " " + m.Includes.String()
+	}
+
+	if m.Requires.Kind != ModuleRenamingDefault {
+		res += " requires " + m.Requires.String()
+	}
+
+	return res
+}
+
+func (r *ModuleReexport) String() string {
+	res := string(r.OriginalName)
+	if r.OriginalPackage != "" {
+		res = r.OriginalPackage + ":" + res
+	}
+
+	if r.NewName != r.OriginalName {
+		res += " as " + string(r.NewName)
+	}
+
+	return res
+}
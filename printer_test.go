@@ -0,0 +1,171 @@
+package gocabalparser
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPrinter_Print(t *testing.T) {
+	p := &CabalPackage{
+		Name:         "backpack-example",
+		Version:      "1.0",
+		Revision:     2,
+		CabalVersion: "3.0",
+		License:      "MIT",
+		Library: &Library{
+			BuildInfo: BuildInfo{
+				BuildDepends: []*Dependency{
+					{Name: "base", GreaterOrEqualThan: 4.14, LessThan: 5},
+					{Name: "str-sig", IsLatest: true},
+				},
+				Mixins: []*Mixin{
+					{
+						PackageName: "str-bytestring",
+						Includes: ModuleRenaming{
+							Kind:    ModuleRenamingExplicit,
+							Renames: []ModuleRename{{From: "Str", To: "Str.ByteString"}},
+						},
+						Requires: ModuleRenaming{
+							Kind:   ModuleRenamingHiding,
+							Hiding: []ModuleName{"Str.Internal"},
+						},
+					},
+				},
+				HSSourceDirs:    []string{"src", "my sources"},
+				DefaultLanguage: GHC2021,
+			},
+			ExposedModules: []ModuleName{"Regex"},
+			ReexportedModules: []*ModuleReexport{
+				{OriginalPackage: "str-sig", OriginalName: "Str", NewName: "Str.Sig"},
+			},
+			Signatures: []ModuleName{"Str"},
+		},
+	}
+
+	expected := `cabal-version: 3.0
+name:          backpack-example
+version:       1.0
+x-revision:    2
+license:       MIT
+
+library
+  exposed-modules:    Regex
+  reexported-modules: str-sig:Str as Str.Sig
+  signatures:         Str
+  hs-source-dirs:     src
+                      "my sources"
+  build-depends:      base >= 4.14 && < 5
+                    , str-sig
+  mixins:             str-bytestring (Str as Str.ByteString) requires hiding (Str.Internal)
+  default-language:   GHC2021
+`
+
+	var buf bytes.Buffer
+
+	if err := NewPrinter().Print(&buf, p); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+}
+
+func TestPrinter_Print_roundTrip(t *testing.T) {
+	for _, name := range []string{"1.cabal", "2.cabal", "3.cabal", "4.cabal", "5.cabal", "8.cabal", "9.cabal"} {
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(fmt.Sprintf("./testdata/%s", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer f.Close()
+
+			expected, err := NewParser().ParseReader(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+
+			if err := NewPrinter().Print(&buf, expected); err != nil {
+				t.Fatal(err)
+			}
+
+			actual, err := NewParser().ParseReader(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(expected, actual) {
+				t.Fatalf("printed package differs from the original")
+			}
+		})
+	}
+}
+
+func TestPrinter_Print_conditionalBranches(t *testing.T) {
+	input := `name: acme
+version: 1.0
+
+library
+  build-depends: base
+  if os(windows)
+    exposed-modules: System.Acme.Windows
+  else
+    reexported-modules: unix:System.Posix as System.Acme.Posix
+    signatures: Acme.Sig
+
+executable acme
+  if flag(dev)
+    main-is: Dev.hs
+  else
+    main-is: Main.hs
+`
+
+	expected := `name:    acme
+version: 1.0
+
+library
+  build-depends: base
+  if os(windows)
+    exposed-modules: System.Acme.Windows
+  else
+    reexported-modules: unix:System.Posix as System.Acme.Posix
+    signatures:         Acme.Sig
+
+executable acme
+  if flag(dev)
+    main-is: Dev.hs
+  else
+    main-is: Main.hs
+`
+
+	p, err := NewParser().ParseReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := NewPrinter().Print(&buf, p); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != expected {
+		t.Fatalf("expected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+
+	actual, err := NewParser().ParseReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(p, actual) {
+		t.Fatalf("printed package differs from the original")
+	}
+}
@@ -0,0 +1,389 @@
+package gocabalparser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Project is a cabal.project file. Settings holds the other top-level
+// fields, such as with-compiler or tests, by lowercased name with value
+// lines joined by spaces; PackageSettings holds the fields of package
+// stanzas the same way, under "*" for package *. Sections holds the
+// top-level sections the parser doesn't model, such as repository,
+// program-options or if blocks, as they were read.
+type Project struct {
+	Packages                 []string
+	OptionalPackages         []string
+	ExtraPackages            []string
+	Constraints              []string
+	AllowNewer               []string
+	AllowOlder               []string
+	Imports                  []string
+	Settings                 map[string]string
+	PackageSettings          map[string]map[string]string
+	SourceRepositoryPackages []*SourceRepositoryPackage
+	Sections                 []*SyntaxNode
+}
+
+// SourceRepositoryPackage is a source-repository-package stanza, a
+// package built from a version control checkout. Settings holds the
+// other fields by lowercased name, e.g. module for CVS repositories.
+type SourceRepositoryPackage struct {
+	Type                string
+	Location            string
+	Tag                 string
+	Branch              string
+	Subdirs             []string
+	PostCheckoutCommand string
+	Settings            map[string]string
+}
+
+type ProjectParser interface {
+	ParseReader(r io.Reader) (*Project, error)
+}
+
+type projectParser struct{}
+
+func NewProjectParser() ProjectParser {
+	return &projectParser{}
+}
+
+// ParseReader parses a cabal.project or cabal.project.local file. Imports
+// are recorded but not followed. Sections nested in package stanzas, such
+// as if blocks, are skipped.
+func (p *projectParser) ParseReader(r io.Reader) (*Project, error) {
+	tree, err := ParseSyntaxTree(r)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*SyntaxNode, 0, len(tree.Nodes))
+	sections := make([]*SyntaxNode, 0)
+
+	for _, n := range tree.Nodes {
+		if n.Kind == SyntaxSection && !isProjectSection(n.Name) {
+			sections = append(sections, n)
+
+			continue
+		}
+
+		nodes = append(nodes, n)
+	}
+
+	closed := func(string) bool { return true }
+
+	res, err := parseProjectTokens(syntaxTokens(nodes, closed))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sections) > 0 {
+		res.Sections = sections
+	}
+
+	return res, nil
+}
+
+func isProjectSection(name string) bool {
+	return strings.EqualFold(name, "package") || strings.EqualFold(name, "source-repository-package")
+}
+
+func parseProjectTokens(tokens tokens) (*Project, error) {
+	iterator := newTokensIterator(tokens)
+	res := &Project{}
+
+	for iterator.Next() {
+		token := iterator.Val()
+
+		if token.Type != tokenTypeKey {
+			return nil, fmt.Errorf("name declaration expected, but got: %s", token.Value)
+		}
+
+		if next, ok := iterator.Seek(); ok && next.Type == tokenTypeScopeName {
+			if err := parseProjectSection(res, token.Value, iterator); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		values := projectValues(iterator)
+
+		var err error
+
+		switch name := strings.ToLower(token.Value); name {
+		case "packages":
+			err = appendSplit(&res.Packages, splitOptCommaList, values)
+		case "optional-packages":
+			err = appendSplit(&res.OptionalPackages, splitOptCommaList, values)
+		case "extra-packages":
+			err = appendSplit(&res.ExtraPackages, splitCommaList, values)
+		case "constraints":
+			err = appendSplit(&res.Constraints, splitCommaList, values)
+		case "allow-newer":
+			err = appendSplit(&res.AllowNewer, splitCommaList, values)
+		case "allow-older":
+			err = appendSplit(&res.AllowOlder, splitCommaList, values)
+		case "import":
+			if len(values) == 0 {
+				return nil, errors.New("import: file expected")
+			}
+
+			res.Imports = append(res.Imports, strings.Join(values, " "))
+		default:
+			if res.Settings == nil {
+				res.Settings = make(map[string]string)
+			}
+
+			res.Settings[name] = strings.Join(values, " ")
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", token.Value, err)
+		}
+	}
+
+	return res, nil
+}
+
+func parseProjectSection(to *Project, name string, iterator *tokensIterator) error {
+	iterator.Next()
+	args := strings.TrimSpace(iterator.Val().Value)
+
+	fields, err := projectSectionFields(name, iterator)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(name) {
+	case "package":
+		if args == "" {
+			return errors.New("package name expected")
+		}
+
+		if to.PackageSettings == nil {
+			to.PackageSettings = make(map[string]map[string]string)
+		}
+
+		settings := to.PackageSettings[args]
+		if settings == nil {
+			settings = make(map[string]string)
+			to.PackageSettings[args] = settings
+		}
+
+		for i := 0; i < len(fields); i += 2 {
+			settings[fields[i]] = fields[i+1]
+		}
+
+		return nil
+	case "source-repository-package":
+		return parseSourceRepositoryPackage(to, fields)
+	default:
+		return fmt.Errorf("unsupported project section: %s", name)
+	}
+}
+
+// projectSectionFields reads the fields of a section up to its end as
+// pairs of lowercased names and values joined by spaces.
+func projectSectionFields(section string, iterator *tokensIterator) ([]string, error) {
+	res := make([]string, 0)
+
+	for iterator.Next() {
+		token := iterator.Val()
+
+		switch {
+		case token.Type == tokenTypeScopeEnd:
+			return res, nil
+		case token.Type != tokenTypeKey:
+			return nil, fmt.Errorf("%s: name declaration expected, but got: %s", section, token.Value)
+		}
+
+		if next, ok := iterator.Seek(); ok && next.Type == tokenTypeScopeName {
+			if !skipProjectSection(iterator) {
+				return nil, fmt.Errorf("%s: end of section %s expected", section, token.Value)
+			}
+
+			continue
+		}
+
+		res = append(res, strings.ToLower(token.Value), strings.Join(projectValues(iterator), " "))
+	}
+
+	return nil, fmt.Errorf("%s: end of section expected", section)
+}
+
+// skipProjectSection consumes a nested section up to its end, reporting
+// whether the end was found.
+func skipProjectSection(iterator *tokensIterator) bool {
+	depth := 0
+
+	for iterator.Next() {
+		switch iterator.Val().Type {
+		case tokenTypeScopeName:
+			depth++
+		case tokenTypeScopeEnd:
+			depth--
+
+			if depth == 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// projectValues consumes the value lines of a field, which may be empty.
+func projectValues(iterator *tokensIterator) []string {
+	res := make([]string, 0)
+
+	for {
+		token, ok := iterator.Seek()
+		if !ok || token.Type != tokenTypeValue {
+			return res
+		}
+
+		res = append(res, token.Value)
+		iterator.Next()
+	}
+}
+
+func appendSplit(to *[]string, split listSplitter, lines []string) error {
+	values, err := split(strings.Join(lines, "\n"))
+	if err != nil {
+		return err
+	}
+
+	*to = append(*to, values...)
+
+	return nil
+}
+
+func parseSourceRepositoryPackage(to *Project, fields []string) error {
+	repo := &SourceRepositoryPackage{}
+
+	for i := 0; i < len(fields); i += 2 {
+		value := fields[i+1]
+
+		switch fields[i] {
+		case "type":
+			repo.Type = value
+		case "location":
+			repo.Location = value
+		case "tag":
+			repo.Tag = value
+		case "branch":
+			repo.Branch = value
+		case "subdir":
+			if err := appendSplit(&repo.Subdirs, splitOptCommaList, []string{value}); err != nil {
+				return err
+			}
+		case "post-checkout-command":
+			repo.PostCheckoutCommand = value
+		default:
+			if repo.Settings == nil {
+				repo.Settings = make(map[string]string)
+			}
+
+			repo.Settings[fields[i]] = value
+		}
+	}
+
+	if repo.Location == "" {
+		return errors.New("source-repository-package: location expected")
+	}
+
+	to.SourceRepositoryPackages = append(to.SourceRepositoryPackages, repo)
+
+	return nil
+}
+
+// PackageFiles resolves the packages and optional-packages entries to the
+// sorted paths of local .cabal files in fsys, which must be rooted at the
+// project directory. Entries are directories containing a single .cabal
+// file, .cabal files or globs matching either; remote and tarball entries
+// are skipped. An entry of packages matching nothing is an error.
+func (p *Project) PackageFiles(fsys fs.FS) ([]string, error) {
+	res := make([]string, 0)
+
+	for _, entries := range []struct {
+		values   []string
+		optional bool
+	}{
+		{p.Packages, false},
+		{p.OptionalPackages, true},
+	} {
+		for _, entry := range entries.values {
+			files, err := projectPackageFiles(fsys, entry)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(files) == 0 && !entries.optional && !isRemoteProjectPackage(entry) {
+				return nil, fmt.Errorf("package %s matches no .cabal file", entry)
+			}
+
+			for _, f := range files {
+				res = appendUnique(res, f)
+			}
+		}
+	}
+
+	sort.Strings(res)
+
+	return res, nil
+}
+
+func projectPackageFiles(fsys fs.FS, entry string) ([]string, error) {
+	if isRemoteProjectPackage(entry) {
+		return nil, nil
+	}
+
+	pattern := path.Clean(strings.TrimPrefix(entry, "./"))
+
+	matches, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("package %s: %v", entry, err)
+	}
+
+	res := make([]string, 0, len(matches))
+
+	for _, m := range matches {
+		info, err := fs.Stat(fsys, m)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			if path.Ext(m) == ".cabal" {
+				res = append(res, m)
+			}
+
+			continue
+		}
+
+		files, err := fs.Glob(fsys, path.Join(m, "*.cabal"))
+		if err != nil {
+			return nil, err
+		}
+
+		switch len(files) {
+		case 0:
+		case 1:
+			res = append(res, files[0])
+		default:
+			return nil, fmt.Errorf("package %s: multiple .cabal files in %s", entry, m)
+		}
+	}
+
+	return res, nil
+}
+
+func isRemoteProjectPackage(entry string) bool {
+	return strings.Contains(entry, "://") || strings.HasSuffix(entry, ".tar.gz")
+}
@@ -0,0 +1,173 @@
+package gocabalparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestProjectParser_ParseReader(t *testing.T) {
+	input := `-- the monorepo
+import: cabal.project.common
+packages: ./
+          services/*/
+          libs/core/core.cabal
+optional-packages: vendor/*
+extra-packages: lens
+constraints: aeson >= 2,
+             any.text installed
+allow-newer: base, ghc-prim
+with-compiler: ghc-9.4.8
+tests: True
+
+package core
+  ghc-options: -Wall
+               -Werror
+  flags: +dev
+  if os(windows)
+    ghc-options: -O0
+
+package *
+  optimization: 2
+
+source-repository-package
+  type: git
+  location: https://github.com/example/fork
+  tag: 0123abc
+  subdir: one two
+
+source-repository-package
+  type: cvs
+  location: :pserver:anonymous@cvs.example.org:/cvs
+  module: legacy
+
+repository my-hackage
+  url: https://hackage.example.org/
+
+program-options
+  ghc-options: -haddock
+
+if impl(ghc >= 9.6)
+  allow-newer: text
+else
+  constraints: text < 2.1
+`
+
+	expected := &Project{
+		Packages:         []string{"./", "services/*/", "libs/core/core.cabal"},
+		OptionalPackages: []string{"vendor/*"},
+		ExtraPackages:    []string{"lens"},
+		Constraints:      []string{"aeson >= 2", "any.text installed"},
+		AllowNewer:       []string{"base", "ghc-prim"},
+		Imports:          []string{"cabal.project.common"},
+		Settings: map[string]string{
+			"with-compiler": "ghc-9.4.8",
+			"tests":         "True",
+		},
+		PackageSettings: map[string]map[string]string{
+			"core": {"ghc-options": "-Wall -Werror", "flags": "+dev"},
+			"*":    {"optimization": "2"},
+		},
+		SourceRepositoryPackages: []*SourceRepositoryPackage{
+			{
+				Type:     "git",
+				Location: "https://github.com/example/fork",
+				Tag:      "0123abc",
+				Subdirs:  []string{"one", "two"},
+			},
+			{
+				Type:     "cvs",
+				Location: ":pserver:anonymous@cvs.example.org:/cvs",
+				Settings: map[string]string{"module": "legacy"},
+			},
+		},
+	}
+
+	actual, err := NewProjectParser().ParseReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sections := make([]string, 0)
+	for _, n := range actual.Sections {
+		sections = append(sections, strings.TrimSpace(n.Name+" "+n.Args))
+	}
+
+	if expected := []string{"repository my-hackage", "program-options", "if impl(ghc >= 9.6)", "else"}; !reflect.DeepEqual(sections, expected) {
+		t.Fatalf("expected sections %v, got %v", expected, sections)
+	}
+
+	actual.Sections = nil
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestProjectParser_ParseReader_errors(t *testing.T) {
+	tcs := map[string]string{
+		"unnamed package":     "package\n  ghc-options: -Wall\n",
+		"repository location": "source-repository-package\n  type: git\n",
+		"empty import":        "import:\n",
+	}
+
+	for name, input := range tcs {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewProjectParser().ParseReader(strings.NewReader(input)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestProject_PackageFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"root.cabal":                    {},
+		"services/api/api.cabal":        {},
+		"services/worker/worker.cabal":  {},
+		"services/README.md":            {},
+		"libs/core/core.cabal":          {},
+		"vendor/patched/patched.cabal":  {},
+		"vendor/notes/notes.txt":        {},
+		"broken/a.cabal":                {},
+		"broken/b.cabal":                {},
+		"services/empty/src/Empty.hs":   {},
+		"services/worker/src/Worker.hs": {},
+	}
+
+	p := &Project{
+		Packages:         []string{"./", "services/*/", "libs/core/core.cabal", "https://example.org/x-1.0.tar.gz"},
+		OptionalPackages: []string{"vendor/*", "missing"},
+	}
+
+	expected := []string{
+		"libs/core/core.cabal",
+		"root.cabal",
+		"services/api/api.cabal",
+		"services/worker/worker.cabal",
+		"vendor/patched/patched.cabal",
+	}
+
+	actual, err := p.PackageFiles(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %q, got %q", expected, actual)
+	}
+
+	for name, packages := range map[string][]string{
+		"no match":       {"missing"},
+		"two cabal":      {"broken"},
+		"invalid glob":   {"services/["},
+		"no cabal files": {"vendor/notes"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := (&Project{Packages: packages}).PackageFiles(fsys); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
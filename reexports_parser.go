@@ -0,0 +1,55 @@
+package gocabalparser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type reexportsParser struct{}
+
+func newReexportsParser() *reexportsParser {
+	return &reexportsParser{}
+}
+
+// ParseString parses a single reexported-modules entry of the form
+// [package:]Original.Module [as New.Name].
+func (p *reexportsParser) ParseString(s string) (*ModuleReexport, error) {
+	chunks := strings.Fields(s)
+
+	if len(chunks) == 0 {
+		return nil, errors.New("empty reexported module")
+	}
+
+	if len(chunks) != 1 && (len(chunks) != 3 || chunks[1] != "as") {
+		return nil, fmt.Errorf("invalid reexported module: %s", s)
+	}
+
+	res := &ModuleReexport{}
+	original := chunks[0]
+
+	if i := strings.LastIndex(original, ":"); i >= 0 {
+		res.OriginalPackage = original[:i]
+		original = original[i+1:]
+
+		if !isPackageNameWord(res.OriginalPackage) {
+			return nil, fmt.Errorf("invalid package name in reexported module: %s", s)
+		}
+	}
+
+	var err error
+
+	if res.OriginalName, err = ParseModuleName(original); err != nil {
+		return nil, err
+	}
+
+	res.NewName = res.OriginalName
+
+	if len(chunks) == 3 {
+		if res.NewName, err = ParseModuleName(chunks[2]); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,80 @@
+package gocabalparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReexportsParser_ParseString(t *testing.T) {
+	cases := []struct {
+		name           string
+		reexportString string
+		expected       *ModuleReexport
+	}{
+		{
+			name:           "module only",
+			reexportString: "Data.Map",
+			expected: &ModuleReexport{
+				OriginalName: "Data.Map",
+				NewName:      "Data.Map",
+			},
+		},
+		{
+			name:           "package qualified",
+			reexportString: "containers:Data.Map",
+			expected: &ModuleReexport{
+				OriginalPackage: "containers",
+				OriginalName:    "Data.Map",
+				NewName:         "Data.Map",
+			},
+		},
+		{
+			name:           "renamed",
+			reexportString: "containers:Data.Map as Map",
+			expected: &ModuleReexport{
+				OriginalPackage: "containers",
+				OriginalName:    "Data.Map",
+				NewName:         "Map",
+			},
+		},
+		{
+			name:           "renamed without package",
+			reexportString: "Data.Set   as   Set",
+			expected: &ModuleReexport{
+				OriginalName: "Data.Set",
+				NewName:      "Set",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := newReexportsParser().ParseString(tc.reexportString)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Fatalf("expected %+v, got %+v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestReexportsParser_ParseString_errors(t *testing.T) {
+	cases := []string{
+		"",
+		"Data.Map as",
+		"Data.Map to Map",
+		"containers:data.Map",
+		":Data.Map",
+	}
+
+	for _, tc := range cases {
+		t.Run(tc, func(t *testing.T) {
+			if _, err := newReexportsParser().ParseString(tc); err == nil {
+				t.Fatalf("expected error for '%s'", tc)
+			}
+		})
+	}
+}
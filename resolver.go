@@ -0,0 +1,549 @@
+package gocabalparser
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PackageSource provides the available versions of packages.
+type PackageSource interface {
+	// Candidates returns the versions of a package in order of preference,
+	// or nil if there are none.
+	Candidates(name string) []*PackageCandidate
+}
+
+// PackageCandidate is an available version of a package. Err is set and
+// Package is nil when the cabal file of the version can't be parsed; the
+// resolver reports such versions instead of choosing them.
+type PackageCandidate struct {
+	Version Version
+	Package *CabalPackage
+	Err     error
+}
+
+// PackageMap is an in-memory PackageSource. Newer versions are preferred;
+// packages whose version fails to parse are left out.
+type PackageMap map[string][]*CabalPackage
+
+func (m PackageMap) Candidates(name string) []*PackageCandidate {
+	res := make([]*PackageCandidate, 0, len(m[name]))
+
+	for _, p := range m[name] {
+		if v, err := ParseVersion(p.Version); err == nil {
+			res = append(res, &PackageCandidate{Version: v, Package: p})
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Version.Compare(res[j].Version) > 0
+	})
+
+	return res
+}
+
+// Candidates returns the latest revision of each release of a package.
+// Preferred versions come first, newest first, followed by the deprecated
+// ones. Releases whose latest revision failed to parse carry its error.
+func (idx *Index) Candidates(name string) []*PackageCandidate {
+	preferred := idx.PreferredVersions(name)
+	releases := idx.Releases(name)
+
+	res := make([]*PackageCandidate, 0, len(releases))
+	deprecated := make([]*PackageCandidate, 0)
+
+	for i := len(releases) - 1; i >= 0; i-- {
+		r := releases[i]
+
+		latest := r.Latest()
+		c := &PackageCandidate{Version: r.Version, Package: latest.Cabal, Err: latest.Err}
+
+		if preferred == nil || preferred.Contains(r.Version) {
+			res = append(res, c)
+		} else {
+			deprecated = append(deprecated, c)
+		}
+	}
+
+	return append(res, deprecated...)
+}
+
+// ResolverOptions configures a Resolver.
+type ResolverOptions struct {
+	// Platform resolves conditionals. Its Flags apply to the root package;
+	// flags of other packages take their defaults unless Constraints set
+	// them.
+	Platform Platform
+	// Constraints restrict versions and set flags like the constraints of
+	// cabal.project. Constraints qualified for setup dependencies or build
+	// tools don't apply, nor do installed, source and stanza constraints.
+	Constraints []*PackageConstraint
+	// Installed holds packages, such as base, which are used at the given
+	// version without consulting the source.
+	Installed map[string]Version
+	// MaxSteps bounds the number of versions tried, 100000 when zero.
+	MaxSteps int
+}
+
+const defaultResolverMaxSteps = 100000
+
+// PlannedPackage is a package version chosen by a Resolver. Package is nil
+// for installed packages.
+type PlannedPackage struct {
+	Name    string
+	Version Version
+	Package *CabalPackage
+}
+
+func (p *PlannedPackage) String() string {
+	return p.Name + "-" + p.Version.String()
+}
+
+// ResolveError explains why no version of Package could be chosen.
+// Reasons tells, in order of preference, why each version was rejected.
+// Cause is the failure further down the search below the most preferred
+// version, if any.
+type ResolveError struct {
+	Package string
+	Reasons []string
+	Cause   *ResolveError
+
+	// conflicts holds the packages whose choices led to the failure.
+	conflicts map[string]bool
+}
+
+func (e *ResolveError) Error() string {
+	var b strings.Builder
+
+	e.write(&b, "")
+
+	return b.String()
+}
+
+func (e *ResolveError) write(b *strings.Builder, indent string) {
+	fmt.Fprintf(b, "%scannot choose a version of %s:", indent, e.Package)
+
+	for _, r := range e.Reasons {
+		fmt.Fprintf(b, "\n%s  %s", indent, r)
+	}
+
+	if e.Cause != nil {
+		b.WriteString("\n")
+		e.Cause.write(b, indent+"  ")
+	}
+}
+
+type Resolver interface {
+	// Resolve returns an install plan satisfying the build-depends of all
+	// components of root, sorted by package name. The root package itself
+	// is not part of the plan. Conflicts are reported as *ResolveError.
+	Resolve(root *CabalPackage) ([]*PlannedPackage, error)
+}
+
+type resolver struct {
+	source   PackageSource
+	opts     ResolverOptions
+	rootName string
+	steps    int
+}
+
+// NewResolver returns a dependency resolver over the package versions of
+// source. It searches versions in order of preference and backtracks on
+// conflicts; only the library dependencies of the chosen packages are
+// followed.
+func NewResolver(source PackageSource, opts ResolverOptions) Resolver {
+	return &resolver{source: source, opts: opts}
+}
+
+// resolveRequirement is a version range imposed on a package by the
+// package by, or by the constraints when by is nil.
+type resolveRequirement struct {
+	versions *VersionRange
+	by       *PlannedPackage
+}
+
+func (r *resolveRequirement) String() string {
+	by := "constraints"
+	if r.by != nil {
+		by = r.by.String()
+	}
+
+	return fmt.Sprintf("%s (%s)", r.versions, by)
+}
+
+// resolveState is a partial plan. It is copied before each choice so
+// backtracking only has to drop the copy.
+type resolveState struct {
+	order    []string
+	required map[string][]*resolveRequirement
+	chosen   map[string]*PlannedPackage
+}
+
+func (s *resolveState) clone() *resolveState {
+	res := &resolveState{
+		order:    append([]string{}, s.order...),
+		required: make(map[string][]*resolveRequirement, len(s.required)),
+		chosen:   make(map[string]*PlannedPackage, len(s.chosen)),
+	}
+
+	for name, r := range s.required {
+		res.required[name] = r
+	}
+
+	for name, p := range s.chosen {
+		res.chosen[name] = p
+	}
+
+	return res
+}
+
+// next returns the first required package without a version.
+func (s *resolveState) next() (string, bool) {
+	for _, name := range s.order {
+		if _, ok := s.chosen[name]; !ok {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// excluding returns the first requirement of a package the version is out
+// of, or nil.
+func (s *resolveState) excluding(name string, v Version) *resolveRequirement {
+	for _, r := range s.required[name] {
+		if !r.versions.Contains(v) {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// require adds the dependencies of a chosen package. It returns the
+// first dependency excluding a version already chosen, along with that
+// version.
+func (s *resolveState) require(by *PlannedPackage, deps []*Dependency) (*Dependency, *PlannedPackage) {
+	for _, d := range deps {
+		r := &resolveRequirement{versions: d.VersionRange(), by: by}
+
+		if p, ok := s.chosen[d.Name]; ok && !r.versions.Contains(p.Version) {
+			return d, p
+		}
+
+		if !containsString(s.order, d.Name) {
+			s.order = append(s.order, d.Name)
+		}
+
+		s.required[d.Name] = append(append([]*resolveRequirement{}, s.required[d.Name]...), r)
+	}
+
+	return nil, nil
+}
+
+func (r *resolver) Resolve(root *CabalPackage) ([]*PlannedPackage, error) {
+	version, err := ParseVersion(root.Version)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", root.Name, err)
+	}
+
+	r.steps = 0
+	r.rootName = root.Name
+
+	s := &resolveState{
+		required: make(map[string][]*resolveRequirement),
+		chosen:   make(map[string]*PlannedPackage),
+	}
+
+	for _, c := range r.opts.Constraints {
+		if c.Kind != PackageConstraintVersion || c.Scope.Kind > ConstraintScopeAny || c.Package == root.Name {
+			continue
+		}
+
+		s.required[c.Package] = append(s.required[c.Package], &resolveRequirement{versions: c.Range})
+	}
+
+	planned := &PlannedPackage{Name: root.Name, Version: version, Package: root}
+	s.chosen[root.Name] = planned
+
+	infos := root.buildInfos()
+	if len(infos) == 0 {
+		return nil, fmt.Errorf("%s: no library or executables", root.Name)
+	}
+
+	deps, err := r.dependencies(root, infos)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", root.Name, err)
+	}
+
+	s.require(planned, deps)
+
+	res, err := r.solve(s)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := make([]*PlannedPackage, 0, len(res.chosen)-1)
+
+	for _, name := range sortedKeys(res.chosen) {
+		if name != root.Name {
+			plan = append(plan, res.chosen[name])
+		}
+	}
+
+	return plan, nil
+}
+
+// solve chooses a version for the next required package and recurses,
+// trying the following versions when the rest of the search fails. When
+// the failure doesn't involve the package, other versions can't help and
+// it is returned as is.
+func (r *resolver) solve(s *resolveState) (*resolveState, error) {
+	name, ok := s.next()
+	if !ok {
+		return s, nil
+	}
+
+	maxSteps := r.opts.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = defaultResolverMaxSteps
+	}
+
+	res := &ResolveError{Package: name, conflicts: make(map[string]bool)}
+
+	for _, req := range s.required[name] {
+		if req.by != nil {
+			res.conflicts[req.by.Name] = true
+		}
+	}
+
+	candidates := r.candidates(name)
+	if len(candidates) == 0 {
+		res.Reasons = append(res.Reasons, "no versions available")
+
+		return nil, res
+	}
+
+	// versions out of range are listed together for each requirement
+	var (
+		excluded     []*resolveRequirement
+		excludedBy   = make(map[*resolveRequirement][]string)
+		otherReasons []string
+	)
+
+	for _, c := range candidates {
+		p := c.PlannedPackage
+
+		if req := s.excluding(name, p.Version); req != nil {
+			if _, ok := excludedBy[req]; !ok {
+				excluded = append(excluded, req)
+			}
+
+			excludedBy[req] = append(excludedBy[req], p.Version.String())
+
+			continue
+		}
+
+		if c.err != nil {
+			otherReasons = append(otherReasons, fmt.Sprintf("%s: invalid cabal file: %v", p, c.err))
+
+			continue
+		}
+
+		if r.steps++; r.steps > maxSteps {
+			return nil, fmt.Errorf("search limit of %d steps reached", maxSteps)
+		}
+
+		deps, err := r.libraryDependencies(p)
+		if err != nil {
+			otherReasons = append(otherReasons, fmt.Sprintf("%s: %v", p, err))
+
+			continue
+		}
+
+		next := s.clone()
+		next.chosen[name] = p
+
+		if d, chosen := next.require(p, deps); d != nil {
+			otherReasons = append(otherReasons, fmt.Sprintf("%s: requires %s %s, but %s is chosen", p, d.Name, d.Range, chosen))
+			res.conflicts[chosen.Name] = true
+
+			continue
+		}
+
+		solved, err := r.solve(next)
+		if err == nil {
+			return solved, nil
+		}
+
+		var cause *ResolveError
+		if !errors.As(err, &cause) {
+			return nil, err
+		}
+
+		if !cause.conflicts[name] {
+			return nil, cause
+		}
+
+		for conflict := range cause.conflicts {
+			if conflict != name {
+				res.conflicts[conflict] = true
+			}
+		}
+
+		otherReasons = append(otherReasons, fmt.Sprintf("%s: then no version of %s can be chosen", p, cause.Package))
+
+		if res.Cause == nil {
+			res.Cause = cause
+		}
+	}
+
+	for _, req := range excluded {
+		res.Reasons = append(res.Reasons, fmt.Sprintf("%s excluded by %s", strings.Join(excludedBy[req], ", "), req))
+	}
+
+	res.Reasons = append(res.Reasons, otherReasons...)
+
+	return nil, res
+}
+
+// resolveCandidate is a version to try; err is set when its cabal file
+// can't be parsed.
+type resolveCandidate struct {
+	*PlannedPackage
+	err error
+}
+
+// candidates returns the versions of a package in order of preference,
+// only the installed one if there is one.
+func (r *resolver) candidates(name string) []resolveCandidate {
+	if v, ok := r.opts.Installed[name]; ok {
+		return []resolveCandidate{{PlannedPackage: &PlannedPackage{Name: name, Version: v}}}
+	}
+
+	res := make([]resolveCandidate, 0)
+
+	for _, c := range r.source.Candidates(name) {
+		if c.Package != nil && c.Package.Name != name {
+			continue
+		}
+
+		err := c.Err
+		if err == nil && c.Package == nil {
+			err = errors.New("cabal file expected")
+		}
+
+		res = append(res, resolveCandidate{PlannedPackage: &PlannedPackage{Name: name, Version: c.Version, Package: c.Package}, err: err})
+	}
+
+	return res
+}
+
+// libraryDependencies returns the dependencies of the library and
+// sub-libraries of a planned package.
+func (r *resolver) libraryDependencies(p *PlannedPackage) ([]*Dependency, error) {
+	if p.Package == nil {
+		return nil, nil
+	}
+
+	if p.Package.Library == nil {
+		return nil, errors.New("no library")
+	}
+
+	infos := make([]namedBuildInfo, 0)
+
+	for _, bi := range p.Package.buildInfos() {
+		if strings.HasPrefix(bi.name, "library") {
+			infos = append(infos, bi)
+		}
+	}
+
+	return r.dependencies(p.Package, infos)
+}
+
+// dependencies returns the build-depends of the components with
+// conditionals resolved for the platform, named by package and sorted.
+// Dependencies on the package itself and its sub-libraries are left out.
+func (r *resolver) dependencies(p *CabalPackage, infos []namedBuildInfo) ([]*Dependency, error) {
+	platform := r.platform(p)
+	res := make([]*Dependency, 0)
+
+	var add func(component string, bi *BuildInfo) error
+	add = func(component string, bi *BuildInfo) error {
+		for _, d := range bi.BuildDepends {
+			name, _, _ := strings.Cut(d.Name, ":")
+			if _, ok := p.SubLibraries[name]; ok || name == p.Name {
+				continue
+			}
+
+			res = append(res, &Dependency{Name: name, Range: d.VersionRange()})
+		}
+
+		for _, c := range bi.Conditionals {
+			ok, err := platform.EvalCondition(c.Condition)
+			if err != nil {
+				return fmt.Errorf("%s: %v", component, err)
+			}
+
+			var branch *BuildInfo
+
+			switch {
+			case ok:
+				branch = &c.Then.BuildInfo
+			case c.Else != nil:
+				branch = &c.Else.BuildInfo
+			}
+
+			if branch != nil {
+				if err := add(component, branch); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	for _, bi := range infos {
+		if err := add(bi.name, bi.info); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+
+	return res, nil
+}
+
+// platform returns the platform with the flags of a package: its
+// defaults, overridden by the platform flags for the root package and by
+// flag constraints.
+func (r *resolver) platform(p *CabalPackage) *Platform {
+	res := r.opts.Platform
+	res.Flags = make(map[string]bool)
+
+	for name, flag := range p.Flags {
+		res.Flags[name] = flag.Default
+	}
+
+	if p.Name == r.rootName {
+		for name, value := range r.opts.Platform.Flags {
+			res.Flags[name] = value
+		}
+	}
+
+	for _, c := range r.opts.Constraints {
+		if c.Kind != PackageConstraintFlags || c.Scope.Kind > ConstraintScopeAny || c.Package != p.Name {
+			continue
+		}
+
+		for _, f := range c.Flags {
+			res.Flags[f.Name] = f.Value
+		}
+	}
+
+	return &res
+}
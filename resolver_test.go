@@ -0,0 +1,239 @@
+package gocabalparser
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func resolverTestPackage(t *testing.T, name, version, library string) *CabalPackage {
+	t.Helper()
+
+	p, err := NewParser().ParseReader(strings.NewReader("name: " + name + "\nversion: " + version + "\nlibrary\n" + library))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func resolverTestSource(t *testing.T) PackageMap {
+	return PackageMap{
+		"text": {
+			resolverTestPackage(t, "text", "1.2.5.0", "  build-depends: base\n"),
+			resolverTestPackage(t, "text", "2.1", "  build-depends: base >= 4.10\n"),
+			resolverTestPackage(t, "text", "2.0.2", "  build-depends: base >= 4.10\n"),
+		},
+		"aeson": {
+			resolverTestPackage(t, "aeson", "2.1.2.1", "  build-depends: base, text >= 1.2 && < 2.1\n"),
+			resolverTestPackage(t, "aeson", "2.2.1.0", "  build-depends: base, text >= 2.1, containers\n"),
+			resolverTestPackage(t, "aeson", "2.2.3.0", "  build-depends: base, text >= 2.1\n"+
+				"  if flag(ordered-keymap)\n    build-depends: containers >= 0.7\n"+
+				"flag ordered-keymap\n  default: True\n"),
+		},
+		"containers": {
+			resolverTestPackage(t, "containers", "0.6.7", "  build-depends: base\n"),
+		},
+	}
+}
+
+func planStrings(plan []*PlannedPackage) []string {
+	res := make([]string, 0, len(plan))
+
+	for _, p := range plan {
+		res = append(res, p.String())
+	}
+
+	return res
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	installed := map[string]Version{"base": {4, 18, 2, 1}}
+
+	testCases := []struct {
+		name     string
+		root     string
+		opts     ResolverOptions
+		expected []string
+	}{
+		{
+			name:     "newest versions",
+			root:     "  build-depends: base, text\n",
+			expected: []string{"base-4.18.2.1", "text-2.1"},
+		},
+		{
+			name: "backtracking",
+			root: "  build-depends: base, aeson, text < 2.1\n",
+			// aeson-2.2.3.0 needs containers >= 0.7, aeson-2.2.1.0 needs
+			// text >= 2.1
+			expected: []string{"aeson-2.1.2.1", "base-4.18.2.1", "text-2.0.2"},
+		},
+		{
+			name: "flag constraint",
+			root: "  build-depends: base, aeson\n",
+			opts: ResolverOptions{Constraints: []*PackageConstraint{
+				{Scope: ConstraintScope{Kind: ConstraintScopeAny}, Package: "aeson", Kind: PackageConstraintFlags,
+					Flags: []FlagAssignment{{Name: "ordered-keymap", Value: false}}},
+			}},
+			expected: []string{"aeson-2.2.3.0", "base-4.18.2.1", "text-2.1"},
+		},
+		{
+			name: "version constraint",
+			root: "  build-depends: base, text\n",
+			opts: ResolverOptions{Constraints: []*PackageConstraint{
+				{Scope: ConstraintScope{Kind: ConstraintScopeTopLevel}, Package: "text", Kind: PackageConstraintVersion,
+					Range: &VersionRange{Kind: VersionRangeEarlier, Version: Version{2}}},
+				{Scope: ConstraintScope{Kind: ConstraintScopeAnySetup}, Package: "text", Kind: PackageConstraintVersion,
+					Range: &VersionRange{Kind: VersionRangeNone}},
+			}},
+			expected: []string{"base-4.18.2.1", "text-1.2.5.0"},
+		},
+		{
+			name:     "root conditionals",
+			root:     "  build-depends: base\n  if flag(json)\n    build-depends: aeson < 2.2\nflag json\n  default: False\n",
+			opts:     ResolverOptions{Platform: Platform{Flags: map[string]bool{"json": true}}},
+			expected: []string{"aeson-2.1.2.1", "base-4.18.2.1", "text-2.0.2"},
+		},
+		{
+			name:     "executables and sub-libraries",
+			root:     "  build-depends: base\nlibrary internal\n  build-depends: base\nexecutable acme\n  build-depends: acme, internal, containers\n",
+			expected: []string{"base-4.18.2.1", "containers-0.6.7"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Installed = installed
+
+			root := resolverTestPackage(t, "acme", "1.0", tc.root)
+
+			plan, err := NewResolver(resolverTestSource(t), tc.opts).Resolve(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual := planStrings(plan); !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestResolver_Resolve_conflicts(t *testing.T) {
+	testCases := []struct {
+		name     string
+		root     string
+		expected string
+	}{
+		{
+			name: "unsatisfiable range",
+			root: "  build-depends: base, text >= 3\n",
+			expected: "cannot choose a version of text:\n" +
+				"  2.1, 2.0.2, 1.2.5.0 excluded by >= 3 (acme-1.0)",
+		},
+		{
+			name: "conflict below",
+			root: "  build-depends: base, aeson < 2.2, text >= 2.1\n",
+			expected: "cannot choose a version of aeson:\n" +
+				"  2.2.3.0, 2.2.1.0 excluded by < 2.2 (acme-1.0)\n" +
+				"  aeson-2.1.2.1: then no version of text can be chosen\n" +
+				"  cannot choose a version of text:\n" +
+				"    2.1 excluded by >= 1.2 && < 2.1 (aeson-2.1.2.1)\n" +
+				"    2.0.2, 1.2.5.0 excluded by >= 2.1 (acme-1.0)",
+		},
+		{
+			name: "unknown package",
+			root: "  build-depends: base, acme-missiles\n",
+			expected: "cannot choose a version of acme-missiles:\n" +
+				"  no versions available",
+		},
+		{
+			name: "installed version",
+			root: "  build-depends: base >= 5\n",
+			expected: "cannot choose a version of base:\n" +
+				"  4.18.2.1 excluded by >= 5 (acme-1.0)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := resolverTestPackage(t, "acme", "1.0", tc.root)
+			opts := ResolverOptions{Installed: map[string]Version{"base": {4, 18, 2, 1}}}
+
+			_, err := NewResolver(resolverTestSource(t), opts).Resolve(root)
+
+			var resolveErr *ResolveError
+			if !errors.As(err, &resolveErr) {
+				t.Fatalf("ResolveError expected, got: %v", err)
+			}
+
+			if err.Error() != tc.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestResolver_Resolve_maxSteps(t *testing.T) {
+	root := resolverTestPackage(t, "acme", "1.0", "  build-depends: aeson, text < 2.1\n")
+	opts := ResolverOptions{Installed: map[string]Version{"base": {4, 18, 2, 1}}, MaxSteps: 2}
+
+	_, err := NewResolver(resolverTestSource(t), opts).Resolve(root)
+	if err == nil || err.Error() != "search limit of 2 steps reached" {
+		t.Errorf("search limit error expected, got: %v", err)
+	}
+}
+
+func TestIndex_Candidates(t *testing.T) {
+	idx, err := ReadIndex(indexTestTar(t, []indexTestFile{
+		{"text/1.2.5.0/text.cabal", "name: text\nversion: 1.2.5.0\n"},
+		{"text/2.0/text.cabal", "name: text\nversion: 2.0\n"},
+		{"text/2.0/text.cabal", "name: text\nversion: 2.0\nx-revision: 1\n"},
+		{"text/2.1/text.cabal", "name: text\nversion: 2.1\n"},
+		{"text/2.2/text.cabal", "name: text\nversion: 2.2\ntest-suite t\n"},
+		{"text/2.3/text.cabal", "name: text\nversion: 2.3\nunknown-field: x\n"},
+		{"text/preferred-versions", "text < 2.1 || > 2.1\n"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	candidates := idx.Candidates("text")
+
+	actual := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		actual = append(actual, c.Version.String())
+	}
+
+	if expected := []string{"2.3", "2.2", "2.0", "1.2.5.0", "2.1"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	if c := candidates[0]; c.Err == nil || c.Package != nil {
+		t.Errorf("parse error expected, got: %+v", c)
+	}
+
+	if c := candidates[1]; c.Err != nil || c.Package.Version != "2.2" {
+		t.Errorf("release with a test suite expected, got: %+v", c)
+	}
+
+	if candidates[2].Package.Revision != 1 {
+		t.Errorf("latest revision expected, got: %d", candidates[2].Package.Revision)
+	}
+
+	if actual := idx.Candidates("aeson"); len(actual) != 0 {
+		t.Errorf("no candidates expected, got: %v", actual)
+	}
+
+	root := resolverTestPackage(t, "acme", "1.0", "  build-depends: text > 2.2\n")
+
+	_, err = NewResolver(idx, ResolverOptions{}).Resolve(root)
+
+	expected := "cannot choose a version of text:\n" +
+		"  2.2, 2.0, 1.2.5.0, 2.1 excluded by > 2.2 (acme-1.0)\n" +
+		"  text-2.3: invalid cabal file: text/2.3/text.cabal: unsupported property: unknown-field"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%v", expected, err)
+	}
+}
@@ -0,0 +1,317 @@
+package gocabalparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RevisionEdit is a change Hackage does not allow in a metadata revision.
+// Section is empty for top-level fields and joins nested sections with
+// " / ", e.g. "library / if flag(dev)". Line is the 1-based line in the
+// revised file, or in the original file for removals, and 0 when the edit
+// has no location.
+type RevisionEdit struct {
+	Section string
+	Field   string
+	Line    int
+	Message string
+}
+
+func (e *RevisionEdit) String() string {
+	location := e.Section
+	if location != "" && e.Field != "" {
+		location += ": "
+	}
+
+	location += e.Field
+
+	if location == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+
+	return fmt.Sprintf("line %d: %s: %s", e.Line, location, e.Message)
+}
+
+type revisionRule int
+
+const (
+	revisionFixed revisionRule = iota
+	revisionFree
+	revisionDependencies
+)
+
+// CheckRevision compares a revised cabal file with the original one and
+// returns the edits Hackage rejects in metadata revisions; the revision is
+// permitted when there are none. Only dependency version bounds, synopsis,
+// description, homepage, bug-reports and package-url, source repository
+// locations and flag defaults may change, and x-revision must be
+// incremented by one. Whitespace, comments and field order are ignored.
+func CheckRevision(original, revised *SyntaxTree) []*RevisionEdit {
+	c := &revisionChecker{
+		originalLines: syntaxLines(original.Nodes),
+		revisedLines:  syntaxLines(revised.Nodes),
+		edits:         make([]*RevisionEdit, 0),
+	}
+
+	c.checkRevisionNumber(original.Nodes, revised.Nodes)
+	c.compare("", "", original.Nodes, revised.Nodes)
+
+	return c.edits
+}
+
+type revisionChecker struct {
+	originalLines map[*SyntaxNode]int
+	revisedLines  map[*SyntaxNode]int
+	edits         []*RevisionEdit
+}
+
+func (c *revisionChecker) add(section, field string, line int, format string, args ...interface{}) {
+	c.edits = append(c.edits, &RevisionEdit{
+		Section: section,
+		Field:   field,
+		Line:    line,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *revisionChecker) checkRevisionNumber(original, revised []*SyntaxNode) {
+	expected := 1
+
+	if n := findField(original, "x-revision"); n != nil {
+		rev, err := strconv.Atoi(strings.Join(n.Value(), " "))
+		if err != nil {
+			c.add("", "x-revision", c.originalLines[n], "invalid revision number in the original file")
+
+			return
+		}
+
+		expected = rev + 1
+	}
+
+	n := findField(revised, "x-revision")
+	if n == nil {
+		c.add("", "x-revision", 0, "x-revision: %d expected", expected)
+
+		return
+	}
+
+	if value := strings.Join(n.Value(), " "); value != strconv.Itoa(expected) {
+		c.add("", "x-revision", c.revisedLines[n], "x-revision %d expected, got: %s", expected, value)
+	}
+}
+
+// compare reports the edits between the contents of two sections. kind is
+// the lowercased name of the enclosing section, empty at the top level.
+func (c *revisionChecker) compare(section, kind string, original, revised []*SyntaxNode) {
+	originalKeys, originalNodes := revisionKeys(original)
+	revisedKeys, revisedNodes := revisionKeys(revised)
+
+	for _, k := range originalKeys {
+		n := originalNodes[k]
+
+		if _, ok := revisedNodes[k]; ok || isRevisionNumber(kind, n) {
+			continue
+		}
+
+		switch {
+		case n.Kind == SyntaxSection:
+			c.add(section, "", c.originalLines[n], "section removed: %s", sectionTitle(n))
+		case revisionFieldRule(kind, n.Name) == revisionFixed:
+			c.add(section, strings.ToLower(n.Name), c.originalLines[n], "field removed")
+		case revisionFieldRule(kind, n.Name) == revisionDependencies:
+			c.compareDependencies(section, n.Name, c.originalLines[n], n.Value(), nil)
+		}
+	}
+
+	for _, k := range revisedKeys {
+		n := revisedNodes[k]
+		line := c.revisedLines[n]
+
+		if isRevisionNumber(kind, n) {
+			continue
+		}
+
+		o, ok := originalNodes[k]
+		if !ok {
+			switch {
+			case n.Kind == SyntaxSection:
+				c.add(section, "", line, "section added: %s", sectionTitle(n))
+			case revisionFieldRule(kind, n.Name) == revisionFixed:
+				c.add(section, strings.ToLower(n.Name), line, "field added")
+			case revisionFieldRule(kind, n.Name) == revisionDependencies:
+				c.compareDependencies(section, n.Name, line, nil, n.Value())
+			}
+
+			continue
+		}
+
+		if n.Kind == SyntaxSection {
+			path := sectionTitle(n)
+			if section != "" {
+				path = section + " / " + path
+			}
+
+			c.compare(path, strings.ToLower(n.Name), o.Children, n.Children)
+
+			continue
+		}
+
+		switch revisionFieldRule(kind, n.Name) {
+		case revisionFixed:
+			if normalizedValue(o) != normalizedValue(n) {
+				c.add(section, strings.ToLower(n.Name), line, "value changed from %q to %q", normalizedValue(o), normalizedValue(n))
+			}
+		case revisionDependencies:
+			c.compareDependencies(section, n.Name, line, o.Value(), n.Value())
+		}
+	}
+}
+
+// compareDependencies allows changes of version ranges only: the package
+// names must stay the same.
+func (c *revisionChecker) compareDependencies(section, field string, line int, original, revised []string) {
+	field = strings.ToLower(field)
+
+	originalNames, err := dependencyNames(original)
+	if err != nil {
+		c.add(section, field, line, "invalid dependencies in the original file: %v", err)
+
+		return
+	}
+
+	revisedNames, err := dependencyNames(revised)
+	if err != nil {
+		c.add(section, field, line, "invalid dependencies: %v", err)
+
+		return
+	}
+
+	for _, name := range excludeStrings(originalNames, revisedNames) {
+		c.add(section, field, line, "dependency removed: %s", name)
+	}
+
+	for _, name := range excludeStrings(revisedNames, originalNames) {
+		c.add(section, field, line, "dependency added: %s", name)
+	}
+}
+
+func dependencyNames(lines []string) ([]string, error) {
+	deps, err := splitCommaList(strings.Join(lines, "\n"))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(deps))
+
+	for _, d := range deps {
+		end := packageNameEnd(d)
+		if end == 0 {
+			return nil, fmt.Errorf("package name expected: %s", d)
+		}
+
+		if constraint := strings.TrimSpace(d[end:]); constraint != "" {
+			if _, err := ParseVersionRange(constraint); err != nil {
+				return nil, fmt.Errorf("%s: %v", d, err)
+			}
+		}
+
+		res = append(res, d[:end])
+	}
+
+	return res, nil
+}
+
+func revisionFieldRule(kind, name string) revisionRule {
+	name = strings.ToLower(name)
+
+	switch kind {
+	case "":
+		switch name {
+		case "synopsis", "description", "homepage", "bug-reports", "package-url":
+			return revisionFree
+		}
+	case "source-repository":
+		if name == "location" {
+			return revisionFree
+		}
+	case "flag":
+		if name == "default" {
+			return revisionFree
+		}
+	default:
+		switch name {
+		case "build-depends", "build-tool-depends", "setup-depends":
+			return revisionDependencies
+		}
+	}
+
+	return revisionFixed
+}
+
+func isRevisionNumber(kind string, n *SyntaxNode) bool {
+	return kind == "" && n.Kind == SyntaxField && strings.EqualFold(n.Name, "x-revision")
+}
+
+// revisionKeys indexes the fields and sections of a section body by their
+// lowercased name and, for sections, arguments. Repeated keys are
+// numbered, so the second if os(windows) of a component is
+// "if os(windows)#2".
+func revisionKeys(nodes []*SyntaxNode) ([]string, map[string]*SyntaxNode) {
+	keys := make([]string, 0, len(nodes))
+	res := make(map[string]*SyntaxNode, len(nodes))
+
+	for _, n := range nodes {
+		if n.Kind != SyntaxField && n.Kind != SyntaxSection {
+			continue
+		}
+
+		key := strings.ToLower(n.Name)
+		if n.Kind == SyntaxSection {
+			key = strings.ToLower(sectionTitle(n))
+		}
+
+		base := key
+		for i := 2; res[key] != nil; i++ {
+			key = base + "#" + strconv.Itoa(i)
+		}
+
+		keys = append(keys, key)
+		res[key] = n
+	}
+
+	return keys, res
+}
+
+func sectionTitle(n *SyntaxNode) string {
+	return strings.TrimSpace(n.Name + " " + strings.Join(strings.Fields(n.Args), " "))
+}
+
+func normalizedValue(n *SyntaxNode) string {
+	return strings.Join(strings.Fields(strings.Join(n.Value(), " ")), " ")
+}
+
+// syntaxLines returns the 1-based line of each node.
+func syntaxLines(nodes []*SyntaxNode) map[*SyntaxNode]int {
+	res := make(map[*SyntaxNode]int)
+	line := 1
+
+	var walk func(nodes []*SyntaxNode)
+	walk = func(nodes []*SyntaxNode) {
+		for _, n := range nodes {
+			res[n] = line
+			line += len(n.Lines)
+			walk(n.Children)
+
+			for _, l := range n.Close {
+				if lineTerminator(l) != "" {
+					line++
+				}
+			}
+		}
+	}
+
+	walk(nodes)
+
+	return res
+}
@@ -0,0 +1,153 @@
+package gocabalparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const revisionTestOriginal = `cabal-version: 2.4
+name: acme
+version: 1.0
+synopsis: Original
+homepage: http://example.org
+license: MIT
+
+source-repository head
+  type: git
+  location: http://example.org/acme.git
+
+flag dev
+  description: Development build
+  default: False
+  manual: True
+
+library
+  exposed-modules: Acme
+  build-depends: base >= 4 && < 5,
+                 text >= 1.2
+  if flag(dev)
+    ghc-options: -O0
+    build-depends: deepseq
+
+executable acme
+  main-is: Main.hs
+  build-depends: base, acme
+`
+
+func mustParseSyntaxTree(t *testing.T, s string) *SyntaxTree {
+	tree, err := ParseSyntaxTree(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tree
+}
+
+func TestCheckRevision_allowed(t *testing.T) {
+	revised := `cabal-version: 2.4
+name: acme
+version: 1.0
+x-revision: 1
+-- relaxed bounds for GHC 9.8
+synopsis: Revised
+  synopsis
+description: Now with a description.
+homepage: https://example.org
+license:   MIT
+
+source-repository head
+  type: git
+  location: https://github.com/example/acme
+
+flag dev
+  description: Development build
+  default: True
+  manual: True
+
+library
+  exposed-modules: Acme
+  build-depends: base >= 4 && < 6, text >= 1.2 && < 2.2
+  if flag(dev)
+    ghc-options: -O0
+    build-depends: deepseq < 2
+
+executable acme
+  build-depends: acme, base
+  main-is: Main.hs
+`
+
+	edits := CheckRevision(mustParseSyntaxTree(t, revisionTestOriginal), mustParseSyntaxTree(t, revised))
+	if len(edits) != 0 {
+		t.Fatalf("expected no edits, got %v", edits)
+	}
+}
+
+func TestCheckRevision_disallowed(t *testing.T) {
+	original := strings.Replace(revisionTestOriginal, "version: 1.0\n", "version: 1.0\nx-revision: 1\n", 1)
+
+	revised := `cabal-version: 2.4
+name: acme
+version: 1.0.1
+x-revision: 3
+license: MIT
+author: Alice
+
+source-repository head
+  type: darcs
+  location: http://example.org/acme
+
+flag dev
+  description: Development build
+  default: False
+
+library
+  exposed-modules: Acme
+                   Acme.Internal
+  build-depends: base >= 4 && < 5,
+                 bytestring
+  if flag(dev)
+    ghc-options: -O0
+    build-depends: deepseq >=
+
+test-suite spec
+  type: exitcode-stdio-1.0
+  main-is: Spec.hs
+`
+
+	edits := CheckRevision(mustParseSyntaxTree(t, original), mustParseSyntaxTree(t, revised))
+
+	actual := make([]string, len(edits))
+	for i, e := range edits {
+		actual[i] = e.String()
+	}
+
+	expected := []string{
+		"line 4: x-revision: x-revision 2 expected, got: 3",
+		"line 26: section removed: executable acme",
+		"line 3: version: value changed from \"1.0\" to \"1.0.1\"",
+		"line 6: author: field added",
+		"line 9: source-repository head: type: value changed from \"git\" to \"darcs\"",
+		"line 16: flag dev: manual: field removed",
+		"line 17: library: exposed-modules: value changed from \"Acme\" to \"Acme Acme.Internal\"",
+		"line 19: library: build-depends: dependency removed: text",
+		"line 19: library: build-depends: dependency added: bytestring",
+		"line 23: library / if flag(dev): build-depends: invalid dependencies: deepseq >=: version expected after >=: empty version",
+		"line 25: section added: test-suite spec",
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+}
+
+func TestCheckRevision_revisionNumber(t *testing.T) {
+	original := mustParseSyntaxTree(t, "name: acme\nversion: 1.0\n")
+
+	edits := CheckRevision(original, mustParseSyntaxTree(t, "name: acme\nversion: 1.0\n"))
+
+	expected := []*RevisionEdit{{Field: "x-revision", Message: "x-revision: 1 expected"}}
+	if !reflect.DeepEqual(edits, expected) {
+		t.Fatalf("expected %v, got %v", expected, edits)
+	}
+}
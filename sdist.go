@@ -0,0 +1,159 @@
+package gocabalparser
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SdistFile is a regular file of a source distribution. Name is relative
+// to the top-level directory of the tarball.
+type SdistFile struct {
+	Name    string
+	Size    int64
+	Mode    int64
+	ModTime time.Time
+}
+
+// Sdist is a source distribution tarball such as acme-1.0.tar.gz. Dir is
+// its top-level directory, e.g. acme-1.0, and Files lists its regular
+// files sorted by name.
+type Sdist struct {
+	Dir       string
+	CabalFile string
+	Package   *CabalPackage
+	Files     []*SdistFile
+}
+
+// ReadSdist reads a gzipped source distribution without unpacking it. All
+// files must be in a single top-level directory named after the package
+// and version, holding a single .cabal file.
+func ReadSdist(r io.Reader) (*Sdist, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	defer zr.Close()
+
+	res := &Sdist{}
+
+	var cabalData []byte
+
+	tr := tar.NewReader(zr)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeDir:
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			return nil, fmt.Errorf("%s: unsupported tar entry type: %c", hdr.Name, hdr.Typeflag)
+		}
+
+		dir, name, err := splitSdistPath(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.Dir == "" {
+			res.Dir = dir
+		} else if dir != res.Dir {
+			return nil, fmt.Errorf("multiple top-level directories: %s and %s", res.Dir, dir)
+		}
+
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		if name == "" {
+			return nil, fmt.Errorf("file outside of the top-level directory: %s", hdr.Name)
+		}
+
+		res.Files = append(res.Files, &SdistFile{
+			Name:    name,
+			Size:    hdr.Size,
+			Mode:    hdr.Mode,
+			ModTime: hdr.ModTime,
+		})
+
+		if path.Ext(name) != ".cabal" || strings.Contains(name, "/") {
+			continue
+		}
+
+		if res.CabalFile != "" {
+			return nil, fmt.Errorf("multiple .cabal files: %s and %s", res.CabalFile, name)
+		}
+
+		res.CabalFile = name
+
+		if cabalData, err = io.ReadAll(tr); err != nil {
+			return nil, err
+		}
+	}
+
+	if res.CabalFile == "" {
+		return nil, errors.New("no .cabal file in the top-level directory")
+	}
+
+	if res.Package, err = NewParser().ParseReader(bytes.NewReader(cabalData)); err != nil {
+		return nil, fmt.Errorf("%s: %v", res.CabalFile, err)
+	}
+
+	if expected := res.Package.Name + "-" + res.Package.Version; res.Dir != expected {
+		return nil, fmt.Errorf("top-level directory %s expected, got: %s", expected, res.Dir)
+	}
+
+	if expected := res.Package.Name + ".cabal"; res.CabalFile != expected {
+		return nil, fmt.Errorf("cabal file %s expected, got: %s", expected, res.CabalFile)
+	}
+
+	sort.Slice(res.Files, func(i, j int) bool {
+		return res.Files[i].Name < res.Files[j].Name
+	})
+
+	return res, nil
+}
+
+// File returns the file with the given name, relative to Dir.
+func (s *Sdist) File(name string) (*SdistFile, bool) {
+	i := sort.Search(len(s.Files), func(i int) bool {
+		return s.Files[i].Name >= name
+	})
+
+	if i < len(s.Files) && s.Files[i].Name == name {
+		return s.Files[i], true
+	}
+
+	return nil, false
+}
+
+// splitSdistPath splits an entry name into the top-level directory and
+// the path below it, which is empty for the directory itself.
+func splitSdistPath(name string) (string, string, error) {
+	clean := path.Clean(strings.TrimPrefix(name, "./"))
+
+	if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", "", fmt.Errorf("invalid path in tarball: %s", name)
+	}
+
+	dir, rest, _ := strings.Cut(clean, "/")
+
+	return dir, rest, nil
+}
@@ -0,0 +1,88 @@
+package gocabalparser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"testing"
+)
+
+func sdistTestTarball(t *testing.T, files []indexTestFile) *bytes.Buffer {
+	var buf bytes.Buffer
+
+	zw := gzip.NewWriter(&buf)
+
+	if _, err := indexTestTar(t, files).WriteTo(zw); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &buf
+}
+
+func TestReadSdist(t *testing.T) {
+	buf := sdistTestTarball(t, []indexTestFile{
+		{"acme-1.0/", ""},
+		{"acme-1.0/acme.cabal", "name: acme\nversion: 1.0\n\nlibrary\n  exposed-modules: Acme\n"},
+		{"acme-1.0/src/Acme.hs", "module Acme where\n"},
+		{"acme-1.0/LICENSE", "MIT"},
+		{"acme-1.0/test/fixtures/other.cabal", ""},
+	})
+
+	sdist, err := ReadSdist(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sdist.Dir != "acme-1.0" || sdist.CabalFile != "acme.cabal" || sdist.Package.Name != "acme" {
+		t.Fatalf("unexpected sdist: %+v", sdist)
+	}
+
+	names := make([]string, len(sdist.Files))
+	for i, f := range sdist.Files {
+		names[i] = f.Name
+	}
+
+	expected := []string{"LICENSE", "acme.cabal", "src/Acme.hs", "test/fixtures/other.cabal"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %q, got %q", expected, names)
+	}
+
+	if f, ok := sdist.File("src/Acme.hs"); !ok || f.Size != 18 || f.Mode != 0644 {
+		t.Fatalf("unexpected file: %+v", f)
+	}
+
+	if _, ok := sdist.File("src"); ok {
+		t.Fatal("expected directories not to be listed")
+	}
+}
+
+func TestReadSdist_errors(t *testing.T) {
+	cabal := "name: acme\nversion: 1.0\n"
+
+	tcs := map[string][]indexTestFile{
+		"no cabal file":      {{"acme-1.0/README", ""}},
+		"two cabal files":    {{"acme-1.0/acme.cabal", cabal}, {"acme-1.0/other.cabal", cabal}},
+		"two directories":    {{"acme-1.0/acme.cabal", cabal}, {"other/README", ""}},
+		"top-level file":     {{"acme.cabal", cabal}},
+		"parent path":        {{"../acme-1.0/acme.cabal", cabal}},
+		"directory name":     {{"acme/acme.cabal", cabal}},
+		"cabal file name":    {{"acme-1.0/other.cabal", cabal}},
+		"invalid cabal file": {{"acme-1.0/acme.cabal", "name: acme\nbogus: 1\n"}},
+	}
+
+	for name, files := range tcs {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ReadSdist(sdistTestTarball(t, files)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+
+	if _, err := ReadSdist(bytes.NewBufferString("not gzip")); err == nil {
+		t.Fatal("expected error")
+	}
+}
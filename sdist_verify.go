@@ -0,0 +1,137 @@
+package gocabalparser
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+var sdistRuleIDs = map[string]string{
+	"license-file":     "sdist-missing-license-file",
+	"exposed-modules":  "sdist-missing-module",
+	"signatures":       "sdist-missing-module",
+	"other-modules":    "sdist-missing-module",
+	"main-is":          "sdist-missing-main-is",
+	"c-sources":        "sdist-missing-c-source",
+	"install-includes": "sdist-missing-include",
+}
+
+// VerifySdist reports the files of the package missing from its source
+// distribution: the license file, module sources, main-is, c-sources,
+// install-includes, and the files matched by data-files,
+// extra-source-files and extra-doc-files. When fsys, the package
+// directory, is given the globs are expanded against it and every match
+// must be in the tarball; when it is nil each glob must match a file of
+// the tarball. Invalid globs are an error.
+func VerifySdist(fsys fs.FS, p *CabalPackage, s *Sdist) ([]*Diagnostic, error) {
+	res := make([]*Diagnostic, 0)
+
+	exists := func(name string) bool {
+		_, ok := s.File(name)
+
+		return ok
+	}
+
+	for _, r := range sdistRequirements(p) {
+		if r.resolve(exists) == nil {
+			res = append(res, newDiagnostic(sdistRuleIDs[r.field], SeverityError, r.component, "%s", r.message()))
+		}
+	}
+
+	var (
+		globs []*Diagnostic
+		err   error
+	)
+
+	if fsys != nil {
+		globs, err = verifySdistExpandedGlobs(fsys, p, exists)
+	} else {
+		globs, err = verifySdistGlobs(p, s)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(res, globs...), nil
+}
+
+func verifySdistExpandedGlobs(fsys fs.FS, p *CabalPackage, exists func(name string) bool) ([]*Diagnostic, error) {
+	files, err := ExpandPackageFiles(fsys, p)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*Diagnostic, 0)
+
+	for _, u := range files.Unmatched {
+		res = append(res, newDiagnostic("sdist-unmatched-glob", SeverityError, "",
+			"%s: %s matches no files in the package directory", u.Field, u.Pattern))
+	}
+
+	for _, f := range []struct {
+		name  string
+		files []string
+	}{
+		{"data-files", files.DataFiles},
+		{"extra-source-files", files.ExtraSourceFiles},
+		{"extra-doc-files", files.ExtraDocFiles},
+	} {
+		for _, name := range f.files {
+			if !exists(name) {
+				res = append(res, newDiagnostic("sdist-missing-file", SeverityError, "", "%s: %s not found", f.name, name))
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func verifySdistGlobs(p *CabalPackage, s *Sdist) ([]*Diagnostic, error) {
+	spec, err := p.SpecVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*Diagnostic, 0)
+
+	dataDir := ""
+	if p.DataDir != "" {
+		dataDir = path.Clean(p.DataDir) + "/"
+	}
+
+	for _, f := range []struct {
+		name   string
+		prefix string
+		globs  []string
+	}{
+		{"data-files", dataDir, p.DataFiles},
+		{"extra-source-files", "", p.ExtraSourceFiles},
+		{"extra-doc-files", "", p.ExtraDocFiles},
+	} {
+		for _, pattern := range f.globs {
+			g, err := ParseFileGlob(pattern, spec)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", f.name, err)
+			}
+
+			if !sdistMatches(s, g, f.prefix) {
+				res = append(res, newDiagnostic("sdist-unmatched-glob", SeverityError, "",
+					"%s: %s matches no files", f.name, pattern))
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func sdistMatches(s *Sdist, g *FileGlob, prefix string) bool {
+	for _, f := range s.Files {
+		if strings.HasPrefix(f.Name, prefix) && g.Match(f.Name[len(prefix):]) {
+			return true
+		}
+	}
+
+	return false
+}
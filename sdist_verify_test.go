@@ -0,0 +1,148 @@
+package gocabalparser
+
+import (
+	"bytes"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVerifySdist(t *testing.T) {
+	p, err := NewParser().ParseReader(strings.NewReader(sdistTestCabal))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sdist, err := ReadSdist(sdistTestTarball(t, []indexTestFile{
+		{"acme-1.0/acme.cabal", sdistTestCabal},
+		{"acme-1.0/src/Acme.hs", ""},
+		{"acme-1.0/src/Acme/Lexer.hs", ""},
+		{"acme-1.0/include/acme.h", ""},
+		{"acme-1.0/data/other.txt", ""},
+		{"acme-1.0/CHANGELOG.md", ""},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcs := map[string]struct {
+		withFS   bool
+		expected []string
+	}{
+		"tarball globs": {
+			expected: []string{
+				"error [sdist-missing-license-file]: license-file: LICENSE not found",
+				"error [sdist-missing-module]: library: other-modules: module Acme.Windows not found in src, win",
+				"error [sdist-missing-c-source]: library: c-sources: cbits/acme.c not found",
+				"error [sdist-missing-main-is]: executable acme: main-is: Main.hs not found in app",
+				"error [sdist-unmatched-glob]: data-files: *.json matches no files",
+			},
+		},
+		"package directory globs": {
+			withFS: true,
+			expected: []string{
+				"error [sdist-missing-license-file]: license-file: LICENSE not found",
+				"error [sdist-missing-module]: library: other-modules: module Acme.Windows not found in src, win",
+				"error [sdist-missing-c-source]: library: c-sources: cbits/acme.c not found",
+				"error [sdist-missing-main-is]: executable acme: main-is: Main.hs not found in app",
+				"error [sdist-missing-file]: data-files: data/config.json not found",
+			},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			var fsys fs.FS
+			if tc.withFS {
+				fsys = sdistTestFS()
+			}
+
+			diagnostics, err := VerifySdist(fsys, p, sdist)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual := make([]string, len(diagnostics))
+			for i, d := range diagnostics {
+				actual[i] = d.String()
+			}
+
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Fatalf("expected:\n%s\ngot:\n%s", strings.Join(tc.expected, "\n"), strings.Join(actual, "\n"))
+			}
+		})
+	}
+}
+
+func TestVerifySdist_complete(t *testing.T) {
+	fsys := sdistTestFS()
+
+	p, err := NewParser().ParseReader(strings.NewReader(sdistTestCabal))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := WriteSdist(&buf, fsys, p); err != nil {
+		t.Fatal(err)
+	}
+
+	sdist, err := ReadSdist(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []fs.FS{nil, fsys} {
+		diagnostics, err := VerifySdist(dir, p, sdist)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(diagnostics) != 0 {
+			t.Fatalf("expected no diagnostics, got %v", diagnostics)
+		}
+	}
+
+	p.ExtraSourceFiles = []string{"../outside/*.md"}
+
+	if _, err := VerifySdist(nil, p, sdist); err == nil {
+		t.Fatal("expected invalid glob error")
+	}
+}
+
+func TestVerifySdist_generatedModules(t *testing.T) {
+	cabal := strings.NewReplacer(
+		"cabal-version: 2.4", "cabal-version: >=1.10",
+		"Paths_acme\n  autogen-modules: Paths_acme", "Paths_acme, PackageInfo_acme",
+	).Replace(sdistTestCabal)
+
+	p, err := NewParser().ParseReader(strings.NewReader(cabal))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := sdistTestFS()
+	fsys["acme.cabal"].Data = []byte(cabal)
+
+	var buf bytes.Buffer
+
+	if err := WriteSdist(&buf, fsys, p); err != nil {
+		t.Fatal(err)
+	}
+
+	sdist, err := ReadSdist(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diagnostics, err := VerifySdist(nil, p, sdist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diagnostics) != 0 {
+		t.Fatalf("expected no diagnostics, got %v", diagnostics)
+	}
+}
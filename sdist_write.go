@@ -0,0 +1,339 @@
+package gocabalparser
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sdistModTime is the modification time of all sdist entries, the one
+// cabal sdist uses for reproducible tarballs.
+var sdistModTime = time.Unix(1000000000, 0).UTC()
+
+// sdistRequirement is a file a source distribution must contain. Modules
+// and main-is are looked up in dirs, the source directories of the
+// component, and install-includes in the include directories; other files
+// are paths relative to the package directory.
+type sdistRequirement struct {
+	component string
+	field     string
+	name      string
+	dirs      []string
+}
+
+func (r *sdistRequirement) isModule() bool {
+	return strings.HasSuffix(r.field, "modules") || r.field == "signatures"
+}
+
+// resolve returns the files satisfying the requirement, or nil if there
+// are none. A module is the first file found with a module extension,
+// along with its hs-boot file if there is one.
+func (r *sdistRequirement) resolve(exists func(name string) bool) []string {
+	switch {
+	case r.isModule():
+		for _, dir := range r.dirs {
+			for _, ext := range moduleExtensions {
+				name := path.Join(dir, ModuleName(r.name).FilePath(ext))
+				if !exists(name) {
+					continue
+				}
+
+				res := []string{name}
+
+				if boot := strings.TrimSuffix(name, ext) + ".hs-boot"; exists(boot) {
+					res = append(res, boot)
+				}
+
+				return res
+			}
+		}
+	case r.dirs != nil:
+		for _, dir := range r.dirs {
+			if name := path.Join(dir, r.name); exists(name) {
+				return []string{name}
+			}
+		}
+	case exists(path.Clean(r.name)):
+		return []string{path.Clean(r.name)}
+	}
+
+	return nil
+}
+
+// message describes the requirement as missing.
+func (r *sdistRequirement) message() string {
+	switch {
+	case r.isModule():
+		return fmt.Sprintf("%s: module %s not found in %s", r.field, r.name, strings.Join(r.dirs, ", "))
+	case r.dirs != nil:
+		return fmt.Sprintf("%s: %s not found in %s", r.field, r.name, strings.Join(r.dirs, ", "))
+	default:
+		return fmt.Sprintf("%s: %s not found", r.field, r.name)
+	}
+}
+
+func (r *sdistRequirement) String() string {
+	if r.component == "" {
+		return r.message()
+	}
+
+	return r.component + ": " + r.message()
+}
+
+// sdistRequirements lists the files of the package other than globs:
+// the license file, module sources of all components and conditional
+// branches except autogen modules, Paths_ and PackageInfo_ included,
+// main-is of executables, c-sources and install-includes.
+func sdistRequirements(p *CabalPackage) []*sdistRequirement {
+	res := make([]*sdistRequirement, 0)
+
+	if p.LicenseFile != "" {
+		res = append(res, &sdistRequirement{field: "license-file", name: p.LicenseFile})
+	}
+
+	addComponent := func(component string, bi *BuildInfo, modules map[string][]ModuleName) {
+		dirs := sourceDirs(bi)
+		autogen := autogenModules(p.Name, bi)
+
+		for _, field := range []string{"exposed-modules", "signatures", "other-modules"} {
+			for _, m := range modules[field] {
+				if _, ok := autogen[m]; !ok {
+					res = append(res, &sdistRequirement{component: component, field: field, name: string(m), dirs: dirs})
+				}
+			}
+		}
+
+		for _, f := range branchValues(bi, func(b *BuildInfo) []string { return b.CSources }) {
+			res = append(res, &sdistRequirement{component: component, field: "c-sources", name: f})
+		}
+
+		includeDirs := make([]string, 0)
+		for _, dir := range branchValues(bi, func(b *BuildInfo) []string { return b.IncludeDirs }) {
+			includeDirs = appendUnique(includeDirs, path.Clean(dir))
+		}
+
+		includeDirs = appendUnique(includeDirs, ".")
+
+		for _, f := range branchValues(bi, func(b *BuildInfo) []string { return b.InstallIncludes }) {
+			res = append(res, &sdistRequirement{component: component, field: "install-includes", name: f, dirs: includeDirs})
+		}
+	}
+
+	if l := p.Library; l != nil {
+		addComponent("library", &l.BuildInfo, libraryModules(l))
+	}
+
+	for _, name := range sortedKeys(p.SubLibraries) {
+		l := p.SubLibraries[name]
+		addComponent("library "+name, &l.BuildInfo, libraryModules(l))
+	}
+
+	for _, name := range sortedKeys(p.Executables) {
+		e := p.Executables[name]
+		component := "executable " + name
+
+		mains := []string{e.MainIs}
+		for _, b := range conditionalBranches(&e.BuildInfo) {
+			mains = append(mains, b.MainIs)
+		}
+
+		for _, main := range mains {
+			if main != "" {
+				res = append(res, &sdistRequirement{component: component, field: "main-is", name: main, dirs: sourceDirs(&e.BuildInfo)})
+			}
+		}
+
+		addComponent(component, &e.BuildInfo, map[string][]ModuleName{"other-modules": otherModules(&e.BuildInfo)})
+	}
+
+	return res
+}
+
+// libraryModules returns the modules of the library by field, those of
+// its conditional branches included.
+func libraryModules(l *Library) map[string][]ModuleName {
+	exposed := append([]ModuleName{}, l.ExposedModules...)
+	signatures := append([]ModuleName{}, l.Signatures...)
+
+	for _, b := range conditionalBranches(&l.BuildInfo) {
+		exposed = append(exposed, b.ExposedModules...)
+		signatures = append(signatures, b.Signatures...)
+	}
+
+	return map[string][]ModuleName{
+		"exposed-modules": exposed,
+		"signatures":      signatures,
+		"other-modules":   otherModules(&l.BuildInfo),
+	}
+}
+
+func otherModules(bi *BuildInfo) []ModuleName {
+	return branchValues(bi, func(b *BuildInfo) []ModuleName { return b.OtherModules })
+}
+
+// autogenModules returns autogen-modules of the component and its
+// conditional branches, along with the Paths_ and PackageInfo_ modules
+// cabal generates, which packages before cabal-version 2.0 list in
+// other-modules only.
+func autogenModules(pkg string, bi *BuildInfo) map[ModuleName]struct{} {
+	suffix := strings.ReplaceAll(pkg, "-", "_")
+	res := map[ModuleName]struct{}{
+		ModuleName("Paths_" + suffix):       {},
+		ModuleName("PackageInfo_" + suffix): {},
+	}
+
+	for _, m := range branchValues(bi, func(b *BuildInfo) []ModuleName { return b.AutogenModules }) {
+		res[m] = struct{}{}
+	}
+
+	return res
+}
+
+// sourceDirs returns hs-source-dirs of the component and its conditional
+// branches, or the package directory when there are none.
+func sourceDirs(bi *BuildInfo) []string {
+	res := make([]string, 0)
+
+	for _, dir := range branchValues(bi, func(b *BuildInfo) []string { return b.HSSourceDirs }) {
+		res = appendUnique(res, path.Clean(dir))
+	}
+
+	if len(res) == 0 {
+		res = append(res, ".")
+	}
+
+	return res
+}
+
+// SdistFiles returns the sorted paths of the files cabal sdist puts in
+// the source distribution of the package: the .cabal file, Setup.hs or
+// Setup.lhs, the license file, module sources, main-is, c-sources,
+// install-includes, and the files matched by data-files,
+// extra-source-files and extra-doc-files. fsys must
+// be rooted at the package directory. A missing file or a glob matching
+// nothing is an error.
+func SdistFiles(fsys fs.FS, p *CabalPackage) ([]string, error) {
+	exists := func(name string) bool { return fileExists(fsys, name) }
+
+	cabalFile := p.Name + ".cabal"
+	if !exists(cabalFile) {
+		return nil, fmt.Errorf("%s not found", cabalFile)
+	}
+
+	res := []string{cabalFile}
+
+	for _, setup := range []string{"Setup.hs", "Setup.lhs"} {
+		if exists(setup) {
+			res = append(res, setup)
+		}
+	}
+
+	for _, r := range sdistRequirements(p) {
+		files := r.resolve(exists)
+		if files == nil {
+			return nil, errors.New(r.String())
+		}
+
+		for _, f := range files {
+			res = appendUnique(res, f)
+		}
+	}
+
+	globs, err := ExpandPackageFiles(fsys, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(globs.Unmatched) > 0 {
+		u := globs.Unmatched[0]
+
+		return nil, fmt.Errorf("%s: %s matches no files", u.Field, u.Pattern)
+	}
+
+	for _, files := range [][]string{globs.DataFiles, globs.ExtraSourceFiles, globs.ExtraDocFiles} {
+		for _, f := range files {
+			res = appendUnique(res, f)
+		}
+	}
+
+	sort.Strings(res)
+
+	return res, nil
+}
+
+// WriteSdist writes the source distribution of the package, as cabal sdist
+// does, to w as a gzipped tarball meant to be named name-version.tar.gz.
+// Entries are sorted and have fixed timestamps, ownership and permissions,
+// so the same files always give the same bytes.
+func WriteSdist(w io.Writer, fsys fs.FS, p *CabalPackage) error {
+	files, err := SdistFiles(fsys, p)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	top := p.Name + "-" + p.Version
+	dirs := make(map[string]struct{})
+
+	var addDir func(dir string) error
+	addDir = func(dir string) error {
+		if _, ok := dirs[dir]; ok || dir == "." {
+			return nil
+		}
+
+		if err := addDir(path.Dir(dir)); err != nil {
+			return err
+		}
+
+		dirs[dir] = struct{}{}
+
+		return tw.WriteHeader(sdistHeader(path.Join(top, dir)+"/", tar.TypeDir, 0755, 0))
+	}
+
+	if err := tw.WriteHeader(sdistHeader(top+"/", tar.TypeDir, 0755, 0)); err != nil {
+		return err
+	}
+
+	for _, name := range files {
+		if err := addDir(path.Dir(name)); err != nil {
+			return err
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		if err := tw.WriteHeader(sdistHeader(path.Join(top, name), tar.TypeReg, 0644, int64(len(data)))); err != nil {
+			return err
+		}
+
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func sdistHeader(name string, typ byte, mode, size int64) *tar.Header {
+	return &tar.Header{
+		Typeflag: typ,
+		Name:     name,
+		Mode:     mode,
+		Size:     size,
+		ModTime:  sdistModTime,
+	}
+}
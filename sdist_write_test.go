@@ -0,0 +1,301 @@
+package gocabalparser
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+const sdistTestCabal = `cabal-version: 2.4
+name: acme
+version: 1.0
+license-file: LICENSE
+data-dir: data
+data-files: *.json
+extra-source-files: CHANGELOG.md
+
+library
+  hs-source-dirs: src
+  exposed-modules: Acme
+  other-modules: Acme.Lexer, Paths_acme
+  autogen-modules: Paths_acme
+  c-sources: cbits/acme.c
+  include-dirs: include
+  install-includes: acme.h
+  if os(windows)
+    hs-source-dirs: win
+    other-modules: Acme.Windows
+
+executable acme
+  main-is: Main.hs
+  hs-source-dirs: app
+`
+
+func sdistTestFS() fstest.MapFS {
+	return fstest.MapFS{
+		"acme.cabal":           {Data: []byte(sdistTestCabal), Mode: 0600, ModTime: time.Now()},
+		"Setup.hs":             {Data: []byte("main = defaultMain\n")},
+		"LICENSE":              {Data: []byte("MIT")},
+		"CHANGELOG.md":         {Data: []byte("# 1.0\n")},
+		"data/config.json":     {Data: []byte("{}")},
+		"data/notes.txt":       {},
+		"src/Acme.hs":          {Data: []byte("module Acme where\n")},
+		"src/Acme.hs-boot":     {},
+		"src/Acme/Lexer.x":     {},
+		"win/Acme/Windows.hsc": {Mode: 0755},
+		"app/Main.hs":          {},
+		"cbits/acme.c":         {},
+		"include/acme.h":       {},
+		"app/Unused.hs":        {},
+		"dist-newstyle/cache":  {},
+	}
+}
+
+func TestSdistFiles(t *testing.T) {
+	fsys := sdistTestFS()
+
+	p, err := NewParser().ParseReader(strings.NewReader(sdistTestCabal))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"CHANGELOG.md",
+		"LICENSE",
+		"Setup.hs",
+		"acme.cabal",
+		"app/Main.hs",
+		"cbits/acme.c",
+		"data/config.json",
+		"include/acme.h",
+		"src/Acme.hs",
+		"src/Acme.hs-boot",
+		"src/Acme/Lexer.x",
+		"win/Acme/Windows.hsc",
+	}
+
+	actual, err := SdistFiles(fsys, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestSdistFiles_generatedModules(t *testing.T) {
+	cabal := strings.NewReplacer(
+		"cabal-version: 2.4", "cabal-version: >=1.10",
+		"Paths_acme\n  autogen-modules: Paths_acme", "Paths_acme, PackageInfo_acme",
+	).Replace(sdistTestCabal)
+
+	p, err := NewParser().ParseReader(strings.NewReader(cabal))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Library.AutogenModules) != 0 {
+		t.Fatalf("expected no autogen-modules, got %v", p.Library.AutogenModules)
+	}
+
+	fsys := sdistTestFS()
+	fsys["acme.cabal"] = &fstest.MapFile{Data: []byte(cabal)}
+
+	actual, err := SdistFiles(fsys, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !containsString(actual, "src/Acme/Lexer.x") {
+		t.Fatalf("expected other modules in %q", actual)
+	}
+}
+
+func TestSdistFiles_cSources(t *testing.T) {
+	data, err := os.ReadFile("./testdata/9.cabal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := fstest.MapFS{
+		"cbits.cabal":        {Data: data},
+		"Setup.hs":           {},
+		"src/Data/Hash.hs":   {},
+		"cbits/hash.c":       {},
+		"cbits/util.c":       {},
+		"cbits/hash-sse.c":   {},
+		"include/hash.h":     {},
+		"include/x86/sse.h":  {},
+		"cbits/unrelated.c":  {},
+		"include/unlisted.h": {},
+	}
+
+	p, err := NewParser().ParseReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"Setup.hs",
+		"cbits.cabal",
+		"cbits/hash-sse.c",
+		"cbits/hash.c",
+		"cbits/util.c",
+		"include/hash.h",
+		"src/Data/Hash.hs",
+	}
+
+	actual, err := SdistFiles(fsys, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestSdistFiles_errors(t *testing.T) {
+	tcs := map[string]struct {
+		cabal    string
+		expected string
+	}{
+		"missing module": {
+			cabal:    strings.Replace(sdistTestCabal, "Acme.Lexer", "Acme.Parser", 1),
+			expected: "library: other-modules: module Acme.Parser not found in src, win",
+		},
+		"missing main": {
+			cabal:    strings.Replace(sdistTestCabal, "Main.hs", "App.hs", 1),
+			expected: "executable acme: main-is: App.hs not found in app",
+		},
+		"missing c source": {
+			cabal:    strings.Replace(sdistTestCabal, "acme.c", "other.c", 1),
+			expected: "library: c-sources: cbits/other.c not found",
+		},
+		"missing include": {
+			cabal:    strings.Replace(sdistTestCabal, "acme.h", "other.h", 1),
+			expected: "library: install-includes: other.h not found in include, .",
+		},
+		"missing license": {
+			cabal:    strings.Replace(sdistTestCabal, "LICENSE", "COPYING", 1),
+			expected: "license-file: COPYING not found",
+		},
+		"unmatched glob": {
+			cabal:    strings.Replace(sdistTestCabal, "CHANGELOG.md", "*.rst", 1),
+			expected: "extra-source-files: *.rst matches no files",
+		},
+		"missing cabal file": {
+			cabal:    strings.Replace(sdistTestCabal, "name: acme", "name: other", 1),
+			expected: "other.cabal not found",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewParser().ParseReader(strings.NewReader(tc.cabal))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = SdistFiles(sdistTestFS(), p)
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("expected error %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestWriteSdist(t *testing.T) {
+	fsys := sdistTestFS()
+
+	p, err := NewParser().ParseReader(strings.NewReader(sdistTestCabal))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var first, second bytes.Buffer
+
+	if err := WriteSdist(&first, fsys, p); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys["acme.cabal"].ModTime = time.Now().Add(time.Hour)
+
+	if err := WriteSdist(&second, fsys, p); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Fatal("expected reproducible tarballs")
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(first.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := tar.NewReader(zr)
+	entries := make([]string, 0)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !hdr.ModTime.Equal(sdistModTime) || hdr.Uid != 0 || hdr.Uname != "" {
+			t.Fatalf("%s: unexpected header %+v", hdr.Name, hdr)
+		}
+
+		entries = append(entries, hdr.Name+" "+hdr.FileInfo().Mode().String())
+	}
+
+	expected := []string{
+		"acme-1.0/ drwxr-xr-x",
+		"acme-1.0/CHANGELOG.md -rw-r--r--",
+		"acme-1.0/LICENSE -rw-r--r--",
+		"acme-1.0/Setup.hs -rw-r--r--",
+		"acme-1.0/acme.cabal -rw-r--r--",
+		"acme-1.0/app/ drwxr-xr-x",
+		"acme-1.0/app/Main.hs -rw-r--r--",
+		"acme-1.0/cbits/ drwxr-xr-x",
+		"acme-1.0/cbits/acme.c -rw-r--r--",
+		"acme-1.0/data/ drwxr-xr-x",
+		"acme-1.0/data/config.json -rw-r--r--",
+		"acme-1.0/include/ drwxr-xr-x",
+		"acme-1.0/include/acme.h -rw-r--r--",
+		"acme-1.0/src/ drwxr-xr-x",
+		"acme-1.0/src/Acme.hs -rw-r--r--",
+		"acme-1.0/src/Acme.hs-boot -rw-r--r--",
+		"acme-1.0/src/Acme/ drwxr-xr-x",
+		"acme-1.0/src/Acme/Lexer.x -rw-r--r--",
+		"acme-1.0/win/ drwxr-xr-x",
+		"acme-1.0/win/Acme/ drwxr-xr-x",
+		"acme-1.0/win/Acme/Windows.hsc -rw-r--r--",
+	}
+
+	if !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("expected %q, got %q", expected, entries)
+	}
+
+	sdist, err := ReadSdist(&first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f, ok := sdist.File("src/Acme.hs"); !ok || f.Size != 18 {
+		t.Fatalf("unexpected file: %+v", f)
+	}
+}
@@ -0,0 +1,338 @@
+package gocabalparser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// StackProject is a stack.yaml file. Flags maps package names to flag
+// settings.
+type StackProject struct {
+	Resolver  string
+	Packages  []*StackPackage
+	ExtraDeps []*StackExtraDep
+	Flags     map[string]map[string]bool
+}
+
+// StackPackage is a local package directory. CabalFile and Package are
+// set when the package was read, CabalFile being package.yaml for hpack
+// packages.
+type StackPackage struct {
+	Dir       string
+	CabalFile string
+	Package   *CabalPackage
+}
+
+type StackExtraDepKind int
+
+const (
+	// StackExtraDepHackage is a Hackage package, e.g. acme-missiles-0.3.
+	StackExtraDepHackage StackExtraDepKind = iota
+	// StackExtraDepGit is a commit of a git or GitHub repository.
+	StackExtraDepGit
+	// StackExtraDepArchive is a tarball or zip URL.
+	StackExtraDepArchive
+	// StackExtraDepPath is a local directory.
+	StackExtraDepPath
+)
+
+// StackExtraDep is an extra-deps entry. Revision keeps the part after @
+// of Hackage dependencies, such as rev:1 or a sha256 hash; Location is
+// the repository, archive URL or directory of the other kinds.
+type StackExtraDep struct {
+	Kind     StackExtraDepKind
+	Name     string
+	Version  Version
+	Revision string
+	Location string
+	Commit   string
+	Subdirs  []string
+}
+
+type StackParser interface {
+	ParseReader(r io.Reader) (*StackProject, error)
+}
+
+type stackParser struct {
+	fsys fs.FS
+}
+
+// NewStackParser returns a parser of stack.yaml files reading the listed
+// packages from fsys, the project directory. Packages are not read when
+// fsys is nil.
+func NewStackParser(fsys fs.FS) StackParser {
+	return &stackParser{fsys: fsys}
+}
+
+func (p *stackParser) ParseReader(r io.Reader) (*StackProject, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	top, err := parseHpackYAML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &StackProject{}
+
+	if res.Resolver, err = hpackString(top, "resolver"); err != nil {
+		return nil, err
+	}
+
+	if res.Resolver == "" {
+		if res.Resolver, err = hpackString(top, "snapshot"); err != nil {
+			return nil, err
+		}
+	}
+
+	v, ok := top.get("packages")
+	if !ok {
+		v = "."
+	}
+
+	dirs, err := hpackStrings("packages", v)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range dirs {
+		pkg, err := p.readPackage(dir)
+		if err != nil {
+			return nil, fmt.Errorf("package %s: %v", dir, err)
+		}
+
+		res.Packages = append(res.Packages, pkg)
+	}
+
+	deps, _ := top.get("extra-deps")
+
+	for _, item := range hpackList("extra-deps", deps) {
+		dep, err := parseStackExtraDep(item)
+		if err != nil {
+			return nil, fmt.Errorf("extra-deps: %v", err)
+		}
+
+		res.ExtraDeps = append(res.ExtraDeps, dep)
+	}
+
+	if res.Flags, err = stackFlags(top); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// readPackage reads the package in dir, preferring package.yaml over the
+// .cabal file as stack does.
+func (p *stackParser) readPackage(dir string) (*StackPackage, error) {
+	res := &StackPackage{Dir: dir}
+	if p.fsys == nil {
+		return res, nil
+	}
+
+	dir = path.Clean(strings.TrimPrefix(dir, "./"))
+
+	if hpackFile := path.Join(dir, "package.yaml"); fileExists(p.fsys, hpackFile) {
+		sub, err := fs.Sub(p.fsys, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		pkg, err := readPackageFile(p.fsys, hpackFile, NewHpackParser(HpackOptions{FS: sub}))
+		if err != nil {
+			return nil, err
+		}
+
+		res.CabalFile, res.Package = hpackFile, pkg
+
+		return res, nil
+	}
+
+	files, err := projectPackageFiles(p.fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) != 1 {
+		return nil, errors.New("no .cabal file or package.yaml found")
+	}
+
+	pkg, err := readPackageFile(p.fsys, files[0], NewParser())
+	if err != nil {
+		return nil, err
+	}
+
+	res.CabalFile, res.Package = files[0], pkg
+
+	return res, nil
+}
+
+func readPackageFile(fsys fs.FS, name string, parser Parser) (*CabalPackage, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return parser.ParseReader(f)
+}
+
+func fileExists(fsys fs.FS, name string) bool {
+	info, err := fs.Stat(fsys, name)
+
+	return err == nil && !info.IsDir()
+}
+
+func parseStackExtraDep(item interface{}) (*StackExtraDep, error) {
+	switch item := item.(type) {
+	case string:
+		return parseStackExtraDepString(item)
+	case *hpackMap:
+		res := &StackExtraDep{}
+
+		var err error
+
+		switch {
+		case hpackHas(item, "git"), hpackHas(item, "github"):
+			res.Kind = StackExtraDepGit
+
+			if res.Location, err = hpackString(item, "git"); err == nil && res.Location == "" {
+				var repo string
+				if repo, err = hpackString(item, "github"); err == nil {
+					res.Location = "https://github.com/" + repo
+				}
+			}
+		case hpackHas(item, "url"), hpackHas(item, "archive"):
+			res.Kind = StackExtraDepArchive
+
+			if res.Location, err = hpackString(item, "url"); err == nil && res.Location == "" {
+				res.Location, err = hpackString(item, "archive")
+			}
+		default:
+			return nil, fmt.Errorf("git, github, url or archive expected: %v", item.keys)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if res.Commit, err = hpackString(item, "commit"); err != nil {
+			return nil, err
+		}
+
+		if res.Kind == StackExtraDepGit && res.Commit == "" {
+			return nil, fmt.Errorf("commit expected for %s", res.Location)
+		}
+
+		subdirs, _ := item.get("subdirs")
+		if res.Subdirs, err = hpackStrings("subdirs", subdirs); err != nil {
+			return nil, err
+		}
+
+		return res, nil
+	default:
+		return nil, fmt.Errorf("unexpected value: %v", item)
+	}
+}
+
+// parseStackExtraDepString parses name-version[@revision], an archive URL
+// or file, or a local directory, which starts with a dot or contains a
+// slash.
+func parseStackExtraDepString(s string) (*StackExtraDep, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.Contains(s, "://") || isArchivePath(s) {
+		return &StackExtraDep{Kind: StackExtraDepArchive, Location: s}, nil
+	}
+
+	if strings.HasPrefix(s, ".") || strings.Contains(s, "/") {
+		return &StackExtraDep{Kind: StackExtraDepPath, Location: s}, nil
+	}
+
+	res := &StackExtraDep{Kind: StackExtraDepHackage}
+
+	if i := strings.IndexByte(s, '@'); i >= 0 {
+		s, res.Revision = s[:i], s[i+1:]
+	}
+
+	i := strings.LastIndexByte(s, '-')
+	if i <= 0 {
+		return nil, fmt.Errorf("name-version expected: %s", s)
+	}
+
+	v, err := ParseVersion(s[i+1:])
+	if err != nil {
+		return nil, fmt.Errorf("name-version expected: %s", s)
+	}
+
+	if !isPackageName(s[:i]) {
+		return nil, fmt.Errorf("invalid package name: %s", s[:i])
+	}
+
+	res.Name, res.Version = s[:i], v
+
+	return res, nil
+}
+
+func isArchivePath(s string) bool {
+	for _, ext := range []string{".tar.gz", ".tgz", ".tar", ".zip"} {
+		if strings.HasSuffix(strings.ToLower(s), ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func stackFlags(top *hpackMap) (map[string]map[string]bool, error) {
+	packages, err := hpackSections(top, "flags")
+	if err != nil {
+		return nil, err
+	}
+
+	var res map[string]map[string]bool
+
+	for _, pkg := range packages.keys {
+		flags, err := hpackMapping(packages.values[pkg])
+		if err != nil {
+			return nil, fmt.Errorf("flags: %s: %v", pkg, err)
+		}
+
+		if res == nil {
+			res = make(map[string]map[string]bool)
+		}
+
+		res[pkg] = make(map[string]bool)
+
+		for _, flag := range flags.keys {
+			value, err := hpackString(flags, flag)
+			if err != nil {
+				return nil, fmt.Errorf("flags: %s: %v", pkg, err)
+			}
+
+			switch strings.ToLower(value) {
+			case "true":
+				res[pkg][flag] = true
+			case "false":
+				res[pkg][flag] = false
+			default:
+				return nil, fmt.Errorf("flags: %s: boolean expected for %s", pkg, flag)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func hpackHas(m *hpackMap, key string) bool {
+	_, ok := m.get(key)
+
+	return ok
+}
@@ -0,0 +1,145 @@
+package gocabalparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStackParser_ParseReader(t *testing.T) {
+	fsys := fstest.MapFS{
+		"core/core.cabal":    {Data: []byte("name: core\nversion: 1.0\n")},
+		"app/package.yaml":   {Data: []byte("name: app\nversion: 0.1\nlibrary:\n  source-dirs: src\n")},
+		"app/app.cabal":      {Data: []byte("name: stale\nversion: 0.0\n")},
+		"app/src/App.hs":     {},
+		"core/src/Core.hs":   {},
+		"vendor/x/x.cabal":   {},
+		"unrelated/README":   {},
+		"unrelated/notes.md": {},
+	}
+
+	input := `resolver: lts-22.7
+packages:
+  - core
+  - ./app
+extra-deps:
+  - acme-missiles-0.3
+  - text-2.1@sha256:0123abcd,1234
+  - aeson-2.2.1.0@rev:1
+  - ./vendor/x
+  - git: https://github.com/example/fork
+    commit: 0123abc
+    subdirs:
+      - one
+      - two
+  - github: example/other
+    commit: 4567def
+  - url: https://example.org/pkg-1.0.tar.gz
+  - https://example.org/other-2.0.zip
+  - vendor/third-1.0.tar.gz
+flags:
+  core:
+    dev: true
+  app:
+    fast: false
+`
+
+	actual, err := NewStackParser(fsys).ParseReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedDeps := []*StackExtraDep{
+		{Kind: StackExtraDepHackage, Name: "acme-missiles", Version: Version{0, 3}},
+		{Kind: StackExtraDepHackage, Name: "text", Version: Version{2, 1}, Revision: "sha256:0123abcd,1234"},
+		{Kind: StackExtraDepHackage, Name: "aeson", Version: Version{2, 2, 1, 0}, Revision: "rev:1"},
+		{Kind: StackExtraDepPath, Location: "./vendor/x"},
+		{Kind: StackExtraDepGit, Location: "https://github.com/example/fork", Commit: "0123abc", Subdirs: []string{"one", "two"}},
+		{Kind: StackExtraDepGit, Location: "https://github.com/example/other", Commit: "4567def"},
+		{Kind: StackExtraDepArchive, Location: "https://example.org/pkg-1.0.tar.gz"},
+		{Kind: StackExtraDepArchive, Location: "https://example.org/other-2.0.zip"},
+		{Kind: StackExtraDepArchive, Location: "vendor/third-1.0.tar.gz"},
+	}
+
+	if actual.Resolver != "lts-22.7" {
+		t.Fatalf("expected resolver lts-22.7, got %s", actual.Resolver)
+	}
+
+	if !reflect.DeepEqual(actual.ExtraDeps, expectedDeps) {
+		t.Fatalf("expected %+v, got %+v", expectedDeps, actual.ExtraDeps)
+	}
+
+	expectedFlags := map[string]map[string]bool{
+		"core": {"dev": true},
+		"app":  {"fast": false},
+	}
+
+	if !reflect.DeepEqual(actual.Flags, expectedFlags) {
+		t.Fatalf("expected %v, got %v", expectedFlags, actual.Flags)
+	}
+
+	packages := make([]string, len(actual.Packages))
+	for i, p := range actual.Packages {
+		packages[i] = p.Dir + " " + p.CabalFile + " " + p.Package.Name
+	}
+
+	expectedPackages := []string{"core core/core.cabal core", "./app app/package.yaml app"}
+	if !reflect.DeepEqual(packages, expectedPackages) {
+		t.Fatalf("expected %q, got %q", expectedPackages, packages)
+	}
+
+	if modules := actual.Packages[1].Package.Library.ExposedModules; !reflect.DeepEqual(modules, []ModuleName{"App"}) {
+		t.Fatalf("expected modules inferred from app/src, got %v", modules)
+	}
+}
+
+func TestStackParser_ParseReader_defaults(t *testing.T) {
+	fsys := fstest.MapFS{"root.cabal": {Data: []byte("name: root\nversion: 1\n")}}
+
+	actual, err := NewStackParser(fsys).ParseReader(strings.NewReader("snapshot: nightly-2024-01-01\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.Resolver != "nightly-2024-01-01" {
+		t.Fatalf("expected snapshot as resolver, got %s", actual.Resolver)
+	}
+
+	if len(actual.Packages) != 1 || actual.Packages[0].Dir != "." || actual.Packages[0].Package.Name != "root" {
+		t.Fatalf("expected the root package, got %+v", actual.Packages)
+	}
+
+	actual, err = NewStackParser(nil).ParseReader(strings.NewReader("resolver: lts-22.7\npackages: [a, b]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(actual.Packages) != 2 || actual.Packages[1].Dir != "b" || actual.Packages[1].Package != nil {
+		t.Fatalf("expected unread packages, got %+v", actual.Packages)
+	}
+}
+
+func TestStackParser_ParseReader_errors(t *testing.T) {
+	fsys := fstest.MapFS{"empty/README": {}}
+
+	tcs := map[string]string{
+		"missing package":    "packages: [missing]\n",
+		"no cabal file":      "packages: [empty]\n",
+		"name-version":       "packages: []\nextra-deps: [acme-missiles]\n",
+		"bad version":        "packages: []\nextra-deps: [acme-missiles-x]\n",
+		"git without commit": "packages: []\nextra-deps:\n  - git: https://example.org/x\n",
+		"unknown location":   "packages: []\nextra-deps:\n  - hg: https://example.org/x\n",
+		"flag value":         "packages: []\nflags:\n  a:\n    dev: maybe\n",
+		"flags list":         "packages: []\nflags: [a]\n",
+		"invalid yaml":       "packages: [\n",
+	}
+
+	for name, input := range tcs {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewStackParser(fsys).ParseReader(strings.NewReader(input)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
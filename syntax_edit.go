@@ -0,0 +1,663 @@
+package gocabalparser
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// SetVersion replaces the value of the version field.
+func (t *SyntaxTree) SetVersion(v Version) error {
+	field := findField(t.Nodes, "version")
+	if field == nil {
+		return fmt.Errorf("version field not found")
+	}
+
+	l := parseFieldList(field, false)
+	if len(l.items) != 1 {
+		return fmt.Errorf("unexpected version value: %q", field.Value())
+	}
+
+	l.replaceItem(l.items[0], v.String())
+
+	return nil
+}
+
+// AddDependency appends a build-depends entry, e.g. "containers >= 0.6",
+// to the component. Components are named as in diagnostics: "library",
+// "library <name>" or "executable <name>".
+func (t *SyntaxTree) AddDependency(component, dependency string) error {
+	dep, err := newDependenciesParser().ParseString(strings.Join(strings.Fields(dependency), " "))
+	if err != nil {
+		return err
+	}
+
+	section, err := t.findComponent(component)
+	if err != nil {
+		return err
+	}
+
+	field := findField(section.Children, "build-depends")
+	if field == nil {
+		addField(section, "build-depends", dependency)
+
+		return nil
+	}
+
+	l := parseFieldList(field, true)
+	if _, ok := l.findDependency(dep.Name); ok {
+		return fmt.Errorf("%s already depends on %s", component, dep.Name)
+	}
+
+	l.appendItem(dependency, true)
+
+	return nil
+}
+
+// RemoveDependency removes the build-depends entry of the named package
+// from the component, dropping the field when it becomes empty.
+func (t *SyntaxTree) RemoveDependency(component, name string) error {
+	section, err := t.findComponent(component)
+	if err != nil {
+		return err
+	}
+
+	field := findField(section.Children, "build-depends")
+	if field == nil {
+		return fmt.Errorf("%s has no build-depends", component)
+	}
+
+	l := parseFieldList(field, true)
+
+	item, ok := l.findDependency(name)
+	if !ok {
+		return fmt.Errorf("%s does not depend on %s", component, name)
+	}
+
+	if l.removeItem(item) {
+		removeChild(section, field)
+	}
+
+	return nil
+}
+
+// SetDependencyBounds replaces the version constraint of a dependency,
+// e.g. ">= 1.2 && < 1.4". An empty constraint drops the bounds.
+func (t *SyntaxTree) SetDependencyBounds(component, name, constraint string) error {
+	section, err := t.findComponent(component)
+	if err != nil {
+		return err
+	}
+
+	field := findField(section.Children, "build-depends")
+	if field == nil {
+		return fmt.Errorf("%s has no build-depends", component)
+	}
+
+	l := parseFieldList(field, true)
+
+	item, ok := l.findDependency(name)
+	if !ok {
+		return fmt.Errorf("%s does not depend on %s", component, name)
+	}
+
+	text := l.itemText(item)
+	nameEnd := dependencyNameEnd(text)
+	sep := " "
+
+	if rest := text[nameEnd:]; strings.TrimSpace(rest) != "" {
+		sep = rest[:len(rest)-len(strings.TrimLeft(rest, " \t"))]
+	}
+
+	replacement := text[:nameEnd]
+	if constraint = strings.TrimSpace(constraint); constraint != "" {
+		replacement += sep + constraint
+	}
+
+	if _, err := newDependenciesParser().ParseString(strings.Join(strings.Fields(replacement), " ")); err != nil {
+		return err
+	}
+
+	l.replaceItem(item, replacement)
+
+	return nil
+}
+
+// AddOtherModule appends a module to other-modules of the component.
+func (t *SyntaxTree) AddOtherModule(component string, m ModuleName) error {
+	section, err := t.findComponent(component)
+	if err != nil {
+		return err
+	}
+
+	field := findField(section.Children, "other-modules")
+	if field == nil {
+		addField(section, "other-modules", string(m))
+
+		return nil
+	}
+
+	l := parseFieldList(field, false)
+
+	for _, item := range l.items {
+		if l.itemText(item) == string(m) {
+			return fmt.Errorf("%s already lists module %s", component, m)
+		}
+	}
+
+	l.appendItem(string(m), false)
+
+	return nil
+}
+
+// AddExecutable appends a new executable stanza at the end of the file,
+// indented like the existing stanzas.
+func (t *SyntaxTree) AddExecutable(name string, ex *Executable) error {
+	if _, err := t.findComponent("executable " + name); err == nil {
+		return fmt.Errorf("executable %s already exists", name)
+	}
+
+	indent := stanzaIndent
+	newline := "\n"
+
+	for _, n := range t.Nodes {
+		if n.Kind == SyntaxSection && len(n.Children) > 0 && n.Children[0].Kind == SyntaxField {
+			indent = strings.Repeat(" ", n.Children[0].Indent-n.Indent)
+			newline = lineTerminator(n.Lines[0])
+
+			break
+		}
+	}
+
+	var b strings.Builder
+
+	w := bufio.NewWriter(&b)
+	fmt.Fprintf(w, "\nexecutable %s\n", name)
+	writeFields(w, indent, executableFields(ex))
+	w.Flush()
+
+	text := b.String()
+	if newline != "\n" {
+		text = strings.ReplaceAll(text, "\n", newline)
+	}
+
+	if last := lastLine(t.Nodes); last != nil && lineTerminator(*last) == "" {
+		*last += newline
+	}
+
+	added := newSyntaxTreeParser().Parse(splitLines(text))
+	t.Nodes = append(t.Nodes, added.Nodes...)
+
+	return nil
+}
+
+func (t *SyntaxTree) findComponent(component string) (*SyntaxNode, error) {
+	component = strings.Join(strings.Fields(component), " ")
+	keyword, args, _ := strings.Cut(component, " ")
+
+	for _, n := range t.Nodes {
+		if n.Kind != SyntaxSection || !strings.EqualFold(n.Name, keyword) {
+			continue
+		}
+
+		if strings.Join(strings.Fields(n.Args), " ") == args {
+			return n, nil
+		}
+	}
+
+	return nil, fmt.Errorf("component not found: %s", component)
+}
+
+func findField(nodes []*SyntaxNode, name string) *SyntaxNode {
+	for _, n := range nodes {
+		if n.Kind == SyntaxField && strings.EqualFold(n.Name, name) {
+			return n
+		}
+	}
+
+	return nil
+}
+
+// addField inserts a new field after the last field of the section, using
+// the indentation and value alignment of its sibling fields.
+func addField(section *SyntaxNode, name, value string) {
+	indent := strings.Repeat(" ", section.Indent+len(stanzaIndent))
+	newline := lineTerminator(section.Lines[0])
+	column := -1
+	last := -1
+
+	for i, n := range section.Children {
+		if n.Kind != SyntaxField {
+			continue
+		}
+
+		last = i
+		indent = n.Lines[0][:n.Indent]
+		newline = lineTerminator(n.Lines[len(n.Lines)-1])
+
+		if c := fieldValueColumn(n); column == -1 {
+			column = c
+		} else if c != column {
+			column = 0
+		}
+	}
+
+	if newline == "" {
+		newline = "\n"
+	}
+
+	header := indent + name + ":"
+	if pad := column - len(header); column > 0 && pad > 0 {
+		header += strings.Repeat(" ", pad)
+	} else {
+		header += " "
+	}
+
+	if last >= 0 {
+		prev := section.Children[last]
+		if tail := &prev.Lines[len(prev.Lines)-1]; lineTerminator(*tail) == "" {
+			*tail += newline
+		}
+	}
+
+	node := &SyntaxNode{
+		Kind:   SyntaxField,
+		Name:   name,
+		Indent: len(indent),
+		Lines:  []string{header + value + newline},
+	}
+
+	children := append([]*SyntaxNode{}, section.Children[:last+1]...)
+	children = append(children, node)
+	section.Children = append(children, section.Children[last+1:]...)
+}
+
+// fieldValueColumn returns the column where the value of the field starts
+// on its first line, or 0 if the value starts on the next line.
+func fieldValueColumn(n *SyntaxNode) int {
+	l := strings.TrimRight(n.Lines[0], "\r\n")
+	i := strings.IndexByte(l, ':') + 1
+
+	if strings.TrimSpace(l[i:]) == "" {
+		return 0
+	}
+
+	return i + len(l[i:]) - len(strings.TrimLeft(l[i:], " \t"))
+}
+
+func removeChild(parent, child *SyntaxNode) {
+	for i, n := range parent.Children {
+		if n == child {
+			parent.Children = append(parent.Children[:i], parent.Children[i+1:]...)
+
+			return
+		}
+	}
+}
+
+func lastLine(nodes []*SyntaxNode) *string {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	n := nodes[len(nodes)-1]
+	if len(n.Close) > 0 {
+		return &n.Close[len(n.Close)-1]
+	}
+
+	if l := lastLine(n.Children); l != nil {
+		return l
+	}
+
+	return &n.Lines[len(n.Lines)-1]
+}
+
+func lineTerminator(l string) string {
+	switch {
+	case strings.HasSuffix(l, "\r\n"):
+		return "\r\n"
+	case strings.HasSuffix(l, "\n"):
+		return "\n"
+	default:
+		return ""
+	}
+}
+
+func dependencyNameEnd(s string) int {
+	i := 0
+	for i < len(s) && (isFieldNameChar(s[i]) || s[i] == ':') {
+		i++
+	}
+
+	return i
+}
+
+// listElement is an item or a separating comma of a field value, located
+// by its line within the field and byte offsets within that line.
+type listElement struct {
+	comma bool
+	line  int
+	start int
+	end   int
+}
+
+type fieldList struct {
+	node     *SyntaxNode
+	elements []*listElement
+	items    []*listElement
+}
+
+// parseFieldList locates list elements of a field. In comma mode only
+// commas outside parentheses and braces separate items, so version sets
+// such as ^>= {4.14, 4.15} stay whole; otherwise whitespace separates
+// items as well.
+func parseFieldList(n *SyntaxNode, commaMode bool) *fieldList {
+	l := &fieldList{node: n}
+
+	for i, line := range n.Lines {
+		start := 0
+		if i == 0 {
+			start = strings.IndexByte(line, ':') + 1
+		} else if isCommentLine(line) {
+			continue
+		}
+
+		content := strings.TrimRight(line, "\r\n")
+		item := -1
+		depth := 0
+
+		for j := start; j <= len(content); j++ {
+			if j < len(content) && commaMode {
+				switch content[j] {
+				case '(', '{':
+					depth++
+				case ')', '}':
+					if depth > 0 {
+						depth--
+					}
+				}
+			}
+
+			comma := j < len(content) && content[j] == ',' && depth == 0
+			sep := j == len(content) || comma || (!commaMode && isSpace(content[j]))
+
+			if sep && item >= 0 {
+				end := j
+				for end > item && isSpace(content[end-1]) {
+					end--
+				}
+
+				e := &listElement{line: i, start: item, end: end}
+				l.elements = append(l.elements, e)
+				l.items = append(l.items, e)
+				item = -1
+			}
+
+			if j == len(content) {
+				break
+			}
+
+			if comma {
+				l.elements = append(l.elements, &listElement{comma: true, line: i, start: j, end: j + 1})
+			} else if item < 0 && !isSpace(content[j]) {
+				item = j
+			}
+		}
+	}
+
+	return l
+}
+
+func (l *fieldList) itemText(e *listElement) string {
+	return l.node.Lines[e.line][e.start:e.end]
+}
+
+func (l *fieldList) findDependency(name string) (*listElement, bool) {
+	for _, item := range l.items {
+		text := l.itemText(item)
+		if text[:dependencyNameEnd(text)] == name {
+			return item, true
+		}
+	}
+
+	return nil, false
+}
+
+func (l *fieldList) replaceItem(e *listElement, text string) {
+	line := l.node.Lines[e.line]
+	l.node.Lines[e.line] = line[:e.start] + text + line[e.end:]
+	e.end = e.start + len(text)
+}
+
+func (l *fieldList) neighbours(e *listElement) (prev, next *listElement) {
+	for i, el := range l.elements {
+		if el != e {
+			continue
+		}
+
+		if i > 0 && l.elements[i-1].comma {
+			prev = l.elements[i-1]
+		}
+
+		if i+1 < len(l.elements) && l.elements[i+1].comma {
+			next = l.elements[i+1]
+		}
+	}
+
+	return prev, next
+}
+
+// removeItem deletes the item together with one adjacent comma, preferring
+// a comma on the same line, and drops lines left empty. It reports whether
+// the list became empty.
+func (l *fieldList) removeItem(e *listElement) bool {
+	type cut struct {
+		line, start, end int
+		repl             string
+	}
+
+	prev, next := l.neighbours(e)
+	cuts := make([]cut, 0, 2)
+
+	switch {
+	case next != nil && next.line == e.line:
+		end := next.end
+		for content := strings.TrimRight(l.node.Lines[e.line], "\r\n"); end < len(content) && isSpace(content[end]); end++ {
+		}
+
+		cuts = append(cuts, cut{line: e.line, start: e.start, end: end})
+	case prev != nil && prev.line == e.line:
+		start := prev.start
+		for line := l.node.Lines[e.line]; !l.isFirstOnLine(prev) && start > 0 && isSpace(line[start-1]); start-- {
+		}
+
+		cuts = append(cuts, cut{line: e.line, start: start, end: e.end})
+	case next != nil:
+		cuts = append(cuts,
+			cut{line: e.line, start: e.start, end: e.end},
+			cut{line: next.line, start: next.start, end: next.end, repl: " "},
+		)
+	case prev != nil:
+		cuts = append(cuts,
+			cut{line: e.line, start: e.start, end: e.end},
+			cut{line: prev.line, start: prev.start, end: prev.end},
+		)
+	default:
+		cuts = append(cuts, cut{line: e.line, start: e.start, end: e.end})
+	}
+
+	changed := make(map[int]struct{})
+
+	for i := len(cuts) - 1; i >= 0; i-- {
+		c := cuts[i]
+		line := l.node.Lines[c.line]
+		l.node.Lines[c.line] = line[:c.start] + c.repl + line[c.end:]
+		changed[c.line] = struct{}{}
+	}
+
+	lines := make([]string, 0, len(l.node.Lines))
+
+	for i, line := range l.node.Lines {
+		if _, ok := changed[i]; ok && strings.TrimSpace(line) == "" && i > 0 {
+			continue
+		}
+
+		if _, ok := changed[i]; ok && i == 0 {
+			line = strings.TrimRight(strings.TrimRight(line, "\r\n"), " \t") + lineTerminator(line)
+		}
+
+		lines = append(lines, line)
+	}
+
+	// the next item moves up when the first one leaves the field line
+	// empty, keeping the value column of the field
+	header := lines[0]
+	if _, value, _ := strings.Cut(header, ":"); e.line == 0 && len(l.items) > 1 && strings.TrimSpace(value) == "" &&
+		len(lines) > 1 && !isCommentLine(lines[1]) {
+		lines[0] = strings.TrimRight(header, " \t\r\n") + " " + strings.TrimSpace(lines[1]) + lineTerminator(header)
+		lines = append(lines[:1], lines[2:]...)
+	}
+
+	l.node.Lines = lines
+
+	return len(l.items) == 1
+}
+
+func (l *fieldList) isFirstOnLine(e *listElement) bool {
+	for _, el := range l.elements {
+		if el.line == e.line {
+			return el == e
+		}
+	}
+
+	return false
+}
+
+// appendItem adds an item after the last one, following the layout of the
+// list: on the same line for single line lists, otherwise on a new line
+// with leading or trailing commas as used by the existing items. Items of
+// comma lists are always separated by commas, even when the list has a
+// single item so far.
+func (l *fieldList) appendItem(text string, commaList bool) {
+	if len(l.items) == 0 {
+		header := l.node.Lines[0]
+		content := strings.TrimRight(header, "\r\n")
+		l.node.Lines[0] = content + " " + text + lineTerminator(header)
+
+		return
+	}
+
+	last := l.items[len(l.items)-1]
+	hasCommas := false
+	leading := (*listElement)(nil)
+	trailing := false
+
+	for i, el := range l.elements {
+		if !el.comma {
+			continue
+		}
+
+		hasCommas = true
+
+		if l.isFirstOnLine(el) && i+1 < len(l.elements) && !l.elements[i+1].comma {
+			leading = el
+		}
+
+		if i == len(l.elements)-1 {
+			trailing = true
+		}
+	}
+
+	singleLine := true
+
+	for _, item := range l.items {
+		if item.line != last.line {
+			singleLine = false
+		}
+	}
+
+	lines := l.node.Lines
+	line := lines[last.line]
+
+	if singleLine {
+		sep := " "
+		if hasCommas || commaList {
+			sep = ", "
+		}
+
+		at := last.end
+		if trailing {
+			sep = " "
+			text += ","
+			at = l.elements[len(l.elements)-1].end
+		}
+
+		l.node.Lines[last.line] = line[:at] + sep + text + line[at:]
+
+		return
+	}
+
+	newline := lineTerminator(lines[len(lines)-1])
+	if newline == "" {
+		newline = "\n"
+		lines[len(lines)-1] += newline
+		line = lines[last.line]
+	}
+
+	var added string
+
+	switch {
+	case leading != nil:
+		line := lines[leading.line]
+		indent := line[:leading.start]
+		if leading.line == 0 {
+			indent = strings.Repeat(" ", leading.start)
+		}
+
+		added = indent + line[leading.start:l.itemStartAfter(leading)] + text + newline
+	case hasCommas:
+		if !trailing {
+			line = line[:last.end] + "," + line[last.end:]
+			lines[last.line] = line
+		} else {
+			text += ","
+		}
+
+		added = l.indentOf(last) + text + newline
+	case commaList:
+		// a single entry spanning several lines
+		lines[last.line] = line[:last.end] + "," + line[last.end:]
+		added = l.indentOf(l.items[0]) + text + newline
+	default:
+		added = l.indentOf(last) + text + newline
+	}
+
+	after := last.line + 1
+	if trailing && l.elements[len(l.elements)-1].line > last.line {
+		after = l.elements[len(l.elements)-1].line + 1
+	}
+
+	res := append([]string{}, lines[:after]...)
+	res = append(res, added)
+	l.node.Lines = append(res, lines[after:]...)
+}
+
+// indentOf returns the whitespace placing a new line's item under e.
+func (l *fieldList) indentOf(e *listElement) string {
+	if e.line == 0 {
+		return strings.Repeat(" ", e.start)
+	}
+
+	return l.node.Lines[e.line][:e.start]
+}
+
+// itemStartAfter returns the offset of the item following a leading comma.
+func (l *fieldList) itemStartAfter(comma *listElement) int {
+	for i, el := range l.elements {
+		if el == comma && i+1 < len(l.elements) {
+			return l.elements[i+1].start
+		}
+	}
+
+	return comma.end
+}
@@ -0,0 +1,315 @@
+package gocabalparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSyntaxTree_edits(t *testing.T) {
+	tcs := []struct {
+		name     string
+		input    string
+		edit     func(t *SyntaxTree) error
+		expected string
+	}{
+		{
+			name:  "set version",
+			input: "name:    foo\nversion: 0.1.0.0\n",
+			edit: func(t *SyntaxTree) error {
+				return t.SetVersion(Version{0, 2})
+			},
+			expected: "name:    foo\nversion: 0.2\n",
+		},
+		{
+			name:  "add dependency to mixed-case executable",
+			input: "Executable  HLint\n  main-is: Main.hs\n  build-depends: base\n",
+			edit: func(t *SyntaxTree) error {
+				return t.AddDependency("executable HLint", "text")
+			},
+			expected: "Executable  HLint\n  main-is: Main.hs\n  build-depends: base, text\n",
+		},
+		{
+			name: "add dependency to leading comma list",
+			input: `library
+    build-depends:
+        , base >= 4 && < 5
+        -- containers is optional
+        , containers
+`,
+			edit: func(t *SyntaxTree) error {
+				return t.AddDependency("library", "text >= 2")
+			},
+			expected: `library
+    build-depends:
+        , base >= 4 && < 5
+        -- containers is optional
+        , containers
+        , text >= 2
+`,
+		},
+		{
+			name: "add dependency to aligned leading comma list",
+			input: `library
+  build-depends: base
+               , containers
+  hs-source-dirs: src
+`,
+			edit: func(t *SyntaxTree) error {
+				return t.AddDependency("library", "text")
+			},
+			expected: `library
+  build-depends: base
+               , containers
+               , text
+  hs-source-dirs: src
+`,
+		},
+		{
+			name: "add dependency to trailing comma list",
+			input: `library
+  build-depends: base,
+                 containers
+`,
+			edit: func(t *SyntaxTree) error {
+				return t.AddDependency("library", "text")
+			},
+			expected: `library
+  build-depends: base,
+                 containers,
+                 text
+`,
+		},
+		{
+			name:  "add dependency to single line list",
+			input: "executable app\n    main-is:          Main.hs\n    build-depends:    base, app\n",
+			edit: func(t *SyntaxTree) error {
+				return t.AddDependency("executable app", "text")
+			},
+			expected: "executable app\n    main-is:          Main.hs\n    build-depends:    base, app, text\n",
+		},
+		{
+			name:  "add dependency to single entry",
+			input: "library\n  build-depends: base\n",
+			edit: func(t *SyntaxTree) error {
+				return t.AddDependency("library", "text")
+			},
+			expected: "library\n  build-depends: base, text\n",
+		},
+		{
+			name: "add dependency to multi-line entry without commas",
+			input: `library
+  build-depends:
+    base >= 4
+      && < 5
+  hs-source-dirs: src
+`,
+			edit: func(t *SyntaxTree) error {
+				return t.AddDependency("library", "text")
+			},
+			expected: `library
+  build-depends:
+    base >= 4
+      && < 5,
+    text
+  hs-source-dirs: src
+`,
+		},
+		{
+			name:  "add dependency without build-depends",
+			input: "library\n  exposed-modules: Foo\n  hs-source-dirs:  src\n\n-- end\n",
+			edit: func(t *SyntaxTree) error {
+				return t.AddDependency("library", "base")
+			},
+			expected: "library\n  exposed-modules: Foo\n  hs-source-dirs:  src\n  build-depends:   base\n\n-- end\n",
+		},
+		{
+			name: "remove dependency from leading comma list",
+			input: `library
+    build-depends:
+        , base >= 4 && < 5
+        , containers
+        , text
+`,
+			edit: func(t *SyntaxTree) error {
+				return t.RemoveDependency("library", "containers")
+			},
+			expected: `library
+    build-depends:
+        , base >= 4 && < 5
+        , text
+`,
+		},
+		{
+			name: "remove last dependency of trailing comma list",
+			input: `library
+  build-depends: base,
+                 containers
+`,
+			edit: func(t *SyntaxTree) error {
+				return t.RemoveDependency("library", "containers")
+			},
+			expected: `library
+  build-depends: base
+`,
+		},
+		{
+			name: "remove first dependency of aligned leading comma list",
+			input: `library
+  build-depends: base
+               , containers
+               , text
+`,
+			edit: func(t *SyntaxTree) error {
+				return t.RemoveDependency("library", "base")
+			},
+			expected: `library
+  build-depends: containers
+               , text
+`,
+		},
+		{
+			name: "remove first dependency of trailing comma list",
+			input: `library
+  build-depends: base,
+                 containers,
+                 text
+`,
+			edit: func(t *SyntaxTree) error {
+				return t.RemoveDependency("library", "base")
+			},
+			expected: `library
+  build-depends: containers,
+                 text
+`,
+		},
+		{
+			name:  "edit dependencies next to version sets",
+			input: "library\n  build-depends: base ^>= {4.14, 4.15}, text, foo:{a, b}\n",
+			edit: func(t *SyntaxTree) error {
+				if err := t.RemoveDependency("library", "text"); err != nil {
+					return err
+				}
+
+				return t.AddDependency("library", "containers")
+			},
+			expected: "library\n  build-depends: base ^>= {4.14, 4.15}, foo:{a, b}, containers\n",
+		},
+		{
+			name:  "remove dependency from single line list",
+			input: "library\n  build-depends: base, containers, text\n",
+			edit: func(t *SyntaxTree) error {
+				return t.RemoveDependency("library", "text")
+			},
+			expected: "library\n  build-depends: base, containers\n",
+		},
+		{
+			name:  "remove only dependency",
+			input: "library\n  build-depends:  base\n  hs-source-dirs: src\n",
+			edit: func(t *SyntaxTree) error {
+				return t.RemoveDependency("library", "base")
+			},
+			expected: "library\n  hs-source-dirs: src\n",
+		},
+		{
+			name:  "set dependency bounds",
+			input: "library\n  build-depends: base  >= 4 && < 5, containers\n",
+			edit: func(t *SyntaxTree) error {
+				if err := t.SetDependencyBounds("library", "base", ">= 4.14 && < 5"); err != nil {
+					return err
+				}
+
+				return t.SetDependencyBounds("library", "containers", ">= 0.6")
+			},
+			expected: "library\n  build-depends: base  >= 4.14 && < 5, containers >= 0.6\n",
+		},
+		{
+			name:  "add other module",
+			input: "library sub\r\n  other-modules: Foo\r\n                 Bar\r\n",
+			edit: func(t *SyntaxTree) error {
+				return t.AddOtherModule("library sub", "Baz.Qux")
+			},
+			expected: "library sub\r\n  other-modules: Foo\r\n                 Bar\r\n                 Baz.Qux\r\n",
+		},
+		{
+			name:  "add executable",
+			input: "name: foo\n\nlibrary\n    exposed-modules: Foo",
+			edit: func(t *SyntaxTree) error {
+				return t.AddExecutable("foo-app", &Executable{
+					MainIs: "Main.hs",
+					BuildInfo: BuildInfo{
+						BuildDepends: []*Dependency{{Name: "base"}, {Name: "foo"}},
+					},
+				})
+			},
+			expected: `name: foo
+
+library
+    exposed-modules: Foo
+
+executable foo-app
+    main-is:       Main.hs
+    build-depends: base
+                 , foo
+`,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			tree, err := ParseSyntaxTree(strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tc.edit(tree); err != nil {
+				t.Fatal(err)
+			}
+
+			if actual := tree.String(); actual != tc.expected {
+				t.Fatalf("expected:\n%q\nactual:\n%q", tc.expected, actual)
+			}
+
+			reparsed, err := ParseSyntaxTree(strings.NewReader(tree.String()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := reparsed.Package(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSyntaxTree_editErrors(t *testing.T) {
+	input := "version: 1.0\n\nlibrary\n  build-depends: base\n\nexecutable app\n  main-is: Main.hs\n"
+
+	tcs := []struct {
+		name string
+		edit func(t *SyntaxTree) error
+	}{
+		{"unknown component", func(t *SyntaxTree) error { return t.AddDependency("library missing", "base") }},
+		{"duplicate dependency", func(t *SyntaxTree) error { return t.AddDependency("library", "base >= 4") }},
+		{"missing dependency", func(t *SyntaxTree) error { return t.RemoveDependency("library", "text") }},
+		{"no build-depends", func(t *SyntaxTree) error { return t.SetDependencyBounds("executable app", "base", "< 5") }},
+		{"duplicate executable", func(t *SyntaxTree) error { return t.AddExecutable("app", &Executable{MainIs: "Main.hs"}) }},
+		{"component name case", func(t *SyntaxTree) error { return t.AddDependency("executable App", "text") }},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			tree, err := ParseSyntaxTree(strings.NewReader(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tc.edit(tree); err == nil {
+				t.Fatal("expected error")
+			}
+
+			if tree.String() != input {
+				t.Fatalf("tree changed on error:\n%s", tree.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package gocabalparser
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+type SyntaxNodeKind int
+
+const (
+	SyntaxField SyntaxNodeKind = iota
+	SyntaxSection
+	SyntaxComment
+	SyntaxBlank
+)
+
+func (k SyntaxNodeKind) String() string {
+	switch k {
+	case SyntaxField:
+		return "Field"
+	case SyntaxSection:
+		return "Section"
+	case SyntaxComment:
+		return "Comment"
+	case SyntaxBlank:
+		return "Blank"
+	default:
+		return "Unknown"
+	}
+}
+
+// SyntaxNode is a node of the concrete syntax tree. Lines holds the raw
+// source lines of the node, newlines included: the field line with its
+// continuation lines and interleaved comments for fields, the header line
+// for sections. Section contents are kept in Children. Sections written
+// with braces, e.g. if flag(dev) {, keep their closing brace in Close; it
+// may share its line with the header of the following else section.
+type SyntaxNode struct {
+	Kind     SyntaxNodeKind
+	Name     string
+	Args     string
+	Indent   int
+	Lines    []string
+	Children []*SyntaxNode
+	Close    []string
+}
+
+// SyntaxTree is a lossless representation of a .cabal file which keeps
+// whitespace, comments, field name casing and ordering.
+type SyntaxTree struct {
+	Nodes []*SyntaxNode
+}
+
+// ParseSyntaxTree reads a .cabal file into a syntax tree.
+func ParseSyntaxTree(r io.Reader) (*SyntaxTree, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSyntaxTreeParser().Parse(splitLines(string(data))), nil
+}
+
+// WriteTo writes the tree back; an unmodified tree reproduces the parsed
+// input byte for byte.
+func (t *SyntaxTree) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	writeSyntaxNodes(&buf, t.Nodes)
+
+	return buf.WriteTo(w)
+}
+
+func (t *SyntaxTree) String() string {
+	var b strings.Builder
+
+	t.WriteTo(&b)
+
+	return b.String()
+}
+
+// Package parses the package described by the tree. Common stanzas are
+// inlined where they are imported. Test suites, benchmarks, foreign
+// libraries and custom-setup are skipped, as are the fields CabalPackage
+// doesn't model, such as buildable, cpp-options or extra-libraries, and x-
+// fields.
+func (t *SyntaxTree) Package() (*CabalPackage, error) {
+	nodes, err := inlineImports(t.Nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTokensParser().Parse(syntaxTokens(modeledNodes(nodes), isConditionalSection))
+}
+
+// Value returns the trimmed value lines of a field, skipping comments and
+// blank lines.
+func (n *SyntaxNode) Value() []string {
+	if n.Kind != SyntaxField {
+		return nil
+	}
+
+	res := make([]string, 0, len(n.Lines))
+
+	for i, l := range n.Lines {
+		if i == 0 {
+			l = l[strings.IndexByte(l, ':')+1:]
+		} else if isCommentLine(l) {
+			continue
+		}
+
+		if l = strings.TrimSpace(l); l != "" {
+			res = append(res, l)
+		}
+	}
+
+	return res
+}
+
+type syntaxFrame struct {
+	node   *SyntaxNode
+	indent int
+	brace  bool
+}
+
+type syntaxTreeParser struct {
+	root   *SyntaxNode
+	frames []syntaxFrame
+	field  *SyntaxNode
+	trivia []*SyntaxNode
+}
+
+func newSyntaxTreeParser() *syntaxTreeParser {
+	root := &SyntaxNode{Kind: SyntaxSection, Indent: -1}
+
+	return &syntaxTreeParser{
+		root:   root,
+		frames: []syntaxFrame{{node: root, indent: -1}},
+	}
+}
+
+func (p *syntaxTreeParser) Parse(lines []string) *SyntaxTree {
+	for _, l := range lines {
+		switch {
+		case strings.TrimSpace(l) == "":
+			p.trivia = append(p.trivia, &SyntaxNode{Kind: SyntaxBlank, Lines: []string{l}})
+		case isCommentLine(l):
+			p.trivia = append(p.trivia, &SyntaxNode{Kind: SyntaxComment, Indent: lineIndent(l), Lines: []string{l}})
+		default:
+			p.addLine(l)
+		}
+	}
+
+	p.field = nil
+	p.frames = p.frames[:1]
+	p.flushTrivia()
+
+	return &SyntaxTree{Nodes: p.root.Children}
+}
+
+func (p *syntaxTreeParser) addLine(l string) {
+	indent := lineIndent(l)
+
+	if strings.HasPrefix(l[indent:], "}") && p.inBraces() {
+		p.closeBrace(l, indent)
+
+		return
+	}
+
+	if p.field != nil && indent > p.field.Indent {
+		for _, t := range p.trivia {
+			p.field.Lines = append(p.field.Lines, t.Lines...)
+		}
+
+		p.trivia = nil
+		p.field.Lines = append(p.field.Lines, l)
+
+		return
+	}
+
+	p.field = nil
+
+	for top := p.frames[len(p.frames)-1]; !top.brace && indent <= top.indent; top = p.frames[len(p.frames)-1] {
+		p.frames = p.frames[:len(p.frames)-1]
+	}
+
+	p.flushTrivia()
+	p.addNode(parseSyntaxLine(l, indent))
+}
+
+func (p *syntaxTreeParser) addNode(node *SyntaxNode) {
+	parent := p.frames[len(p.frames)-1].node
+	parent.Children = append(parent.Children, node)
+
+	if node.Kind == SyntaxField {
+		p.field = node
+
+		return
+	}
+
+	brace := strings.HasSuffix(node.Args, "{")
+	if brace {
+		node.Args = strings.TrimSpace(strings.TrimSuffix(node.Args, "{"))
+	}
+
+	p.frames = append(p.frames, syntaxFrame{node: node, indent: node.Indent, brace: brace})
+}
+
+func (p *syntaxTreeParser) inBraces() bool {
+	for _, f := range p.frames {
+		if f.brace {
+			return true
+		}
+	}
+
+	return false
+}
+
+// closeBrace ends the innermost section opened with a brace. The rest of
+// the line, as in } else {, starts a new section.
+func (p *syntaxTreeParser) closeBrace(l string, indent int) {
+	p.field = nil
+	p.flushTrivia()
+
+	for !p.frames[len(p.frames)-1].brace {
+		p.frames = p.frames[:len(p.frames)-1]
+	}
+
+	section := p.frames[len(p.frames)-1].node
+	p.frames = p.frames[:len(p.frames)-1]
+
+	end := indent + 1
+	rest := l[end:]
+
+	if strings.TrimSpace(rest) == "" {
+		section.Close = append(section.Close, l)
+
+		return
+	}
+
+	end += lineIndent(rest)
+	section.Close = append(section.Close, l[:end])
+	node := parseSyntaxLine(l[end:], 0)
+	node.Indent = indent
+	p.addNode(node)
+}
+
+func (p *syntaxTreeParser) flushTrivia() {
+	parent := p.frames[len(p.frames)-1].node
+	parent.Children = append(parent.Children, p.trivia...)
+	p.trivia = nil
+}
+
+func parseSyntaxLine(l string, indent int) *SyntaxNode {
+	content := strings.TrimRight(l[indent:], "\r\n")
+
+	nameEnd := 0
+	for nameEnd < len(content) && isFieldNameChar(content[nameEnd]) {
+		nameEnd++
+	}
+
+	name := content[:nameEnd]
+	rest := content[nameEnd:]
+
+	if strings.HasPrefix(strings.TrimLeft(rest, " \t"), ":") && name != "" {
+		return &SyntaxNode{Kind: SyntaxField, Name: name, Indent: indent, Lines: []string{l}}
+	}
+
+	return &SyntaxNode{
+		Kind:   SyntaxSection,
+		Name:   name,
+		Args:   strings.TrimSpace(rest),
+		Indent: indent,
+		Lines:  []string{l},
+	}
+}
+
+func writeSyntaxNodes(buf *bytes.Buffer, nodes []*SyntaxNode) {
+	for _, n := range nodes {
+		for _, l := range n.Lines {
+			buf.WriteString(l)
+		}
+
+		writeSyntaxNodes(buf, n.Children)
+
+		for _, l := range n.Close {
+			buf.WriteString(l)
+		}
+	}
+}
+
+// syntaxTokens flattens the tree into tokens understood by tokensParser.
+// Sections for which closed returns true are followed by a scope end.
+func syntaxTokens(nodes []*SyntaxNode, closed func(name string) bool) tokens {
+	res := make(tokens, 0)
+
+	for _, n := range nodes {
+		switch n.Kind {
+		case SyntaxField:
+			res = append(res, &token{Type: tokenTypeKey, Value: n.Name})
+
+			for _, v := range n.Value() {
+				res = append(res, &token{Type: tokenTypeValue, Value: v})
+			}
+		case SyntaxSection:
+			res = append(res,
+				&token{Type: tokenTypeKey, Value: n.Name},
+				&token{Type: tokenTypeScopeName, Value: n.Args},
+			)
+			res = append(res, syntaxTokens(n.Children, closed)...)
+
+			if closed(n.Name) {
+				res = append(res, &token{Type: tokenTypeScopeEnd})
+			}
+		}
+	}
+
+	return res
+}
+
+// splitLines splits s into lines keeping their line terminators.
+func splitLines(s string) []string {
+	lines := make([]string, 0)
+
+	for s != "" {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			lines = append(lines, s)
+
+			break
+		}
+
+		lines = append(lines, s[:i+1])
+		s = s[i+1:]
+	}
+
+	return lines
+}
+
+func lineIndent(l string) int {
+	i := 0
+	for i < len(l) && (l[i] == ' ' || l[i] == '\t') {
+		i++
+	}
+
+	return i
+}
+
+func isCommentLine(l string) bool {
+	return strings.HasPrefix(strings.TrimLeft(l, " \t"), "--")
+}
+
+func isFieldNameChar(c byte) bool {
+	return c == '-' || c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
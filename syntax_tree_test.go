@@ -0,0 +1,160 @@
+package gocabalparser
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSyntaxTree_roundTrip(t *testing.T) {
+	for _, name := range []string{"1.cabal", "2.cabal", "3.cabal", "4.cabal", "5.cabal", "6.cabal", "7.cabal", "8.cabal", "9.cabal", "10.cabal", "11.cabal"} {
+		t.Run(name, func(t *testing.T) {
+			expected, err := os.ReadFile(fmt.Sprintf("./testdata/%s", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tree, err := ParseSyntaxTree(strings.NewReader(string(expected)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual := tree.String(); actual != string(expected) {
+				t.Fatalf("expected:\n%q\nactual:\n%q", expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseSyntaxTree_structure(t *testing.T) {
+	f, err := os.Open("./testdata/6.cabal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	tree, err := ParseSyntaxTree(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type node struct {
+		kind     SyntaxNodeKind
+		name     string
+		children []node
+	}
+
+	var describe func(nodes []*SyntaxNode) []node
+	describe = func(nodes []*SyntaxNode) []node {
+		res := make([]node, 0, len(nodes))
+		for _, n := range nodes {
+			res = append(res, node{kind: n.Kind, name: n.Name, children: describe(n.Children)})
+		}
+
+		return res
+	}
+
+	expected := []node{
+		{kind: SyntaxField, name: "cabal-version", children: []node{}},
+		{kind: SyntaxComment, children: []node{}},
+		{kind: SyntaxField, name: "Name", children: []node{}},
+		{kind: SyntaxField, name: "version", children: []node{}},
+		{kind: SyntaxBlank, children: []node{}},
+		{kind: SyntaxField, name: "synopsis", children: []node{}},
+		{kind: SyntaxField, name: "description", children: []node{}},
+		{kind: SyntaxBlank, children: []node{}},
+		{kind: SyntaxComment, children: []node{}},
+		{kind: SyntaxSection, name: "library", children: []node{
+			{kind: SyntaxField, name: "exposed-modules", children: []node{}},
+			{kind: SyntaxBlank, children: []node{}},
+			{kind: SyntaxField, name: "build-depends", children: []node{}},
+		}},
+		{kind: SyntaxBlank, children: []node{}},
+		{kind: SyntaxComment, children: []node{}},
+		{kind: SyntaxBlank, children: []node{}},
+		{kind: SyntaxSection, name: "executable", children: []node{
+			{kind: SyntaxField, name: "main-is", children: []node{}},
+			{kind: SyntaxField, name: "build-depends", children: []node{}},
+			{kind: SyntaxField, name: "hs-source-dirs", children: []node{}},
+		}},
+		{kind: SyntaxComment, children: []node{}},
+	}
+
+	if actual := describe(tree.Nodes); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, actual)
+	}
+
+	description := tree.Nodes[6].Value()
+	if !reflect.DeepEqual(description, []string{"First paragraph.", "Second paragraph."}) {
+		t.Fatalf("unexpected description value: %q", description)
+	}
+
+	modules := tree.Nodes[9].Children[0].Value()
+	if !reflect.DeepEqual(modules, []string{"Commented", "Commented.Internal"}) {
+		t.Fatalf("unexpected exposed-modules value: %q", modules)
+	}
+}
+
+func TestParseSyntaxTree_braces(t *testing.T) {
+	tree, err := ParseSyntaxTree(strings.NewReader(`library {
+  build-depends: base
+  if flag(dev) {
+    ghc-options: -Werror
+  } else {
+  ghc-options: -Wall
+  }
+}
+executable acme
+  main-is: Main.hs
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tree.Nodes) != 2 {
+		t.Fatalf("expected library and executable, got %d nodes", len(tree.Nodes))
+	}
+
+	lib := tree.Nodes[0]
+	if lib.Args != "" || !reflect.DeepEqual(lib.Close, []string{"}\n"}) || len(lib.Children) != 3 {
+		t.Fatalf("unexpected library: %+v", lib)
+	}
+
+	cond, els := lib.Children[1], lib.Children[2]
+	if cond.Args != "flag(dev)" || !reflect.DeepEqual(cond.Close, []string{"  } "}) || len(cond.Children) != 1 {
+		t.Fatalf("unexpected if: %+v", cond)
+	}
+
+	if els.Name != "else" || els.Args != "" || els.Indent != 2 || len(els.Children) != 1 {
+		t.Fatalf("unexpected else: %+v", els)
+	}
+
+	if ex := tree.Nodes[1]; ex.Args != "acme" || len(ex.Children) != 1 {
+		t.Fatalf("unexpected executable: %+v", ex)
+	}
+}
+
+func TestSyntaxTree_Package(t *testing.T) {
+	for _, name := range []string{"6.cabal", "7.cabal", "8.cabal", "9.cabal", "10.cabal", "11.cabal"} {
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(fmt.Sprintf("./testdata/%s", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer f.Close()
+
+			p, err := NewParser().ParseReader(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if p.Library == nil || len(p.Library.BuildDepends) == 0 || p.Library.BuildDepends[0].Name != "base" {
+				t.Fatalf("unexpected library: %+v", p.Library)
+			}
+		})
+	}
+}
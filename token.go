@@ -0,0 +1,46 @@
+package gocabalparser
+
+import "fmt"
+
+type tokenType int
+
+const (
+	tokenTypeKey tokenType = iota
+	tokenTypeValue
+	tokenTypeScopeName
+	// tokenTypeScopeEnd closes scopes, such as conditionals, whose fields
+	// can't be told apart from the fields following them otherwise.
+	tokenTypeScopeEnd
+)
+
+func (t tokenType) String() string {
+	switch t {
+	case tokenTypeKey:
+		return "Key"
+	case tokenTypeValue:
+		return "Value"
+	case tokenTypeScopeName:
+		return "ScopeName"
+	case tokenTypeScopeEnd:
+		return "ScopeEnd"
+	default:
+		return fmt.Sprintf("unknown token: %d", t)
+	}
+}
+
+type token struct {
+	Type  tokenType
+	Value string
+}
+
+type tokens []*token
+
+func (t tokens) String() string {
+	r := ""
+
+	for _, v := range t {
+		r += fmt.Sprintf("[%s: %s]\n", v.Type, v.Value)
+	}
+
+	return r
+}
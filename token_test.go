@@ -14,7 +14,7 @@ func testMakeToken(typ tokenType, val string) *token {
 	}
 }
 
-func TestTokenizer_tokenizeReader(t *testing.T) {
+func TestSyntaxTokens(t *testing.T) {
 	cases := []struct {
 		name     string
 		filename string
@@ -86,9 +86,9 @@ func TestTokenizer_tokenizeReader(t *testing.T) {
 				testMakeToken(tokenTypeKey, "Executable"),
 				testMakeToken(tokenTypeScopeName, "mountains"),
 				testMakeToken(tokenTypeKey, "Build-Depends"),
-				testMakeToken(tokenTypeValue, "base   >= 3.0 && < 5"),
-				testMakeToken(tokenTypeValue, "GLUT   >= 2.4 && < 2.8"),
-				testMakeToken(tokenTypeValue, "OpenGL >= 2.8 && < 3.1"),
+				testMakeToken(tokenTypeValue, "base   >= 3.0 && < 5,"),
+				testMakeToken(tokenTypeValue, "GLUT   >= 2.4 && < 2.8,"),
+				testMakeToken(tokenTypeValue, "OpenGL >= 2.8 && < 3.1,"),
 				testMakeToken(tokenTypeValue, "random >= 1.0 && < 1.2"),
 				testMakeToken(tokenTypeKey, "Extensions"),
 				testMakeToken(tokenTypeValue, "FlexibleContexts"),
@@ -101,8 +101,8 @@ func TestTokenizer_tokenizeReader(t *testing.T) {
 				testMakeToken(tokenTypeKey, "Executable"),
 				testMakeToken(tokenTypeScopeName, "l-systems"),
 				testMakeToken(tokenTypeKey, "Build-Depends"),
-				testMakeToken(tokenTypeValue, "base   >= 3.0 && < 5"),
-				testMakeToken(tokenTypeValue, "GLUT   >= 2.4 && < 2.8"),
+				testMakeToken(tokenTypeValue, "base   >= 3.0 && < 5,"),
+				testMakeToken(tokenTypeValue, "GLUT   >= 2.4 && < 2.8,"),
 				testMakeToken(tokenTypeValue, "OpenGL >= 2.8 && < 3.1"),
 				testMakeToken(tokenTypeKey, "Extensions"),
 				testMakeToken(tokenTypeValue, "FlexibleContexts"),
@@ -189,9 +189,9 @@ func TestTokenizer_tokenizeReader(t *testing.T) {
 				testMakeToken(tokenTypeKey, "Executable"),
 				testMakeToken(tokenTypeScopeName, "mountains"),
 				testMakeToken(tokenTypeKey, "Build-Depends"),
-				testMakeToken(tokenTypeValue, "base   >= 3.0 && < 5"),
-				testMakeToken(tokenTypeValue, "GLUT   >= 2.4 && < 2.8"),
-				testMakeToken(tokenTypeValue, "OpenGL >= 2.8 && < 3.1"),
+				testMakeToken(tokenTypeValue, "base   >= 3.0 && < 5,"),
+				testMakeToken(tokenTypeValue, "GLUT   >= 2.4 && < 2.8,"),
+				testMakeToken(tokenTypeValue, "OpenGL >= 2.8 && < 3.1,"),
 				testMakeToken(tokenTypeValue, "random >= 1.0 && < 1.2"),
 				testMakeToken(tokenTypeKey, "Extensions"),
 				testMakeToken(tokenTypeValue, "FlexibleContexts"),
@@ -204,8 +204,8 @@ func TestTokenizer_tokenizeReader(t *testing.T) {
 				testMakeToken(tokenTypeKey, "Executable"),
 				testMakeToken(tokenTypeScopeName, "l-systems"),
 				testMakeToken(tokenTypeKey, "Build-Depends"),
-				testMakeToken(tokenTypeValue, "base   >= 3.0 && < 5"),
-				testMakeToken(tokenTypeValue, "GLUT   >= 2.4 && < 2.8"),
+				testMakeToken(tokenTypeValue, "base   >= 3.0 && < 5,"),
+				testMakeToken(tokenTypeValue, "GLUT   >= 2.4 && < 2.8,"),
 				testMakeToken(tokenTypeValue, "OpenGL >= 2.8 && < 3.1"),
 				testMakeToken(tokenTypeKey, "Extensions"),
 				testMakeToken(tokenTypeValue, "FlexibleContexts"),
@@ -234,16 +234,14 @@ func TestTokenizer_tokenizeReader(t *testing.T) {
 
 			defer f.Close()
 
-			tt := newTokenizer()
-			p, err := tt.TokenizeReader(f)
+			tree, err := ParseSyntaxTree(f)
 			if err != nil {
 				t.Fatal(err)
 			}
 
-			if !reflect.DeepEqual(tc.expected, p) {
+			if p := syntaxTokens(tree.Nodes, isConditionalSection); !reflect.DeepEqual(tc.expected, p) {
 				t.Logf("expected: %v", tc.expected)
 				t.Logf("actual: %v", p)
-				t.Logf("states: %v", tt.states)
 				t.Fatalf("expected value not equal to actual")
 			}
 		})
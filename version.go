@@ -0,0 +1,90 @@
+package gocabalparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a dotted numeric version such as 1.2.0.3.
+type Version []int
+
+// ParseVersion parses a dotted numeric version.
+func ParseVersion(s string) (Version, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty version")
+	}
+
+	chunks := strings.Split(s, ".")
+	res := make(Version, 0, len(chunks))
+
+	for _, c := range chunks {
+		if c == "" || c[0] == '+' || c[0] == '-' {
+			return nil, fmt.Errorf("invalid version: %s", s)
+		}
+
+		n, err := strconv.Atoi(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version: %s", s)
+		}
+
+		res = append(res, n)
+	}
+
+	return res, nil
+}
+
+// Compare returns -1, 0 or 1 when v is less than, equal to or greater
+// than o. Versions are compared component-wise, so 1.0 < 1.0.0.
+func (v Version) Compare(o Version) int {
+	for i := 0; i < len(v) && i < len(o); i++ {
+		if v[i] != o[i] {
+			if v[i] < o[i] {
+				return -1
+			}
+
+			return 1
+		}
+	}
+
+	switch {
+	case len(v) < len(o):
+		return -1
+	case len(v) > len(o):
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (v Version) String() string {
+	chunks := make([]string, len(v))
+
+	for i, n := range v {
+		chunks[i] = strconv.Itoa(n)
+	}
+
+	return strings.Join(chunks, ".")
+}
+
+// SpecVersion returns the version of the cabal specification the package
+// is written against. Old style ranges such as ">= 1.8" are interpreted by
+// their lower bound, and a missing cabal-version means 1.0.
+func (p *CabalPackage) SpecVersion() (Version, error) {
+	s := strings.TrimSpace(p.CabalVersion)
+	if s == "" {
+		return Version{1, 0}, nil
+	}
+
+	s = strings.TrimSpace(strings.TrimPrefix(s, ">="))
+	if i := strings.IndexAny(s, " &"); i >= 0 {
+		s = s[:i]
+	}
+
+	v, err := ParseVersion(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cabal-version: %s", p.CabalVersion)
+	}
+
+	return v, nil
+}
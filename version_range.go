@@ -0,0 +1,405 @@
+package gocabalparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type VersionRangeKind int
+
+const (
+	// VersionRangeAny matches every version, written -any or left out.
+	VersionRangeAny VersionRangeKind = iota
+	// VersionRangeNone matches no version, written -none.
+	VersionRangeNone
+	VersionRangeThis
+	VersionRangeLater
+	VersionRangeOrLater
+	VersionRangeEarlier
+	VersionRangeOrEarlier
+	// VersionRangeWildcard is == 1.2.*, matching 1.2 and its subversions.
+	VersionRangeWildcard
+	// VersionRangeMajorBound is ^>= 1.2.3, meaning >= 1.2.3 && < 1.3.
+	VersionRangeMajorBound
+	VersionRangeUnion
+	VersionRangeIntersection
+)
+
+func (k VersionRangeKind) String() string {
+	switch k {
+	case VersionRangeAny:
+		return "any"
+	case VersionRangeNone:
+		return "none"
+	case VersionRangeThis:
+		return "eq"
+	case VersionRangeLater:
+		return "gt"
+	case VersionRangeOrLater:
+		return "ge"
+	case VersionRangeEarlier:
+		return "lt"
+	case VersionRangeOrEarlier:
+		return "le"
+	case VersionRangeWildcard:
+		return "wildcard"
+	case VersionRangeMajorBound:
+		return "majorBound"
+	case VersionRangeUnion:
+		return "union"
+	case VersionRangeIntersection:
+		return "intersection"
+	default:
+		return "unknown"
+	}
+}
+
+var versionRangeOperators = map[VersionRangeKind]string{
+	VersionRangeThis:       "==",
+	VersionRangeLater:      ">",
+	VersionRangeOrLater:    ">=",
+	VersionRangeEarlier:    "<",
+	VersionRangeOrEarlier:  "<=",
+	VersionRangeWildcard:   "==",
+	VersionRangeMajorBound: "^>=",
+}
+
+// VersionRange is a cabal version range such as ">= 1.2 && < 1.4". Bound
+// kinds use Version, union and intersection combine Left and Right.
+type VersionRange struct {
+	Kind    VersionRangeKind
+	Version Version
+	Left    *VersionRange
+	Right   *VersionRange
+}
+
+// ParseVersionRange parses a version range, e.g. "^>= 4.14 || == 5.*".
+func ParseVersionRange(s string) (*VersionRange, error) {
+	p := &versionRangeParser{tokens: splitVersionRangeTokens(s)}
+
+	r, err := p.parseUnion()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.index < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token in version range: %s", p.tokens[p.index])
+	}
+
+	return r, nil
+}
+
+// Contains reports whether the version is within the range.
+func (r *VersionRange) Contains(v Version) bool {
+	switch r.Kind {
+	case VersionRangeAny:
+		return true
+	case VersionRangeNone:
+		return false
+	case VersionRangeThis:
+		return v.Compare(r.Version) == 0
+	case VersionRangeLater:
+		return v.Compare(r.Version) > 0
+	case VersionRangeOrLater:
+		return v.Compare(r.Version) >= 0
+	case VersionRangeEarlier:
+		return v.Compare(r.Version) < 0
+	case VersionRangeOrEarlier:
+		return v.Compare(r.Version) <= 0
+	case VersionRangeWildcard:
+		return v.Compare(r.Version) >= 0 && v.Compare(wildcardUpperBound(r.Version)) < 0
+	case VersionRangeMajorBound:
+		return v.Compare(r.Version) >= 0 && v.Compare(majorUpperBound(r.Version)) < 0
+	case VersionRangeUnion:
+		return r.Left.Contains(v) || r.Right.Contains(v)
+	case VersionRangeIntersection:
+		return r.Left.Contains(v) && r.Right.Contains(v)
+	default:
+		return false
+	}
+}
+
+// HasUpperBound reports whether the range excludes all versions above
+// some version.
+func (r *VersionRange) HasUpperBound() bool {
+	switch r.Kind {
+	case VersionRangeAny, VersionRangeLater, VersionRangeOrLater:
+		return false
+	case VersionRangeUnion:
+		return r.Left.HasUpperBound() && r.Right.HasUpperBound()
+	case VersionRangeIntersection:
+		return r.Left.HasUpperBound() || r.Right.HasUpperBound()
+	default:
+		return true
+	}
+}
+
+func (r *VersionRange) String() string {
+	switch r.Kind {
+	case VersionRangeAny:
+		return "-any"
+	case VersionRangeNone:
+		return "-none"
+	case VersionRangeWildcard:
+		return "== " + r.Version.String() + ".*"
+	case VersionRangeUnion:
+		return r.Left.String() + " || " + r.Right.operand(VersionRangeUnion)
+	case VersionRangeIntersection:
+		return r.Left.operand(VersionRangeUnion) + " && " + r.Right.operand(VersionRangeUnion, VersionRangeIntersection)
+	default:
+		return versionRangeOperators[r.Kind] + " " + r.Version.String()
+	}
+}
+
+// operand renders the range parenthesized when it is one of the kinds.
+func (r *VersionRange) operand(parenthesized ...VersionRangeKind) string {
+	for _, k := range parenthesized {
+		if r.Kind == k {
+			return "(" + r.String() + ")"
+		}
+	}
+
+	return r.String()
+}
+
+// wildcardUpperBound returns the least version above all versions matched
+// by v.*, e.g. 1.3 for 1.2.
+func wildcardUpperBound(v Version) Version {
+	res := append(Version{}, v...)
+	res[len(res)-1]++
+
+	return res
+}
+
+// majorUpperBound returns the upper bound of ^>= v, e.g. 1.3 for 1.2.3.
+func majorUpperBound(v Version) Version {
+	switch len(v) {
+	case 0:
+		return Version{0, 1}
+	case 1:
+		return Version{v[0], 1}
+	default:
+		return Version{v[0], v[1] + 1}
+	}
+}
+
+type versionRangeParser struct {
+	tokens []string
+	index  int
+}
+
+func (p *versionRangeParser) peek() string {
+	if p.index < len(p.tokens) {
+		return p.tokens[p.index]
+	}
+
+	return ""
+}
+
+func (p *versionRangeParser) next() string {
+	t := p.peek()
+	p.index++
+
+	return t
+}
+
+func (p *versionRangeParser) parseUnion() (*VersionRange, error) {
+	return p.parseBinary(VersionRangeUnion, "||", p.parseIntersection)
+}
+
+func (p *versionRangeParser) parseIntersection() (*VersionRange, error) {
+	return p.parseBinary(VersionRangeIntersection, "&&", p.parseAtom)
+}
+
+func (p *versionRangeParser) parseBinary(kind VersionRangeKind, op string, operand func() (*VersionRange, error)) (*VersionRange, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek() == op {
+		p.next()
+
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &VersionRange{Kind: kind, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *versionRangeParser) parseAtom() (*VersionRange, error) {
+	switch t := p.next(); t {
+	case "":
+		return nil, fmt.Errorf("version range expected")
+	case "(":
+		r, err := p.parseUnion()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.next() != ")" {
+			return nil, fmt.Errorf("closing parenthesis expected in version range")
+		}
+
+		return r, nil
+	case "-any":
+		return &VersionRange{Kind: VersionRangeAny}, nil
+	case "-none":
+		return &VersionRange{Kind: VersionRangeNone}, nil
+	case "==", ">", ">=", "<", "<=", "^>=":
+		if p.peek() == "{" && (t == "==" || t == "^>=") {
+			return p.parseVersionSet(t)
+		}
+
+		return parseVersionBound(t, p.next())
+	default:
+		return nil, fmt.Errorf("unexpected token in version range: %s", t)
+	}
+}
+
+// parseVersionSet parses "== { 1.2, 1.3 }" into a union of bounds.
+func (p *versionRangeParser) parseVersionSet(op string) (*VersionRange, error) {
+	p.next()
+
+	var res *VersionRange
+
+	for {
+		r, err := parseVersionBound(op, p.next())
+		if err != nil {
+			return nil, err
+		}
+
+		if res == nil {
+			res = r
+		} else {
+			res = &VersionRange{Kind: VersionRangeUnion, Left: res, Right: r}
+		}
+
+		switch p.next() {
+		case ",":
+			continue
+		case "}":
+			return res, nil
+		default:
+			return nil, fmt.Errorf("closing brace expected in version set")
+		}
+	}
+}
+
+func parseVersionBound(op, version string) (*VersionRange, error) {
+	kinds := map[string]VersionRangeKind{
+		"==":  VersionRangeThis,
+		">":   VersionRangeLater,
+		">=":  VersionRangeOrLater,
+		"<":   VersionRangeEarlier,
+		"<=":  VersionRangeOrEarlier,
+		"^>=": VersionRangeMajorBound,
+	}
+
+	kind := kinds[op]
+
+	if strings.HasSuffix(version, ".*") {
+		if op != "==" {
+			return nil, fmt.Errorf("wildcard is only allowed with ==: %s %s", op, version)
+		}
+
+		kind = VersionRangeWildcard
+		version = strings.TrimSuffix(version, ".*")
+	}
+
+	v, err := ParseVersion(version)
+	if err != nil {
+		return nil, fmt.Errorf("version expected after %s: %v", op, err)
+	}
+
+	return &VersionRange{Kind: kind, Version: v}, nil
+}
+
+// splitVersionRangeTokens splits a range into operators, punctuation and
+// versions; whitespace between them is optional.
+func splitVersionRangeTokens(s string) []string {
+	res := make([]string, 0)
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case isSpace(c):
+			i++
+
+			continue
+		case strings.ContainsRune("(){},", rune(c)):
+			res = append(res, s[i:i+1])
+			i++
+
+			continue
+		}
+
+		j := i + 1
+
+		switch {
+		case strings.HasPrefix(s[i:], "&&") || strings.HasPrefix(s[i:], "||"):
+			j = i + 2
+		case strings.ContainsRune("<>=^", rune(c)):
+			for j < len(s) && strings.ContainsRune("<>=^", rune(s[j])) {
+				j++
+			}
+		default:
+			for j < len(s) && !isSpace(s[j]) && !strings.ContainsRune("(){},<>=^&|", rune(s[j])) {
+				j++
+			}
+		}
+
+		res = append(res, s[i:j])
+		i = j
+	}
+
+	return res
+}
+
+// VersionRange returns the range of the dependency, building it from the
+// float bounds for dependencies constructed without one.
+func (d *Dependency) VersionRange() *VersionRange {
+	if d.Range != nil {
+		return d.Range
+	}
+
+	var res *VersionRange
+
+	for _, b := range []struct {
+		kind  VersionRangeKind
+		value float64
+	}{
+		{VersionRangeLater, d.GreaterThan},
+		{VersionRangeOrLater, d.GreaterOrEqualThan},
+		{VersionRangeEarlier, d.LessThan},
+		{VersionRangeOrEarlier, d.LessOrEqualThan},
+	} {
+		if b.value <= 0 {
+			continue
+		}
+
+		v, err := ParseVersion(strconv.FormatFloat(b.value, 'f', -1, 64))
+		if err != nil {
+			continue
+		}
+
+		r := &VersionRange{Kind: b.kind, Version: v}
+		if res == nil {
+			res = r
+		} else {
+			res = &VersionRange{Kind: VersionRangeIntersection, Left: res, Right: r}
+		}
+	}
+
+	if res == nil {
+		return &VersionRange{Kind: VersionRangeAny}
+	}
+
+	return res
+}
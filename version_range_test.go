@@ -0,0 +1,207 @@
+package gocabalparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVersionRange(t *testing.T) {
+	tcs := []struct {
+		input    string
+		expected *VersionRange
+		str      string
+	}{
+		{
+			input:    "-any",
+			expected: &VersionRange{Kind: VersionRangeAny},
+			str:      "-any",
+		},
+		{
+			input:    ">=1.10",
+			expected: &VersionRange{Kind: VersionRangeOrLater, Version: Version{1, 10}},
+			str:      ">= 1.10",
+		},
+		{
+			input: ">= 4 && < 5",
+			expected: &VersionRange{
+				Kind:  VersionRangeIntersection,
+				Left:  &VersionRange{Kind: VersionRangeOrLater, Version: Version{4}},
+				Right: &VersionRange{Kind: VersionRangeEarlier, Version: Version{5}},
+			},
+			str: ">= 4 && < 5",
+		},
+		{
+			input: "^>= 4.14 || == 5.*",
+			expected: &VersionRange{
+				Kind:  VersionRangeUnion,
+				Left:  &VersionRange{Kind: VersionRangeMajorBound, Version: Version{4, 14}},
+				Right: &VersionRange{Kind: VersionRangeWildcard, Version: Version{5}},
+			},
+			str: "^>= 4.14 || == 5.*",
+		},
+		{
+			input: ">=4&&<5",
+			expected: &VersionRange{
+				Kind:  VersionRangeIntersection,
+				Left:  &VersionRange{Kind: VersionRangeOrLater, Version: Version{4}},
+				Right: &VersionRange{Kind: VersionRangeEarlier, Version: Version{5}},
+			},
+			str: ">= 4 && < 5",
+		},
+		{
+			input: ">=4 &&<5",
+			expected: &VersionRange{
+				Kind:  VersionRangeIntersection,
+				Left:  &VersionRange{Kind: VersionRangeOrLater, Version: Version{4}},
+				Right: &VersionRange{Kind: VersionRangeEarlier, Version: Version{5}},
+			},
+			str: ">= 4 && < 5",
+		},
+		{
+			input: "^>=4.14||^>=4.15",
+			expected: &VersionRange{
+				Kind:  VersionRangeUnion,
+				Left:  &VersionRange{Kind: VersionRangeMajorBound, Version: Version{4, 14}},
+				Right: &VersionRange{Kind: VersionRangeMajorBound, Version: Version{4, 15}},
+			},
+			str: "^>= 4.14 || ^>= 4.15",
+		},
+		{
+			input: "(>1||<0.5)&&<=2",
+			expected: &VersionRange{
+				Kind: VersionRangeIntersection,
+				Left: &VersionRange{
+					Kind:  VersionRangeUnion,
+					Left:  &VersionRange{Kind: VersionRangeLater, Version: Version{1}},
+					Right: &VersionRange{Kind: VersionRangeEarlier, Version: Version{0, 5}},
+				},
+				Right: &VersionRange{Kind: VersionRangeOrEarlier, Version: Version{2}},
+			},
+			str: "(> 1 || < 0.5) && <= 2",
+		},
+		{
+			input: "(> 1 || < 0.5) && <= 2",
+			expected: &VersionRange{
+				Kind: VersionRangeIntersection,
+				Left: &VersionRange{
+					Kind:  VersionRangeUnion,
+					Left:  &VersionRange{Kind: VersionRangeLater, Version: Version{1}},
+					Right: &VersionRange{Kind: VersionRangeEarlier, Version: Version{0, 5}},
+				},
+				Right: &VersionRange{Kind: VersionRangeOrEarlier, Version: Version{2}},
+			},
+			str: "(> 1 || < 0.5) && <= 2",
+		},
+		{
+			input: "== { 1.2, 1.3 }",
+			expected: &VersionRange{
+				Kind:  VersionRangeUnion,
+				Left:  &VersionRange{Kind: VersionRangeThis, Version: Version{1, 2}},
+				Right: &VersionRange{Kind: VersionRangeThis, Version: Version{1, 3}},
+			},
+			str: "== 1.2 || == 1.3",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.input, func(t *testing.T) {
+			actual, err := ParseVersionRange(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Fatalf("expected %+v, got %+v", tc.expected, actual)
+			}
+
+			if actual.String() != tc.str {
+				t.Fatalf("expected %q, got %q", tc.str, actual.String())
+			}
+
+			reparsed, err := ParseVersionRange(actual.String())
+			if err != nil || !reflect.DeepEqual(reparsed, actual) {
+				t.Fatalf("%q does not round trip: %+v, %v", actual.String(), reparsed, err)
+			}
+		})
+	}
+}
+
+func TestParseVersionRange_errors(t *testing.T) {
+	for _, input := range []string{"", ">=", "4", ">= 4 &&", "(>= 4", "> 1.*", "== {1.2", ">= 4 < 5", ">= a", ">=4&<5", ">=&&4"} {
+		t.Run(input, func(t *testing.T) {
+			if _, err := ParseVersionRange(input); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestVersionRange_Contains(t *testing.T) {
+	tcs := []struct {
+		r        string
+		version  Version
+		expected bool
+	}{
+		{">= 4 && < 5", Version{4, 14}, true},
+		{">= 4 && < 5", Version{5}, false},
+		{"^>= 1.2.3", Version{1, 2, 9}, true},
+		{"^>= 1.2.3", Version{1, 3}, false},
+		{"^>= 1", Version{1, 0, 5}, true},
+		{"^>= 1", Version{1, 1}, false},
+		{"== 1.2.*", Version{1, 2}, true},
+		{"== 1.2.*", Version{1, 2, 4, 1}, true},
+		{"== 1.2.*", Version{1, 3}, false},
+		{"< 1 || > 2", Version{1, 5}, false},
+		{"< 1 || > 2", Version{2, 0, 1}, true},
+		{"-none", Version{1}, false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.r+" "+tc.version.String(), func(t *testing.T) {
+			if actual := mustParseVersionRange(tc.r).Contains(tc.version); actual != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestVersionRange_HasUpperBound(t *testing.T) {
+	tcs := map[string]bool{
+		"-any":             false,
+		">= 4":             false,
+		">= 4 && < 5":      true,
+		"^>= 4.14":         true,
+		"== 4.*":           true,
+		"< 4 || >= 5":      false,
+		"^>= 4.14 || == 5": true,
+	}
+
+	for r, expected := range tcs {
+		t.Run(r, func(t *testing.T) {
+			if actual := mustParseVersionRange(r).HasUpperBound(); actual != expected {
+				t.Fatalf("expected %v, got %v", expected, actual)
+			}
+		})
+	}
+}
+
+func TestDependency_VersionRange(t *testing.T) {
+	d := &Dependency{Name: "base", GreaterOrEqualThan: 4.14, LessThan: 5}
+
+	if actual := d.VersionRange().String(); actual != ">= 4.14 && < 5" {
+		t.Fatalf("unexpected range: %s", actual)
+	}
+
+	if actual := (&Dependency{Name: "base", IsLatest: true}).VersionRange(); actual.Kind != VersionRangeAny {
+		t.Fatalf("unexpected range: %s", actual)
+	}
+}
+
+func mustParseVersionRange(s string) *VersionRange {
+	r, err := ParseVersionRange(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
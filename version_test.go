@@ -0,0 +1,69 @@
+package gocabalparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	v, err := ParseVersion("1.2.0.10")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !reflect.DeepEqual(v, Version{1, 2, 0, 10}) || v.String() != "1.2.0.10" {
+		t.Fatalf("unexpected version: %v", v)
+	}
+
+	for _, s := range []string{"", "1.", ".1", "1..2", "1.a", "-1", "1.+2"} {
+		if _, err := ParseVersion(s); err == nil {
+			t.Fatalf("expected error for '%s'", s)
+		}
+	}
+}
+
+func TestVersion_Compare(t *testing.T) {
+	cases := []struct {
+		a, b     Version
+		expected int
+	}{
+		{a: Version{1, 2}, b: Version{1, 2}, expected: 0},
+		{a: Version{1, 2}, b: Version{1, 10}, expected: -1},
+		{a: Version{2}, b: Version{1, 10}, expected: 1},
+		{a: Version{1, 0}, b: Version{1, 0, 0}, expected: -1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.a.String()+" "+tc.b.String(), func(t *testing.T) {
+			if actual := tc.a.Compare(tc.b); actual != tc.expected {
+				t.Fatalf("expected %d, got %d", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCabalPackage_SpecVersion(t *testing.T) {
+	cases := []struct {
+		cabalVersion string
+		expected     Version
+	}{
+		{cabalVersion: "", expected: Version{1, 0}},
+		{cabalVersion: ">= 1.8", expected: Version{1, 8}},
+		{cabalVersion: ">=1.10 && <2", expected: Version{1, 10}},
+		{cabalVersion: "2.4", expected: Version{2, 4}},
+		{cabalVersion: "3.0", expected: Version{3, 0}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.cabalVersion, func(t *testing.T) {
+			actual, err := (&CabalPackage{CabalVersion: tc.cabalVersion}).SpecVersion()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}